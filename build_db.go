@@ -1,22 +1,40 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// schemaVersion is the version of the database schema created by this tool.
+// Bump it whenever the schema changes so the server can detect outdated databases.
+const schemaVersion = 3
+
+// defaultTuning is the tuning assumed for chords that don't specify one
+const defaultTuning = "standard"
+
 // ChordData represents the structure of your input JSON files
 type ChordData struct {
 	Key       string     `json:"key"`
 	Suffix    string     `json:"suffix"`
+	Tuning    string     `json:"tuning,omitempty"`
 	Positions []Position `json:"positions"`
 }
 
@@ -30,198 +48,803 @@ type Position struct {
 
 func main() {
 	sourceDir := flag.String("source", "", "Source directory containing chord JSON files")
+	inputFile := flag.String("input-file", "", "JSON file containing an array of chords, instead of -source")
 	outputFile := flag.String("output", "chords.db", "Output SQLite database file")
+	maxAliases := flag.Int("max-aliases", 20, "Maximum number of aliases generated per chord (0 for no limit)")
+	numberSuffixes := flag.String("number-suffixes", "", "Overrides for how bare-number suffixes resolve, e.g. 2=sus2 (pass the same value to the server)")
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "Number of files to parse in parallel (1 parses serially)")
+	germanAliases := flag.Bool("german-aliases", false, "Also generate aliases under German note names, e.g. H for B")
+	timeout := flag.Duration("timeout", 0, "Stop the build and remove the partial database after this long, e.g. 10m (0 for no limit)")
+	incremental := flag.Bool("incremental", false, "Update the existing -output database from the -source files that changed since it was built")
+	logLevel := flag.String("log-level", "info", "Least severe level logged: debug, info, warn or error")
+	logOutput := flag.String("log-output", "stdout", "Where logs go: stdout, stderr or the path of a file to append to")
 	flag.Parse()
 
-	if *sourceDir == "" {
-		fmt.Println("Usage: go run script.go -source=/path/to/source [-output=chords.db]")
+	if err := setupLogging(*logLevel, *logOutput); err != nil {
+		slog.Error("Invalid logging flags", "err", err)
 		os.Exit(1)
 	}
 
-	// Remove existing database if it exists
-	if _, err := os.Stat(*outputFile); err == nil {
-		if err := os.Remove(*outputFile); err != nil {
-			fmt.Printf("Error removing existing database: %v\n", err)
-			os.Exit(1)
-		}
+	if (*sourceDir == "") == (*inputFile == "") {
+		fmt.Println("Usage: go run script.go -source=/path/to/source | -input-file=chords.json [-output=chords.db] [-incremental]")
+		os.Exit(1)
 	}
 
-	// Create and open database
-	db, err := sql.Open("sqlite3", *outputFile)
-	if err != nil {
-		fmt.Printf("Error opening database: %v\n", err)
+	if *concurrency < 1 {
+		slog.Error("Concurrency must be at least 1")
 		os.Exit(1)
 	}
-	defer db.Close()
 
-	// Create tables
-	createTables(db)
+	if err := parseNumberSuffixes(*numberSuffixes); err != nil {
+		slog.Error("Invalid -number-suffixes", "err", err)
+		os.Exit(1)
+	}
 
-	// Prepare insert statements
-	chordStmt, err := db.Prepare(`
-		INSERT INTO chords (key, suffix, full_data) 
-		VALUES (?, ?, ?)
-	`)
-	if err != nil {
-		fmt.Printf("Error preparing chord statement: %v\n", err)
+	if *timeout < 0 {
+		slog.Error("Timeout can't be negative")
 		os.Exit(1)
 	}
-	defer chordStmt.Close()
 
-	fingStmt, err := db.Prepare(`
-		INSERT INTO fingerings (chord_id, frets, fingers, barres, capo) 
-		VALUES (?, ?, ?, ?, ?)
-	`)
-	if err != nil {
-		fmt.Printf("Error preparing fingering statement: %v\n", err)
+	// Cancel the build on Ctrl-C or when the timeout is exceeded
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+
+	if *incremental && *inputFile != "" {
+		slog.Error("-incremental tracks source files, so it needs -source rather than -input-file")
 		os.Exit(1)
 	}
-	defer fingStmt.Close()
 
-	aliasStmt, err := db.Prepare(`
-		INSERT INTO chord_aliases (chord_id, alias_key, alias_suffix) 
-		VALUES (?, ?, ?)
-	`)
+	var db *sql.DB
+	var err error
+	if *incremental {
+		// Update the existing database in place
+		db, err = openForUpdate(*outputFile)
+		if err != nil {
+			slog.Error("Can't update database", "output", *outputFile, "err", err)
+			os.Exit(1)
+		}
+	} else {
+		// Remove existing database if it exists
+		if _, err := os.Stat(*outputFile); err == nil {
+			if err := os.Remove(*outputFile); err != nil {
+				slog.Error("Error removing existing database", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		// Create and open database
+		db, err = sql.Open("sqlite3", *outputFile)
+		if err != nil {
+			slog.Error("Error opening database", "err", err)
+			os.Exit(1)
+		}
+
+		// Create tables
+		createTables(db)
+	}
+	defer db.Close()
+
+	// A cancelled full build leaves a partial database to remove, while a cancelled update leaves the database as it was
+	abort := func() {
+		if *incremental {
+			abortUpdate(ctx, db)
+		}
+		abortBuild(ctx, db, *outputFile)
+	}
+
+	// Prepare insert statements
+	inserter, err := newChordInserter(db, *maxAliases, *germanAliases)
 	if err != nil {
-		fmt.Printf("Error preparing alias statement: %v\n", err)
+		slog.Error("Error preparing statements", "err", err)
 		os.Exit(1)
 	}
-	defer aliasStmt.Close()
+	defer inserter.Close()
+
+	var parsedFiles []*parsedChord
+	var sources []sourceFile // The source file of each parsed chord, for -source builds
+	var removed []sourceFile // Source files recorded by the last build that are gone or changed
+	var added, changed, unchanged int
+	if *inputFile != "" {
+		// A single file holds every chord, in array order
+		parsedFiles, err = parseChordArray(*inputFile)
+		if err != nil {
+			slog.Error("Error reading chord array", "err", err)
+			os.Exit(1)
+		}
+	} else {
+		// Collect the JSON files to import. Walk visits them in lexical order, which keeps chord IDs deterministic.
+		var paths []string
+		err = filepath.Walk(*sourceDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			// Skip directories and non-JSON files
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
+				paths = append(paths, path)
+			}
+			return nil
+		})
+		if ctx.Err() != nil {
+			abort()
+		}
+		if err != nil {
+			slog.Error("Error walking directory", "err", err)
+			os.Exit(1)
+		}
+
+		sources, err = hashSourceFiles(*sourceDir, paths)
+		if err != nil {
+			slog.Error("Error reading source files", "err", err)
+			os.Exit(1)
+		}
+
+		// An update only parses the files whose content changed since the last build
+		if *incremental {
+			stored, err := loadSourceFiles(db)
+			if err != nil {
+				slog.Error("Error reading source files table", "err", err)
+				os.Exit(1)
+			}
+
+			var changedPaths []string
+			var changedSources []sourceFile
+			for i, source := range sources {
+				previous, ok := stored[source.path]
+				delete(stored, source.path)
+				switch {
+				case !ok:
+					added++
+				case previous.hash == source.hash:
+					unchanged++
+					continue
+				default:
+					changed++
+					removed = append(removed, previous)
+				}
+				changedPaths = append(changedPaths, paths[i])
+				changedSources = append(changedSources, source)
+			}
+			paths, sources = changedPaths, changedSources
+
+			// Whatever is left was built from files that no longer exist, in a stable order
+			for _, previous := range stored {
+				removed = append(removed, previous)
+			}
+			sort.Slice(removed, func(i, j int) bool { return removed[i].path < removed[j].path })
+		}
+
+		// Parse the files, in parallel unless concurrency is 1
+		parsedFiles = parseChordFiles(ctx, paths, *concurrency)
+	}
+	if ctx.Err() != nil {
+		abort()
+	}
 
 	// Start transaction for bulk insertion
 	tx, err := db.Begin()
 	if err != nil {
-		fmt.Printf("Error starting transaction: %v\n", err)
+		slog.Error("Error starting transaction", "err", err)
+		os.Exit(1)
+	}
+
+	// Drop the chords of removed and changed files before inserting, freeing their keys and aliases for the new data
+	for _, previous := range removed {
+		if err := deleteSourceFile(tx, previous); err != nil {
+			tx.Rollback()
+			slog.Error("Error removing chord", "source", previous.path, "err", err)
+			os.Exit(1)
+		}
+	}
+	if *incremental {
+		if err := inserter.loadAliases(tx); err != nil {
+			tx.Rollback()
+			slog.Error("Error reading aliases", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	// Insert the parsed chords serially, in file order
+	inconsistencyCount := 0
+	for i, parsed := range parsedFiles {
+		if ctx.Err() != nil {
+			tx.Rollback()
+			abort()
+		}
+
+		// Files and array elements that couldn't be read or parsed were already reported
+		if parsed == nil {
+			continue
+		}
+		inconsistencyCount += parsed.inconsistencies
+
+		chordID, ok := inserter.insert(tx, parsed)
+		if !ok || sources == nil {
+			continue
+		}
+
+		// Record the file the chord came from, so an update can tell when it changes
+		if _, err := tx.Stmt(inserter.sourceStmt).Exec(sources[i].path, sources[i].hash, chordID); err != nil {
+			slog.Error("Error recording source file", "source", sources[i].path, "err", err)
+		}
+	}
+
+	// Commit the transaction
+	if err := tx.Commit(); err != nil {
+		slog.Error("Error committing transaction", "err", err)
 		os.Exit(1)
 	}
 
-	// Counters
-	chordCount := 0
-	fingeringCount := 0
-	aliasCount := 0
+	if *incremental {
+		slog.Info("Database update complete", "output", *outputFile, "added", added, "changed", changed,
+			"removed", len(removed)-changed, "unchanged", unchanged, "chords", inserter.chords,
+			"fingerings", inserter.fingerings, "aliases", inserter.aliases)
+	} else {
+		// Create indexes after inserting data (faster)
+		if ctx.Err() != nil {
+			abort()
+		}
+		createIndexes(db)
 
-	// Process all files
-	err = filepath.Walk(*sourceDir, func(path string, info os.FileInfo, err error) error {
+		// Optimize database
+		_, err = db.Exec("VACUUM;")
 		if err != nil {
-			return err
+			slog.Error("Error optimizing database", "err", err)
 		}
 
-		// Skip directories and non-JSON files
-		if info.IsDir() || !strings.HasSuffix(info.Name(), ".json") {
-			return nil
+		slog.Info("Database creation complete", "output", *outputFile, "chords", inserter.chords,
+			"fingerings", inserter.fingerings, "aliases", inserter.aliases)
+	}
+
+	// Output stats
+	slog.Info("Generated aliases", "generated", inserter.generatedAliases, "capped", inserter.cappedAliases, "duplicates", inserter.duplicateAliases)
+	if *germanAliases {
+		slog.Info("Generated German aliases", "aliases", inserter.germanAliases)
+	}
+	if inconsistencyCount > 0 {
+		slog.Warn("Found fingering inconsistencies", "count", inconsistencyCount)
+	}
+
+	// Output file size
+	fileInfo, err := os.Stat(*outputFile)
+	if err == nil {
+		slog.Info("Database size", "mb", fmt.Sprintf("%.2f", float64(fileInfo.Size())/(1024*1024)))
+	}
+}
+
+// chordInserter inserts parsed chords with their fingerings and aliases, counting what it inserts
+type chordInserter struct {
+	chordStmt, fingStmt, aliasStmt, sourceStmt *sql.Stmt
+
+	maxAliases       int  // Most aliases generated per chord, 0 for no limit
+	withGerman       bool // Whether to generate aliases under German note names
+	insertedAliases  map[string]bool
+	chords           int
+	fingerings       int
+	aliases          int
+	generatedAliases int
+	cappedAliases    int
+	duplicateAliases int
+	germanAliases    int
+}
+
+// newChordInserter prepares the insert statements of a chordInserter
+func newChordInserter(db *sql.DB, maxAliases int, withGerman bool) (*chordInserter, error) {
+	inserter := &chordInserter{maxAliases: maxAliases, withGerman: withGerman, insertedAliases: make(map[string]bool)}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&inserter.chordStmt, `INSERT INTO chords (key, suffix, tuning, full_data) VALUES (?, ?, ?, ?)`},
+		{&inserter.fingStmt, `INSERT INTO fingerings (chord_id, frets, fingers, barres, capo) VALUES (?, ?, ?, ?, ?)`},
+		{&inserter.aliasStmt, `INSERT INTO chord_aliases (chord_id, alias_key, alias_suffix, alias_tuning) VALUES (?, ?, ?, ?)`},
+		{&inserter.sourceStmt, `INSERT INTO source_files (path, hash, chord_id) VALUES (?, ?, ?)`},
+	}
+	for _, statement := range statements {
+		stmt, err := db.Prepare(statement.query)
+		if err != nil {
+			inserter.Close()
+			return nil, err
 		}
+		*statement.stmt = stmt
+	}
+	return inserter, nil
+}
 
-		// Read the file
-		data, err := ioutil.ReadFile(path)
+// Close releases the prepared statements
+func (ins *chordInserter) Close() {
+	for _, stmt := range []*sql.Stmt{ins.chordStmt, ins.fingStmt, ins.aliasStmt, ins.sourceStmt} {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}
+}
+
+// loadAliases marks the aliases already in the database as taken, so an update doesn't insert them twice
+func (ins *chordInserter) loadAliases(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT alias_key, alias_suffix, alias_tuning FROM chord_aliases`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, suffix, tuning string
+		if err := rows.Scan(&key, &suffix, &tuning); err != nil {
+			return err
+		}
+		ins.insertedAliases[key+"|"+suffix+"|"+tuning] = true
+	}
+	return rows.Err()
+}
+
+// insert inserts a parsed chord with its fingerings and aliases, and returns the chord's ID.
+// Failures are reported, and it returns false if the chord itself couldn't be inserted.
+func (ins *chordInserter) insert(tx *sql.Tx, parsed *parsedChord) (int64, bool) {
+	chordData := parsed.chordData
+
+	// Insert the chord
+	res, err := tx.Stmt(ins.chordStmt).Exec(
+		chordData.Key,
+		chordData.Suffix,
+		chordData.Tuning,
+		string(parsed.data),
+	)
+	if err != nil {
+		slog.Error("Error inserting chord", "err", err)
+		return 0, false
+	}
+
+	// Get the chord ID
+	chordID, err := res.LastInsertId()
+	if err != nil {
+		slog.Error("Error getting last insert ID", "err", err)
+		return 0, false
+	}
+	ins.chords++
+
+	// Insert fingerings
+	for _, pos := range chordData.Positions {
+		_, err := tx.Stmt(ins.fingStmt).Exec(
+			chordID,
+			pos.Frets,
+			pos.Fingers,
+			pos.Barres,
+			pos.Capo,
+		)
 		if err != nil {
-			fmt.Printf("Error reading file %s: %v\n", path, err)
-			return nil
+			slog.Error("Error inserting fingering", "err", err)
+			continue
 		}
+		ins.fingerings++
+	}
 
-		// Parse the JSON
-		var chordData ChordData
-		if err := json.Unmarshal(data, &chordData); err != nil {
-			fmt.Printf("Error parsing JSON from %s: %v\n", path, err)
-			return nil
+	// Insert aliases
+	key := chordData.Key
+	suffix := chordData.Suffix
+
+	// Generate aliases for the suffix, skipping the original
+	suffixAliases := []string{}
+	for _, aliasStr := range getSuffixAliases(suffix) {
+		if aliasStr != suffix {
+			suffixAliases = append(suffixAliases, aliasStr)
 		}
+	}
+	ins.generatedAliases += len(suffixAliases)
 
-		// Insert the chord
-		res, err := tx.Stmt(chordStmt).Exec(
-			chordData.Key,
-			chordData.Suffix,
-			string(data),
+	// Cap the aliases per chord to keep the table and build time bounded
+	if ins.maxAliases > 0 && len(suffixAliases) > ins.maxAliases {
+		slog.Info("Capping aliases", "key", key, "suffix", suffix, "generated", len(suffixAliases), "kept", ins.maxAliases)
+		ins.cappedAliases += len(suffixAliases) - ins.maxAliases
+		suffixAliases = suffixAliases[:ins.maxAliases]
+	}
+
+	// Pair each alias with the key, and with the German name of the key, which also takes the original suffix
+	type aliasPair struct{ key, suffix string }
+	pairs := []aliasPair{}
+	for _, aliasStr := range suffixAliases {
+		pairs = append(pairs, aliasPair{key, aliasStr})
+	}
+	if germanKey, ok := germanKeys[key]; ok && ins.withGerman {
+		pairs = append(pairs, aliasPair{germanKey, suffix})
+		for _, aliasStr := range suffixAliases {
+			pairs = append(pairs, aliasPair{germanKey, aliasStr})
+		}
+		ins.germanAliases += len(suffixAliases) + 1
+	}
+
+	// Insert aliases
+	for _, alias := range pairs {
+		// Skip pairs another chord in the same tuning already claimed, e.g. one stored under both spellings of a suffix
+		pair := alias.key + "|" + alias.suffix + "|" + chordData.Tuning
+		if ins.insertedAliases[pair] {
+			ins.duplicateAliases++
+			continue
+		}
+
+		_, err := tx.Stmt(ins.aliasStmt).Exec(
+			chordID,
+			alias.key,
+			alias.suffix,
+			chordData.Tuning,
 		)
 		if err != nil {
-			fmt.Printf("Error inserting chord: %v\n", err)
-			return nil
+			slog.Error("Error inserting alias", "err", err)
+			continue
 		}
+		ins.insertedAliases[pair] = true
+		ins.aliases++
+	}
 
-		// Get the chord ID
-		chordID, err := res.LastInsertId()
+	return chordID, true
+}
+
+// sourceFile is a chord file as recorded in the source_files table: its path relative to the source directory,
+// the SHA-256 of its content and the chord built from it
+type sourceFile struct {
+	path    string
+	hash    string
+	chordID int64
+}
+
+// hashSourceFiles hashes the content of each file, naming them by their path relative to sourceDir
+func hashSourceFiles(sourceDir string, paths []string) ([]sourceFile, error) {
+	sources := make([]sourceFile, len(paths))
+	for i, path := range paths {
+		data, err := ioutil.ReadFile(path)
 		if err != nil {
-			fmt.Printf("Error getting last insert ID: %v\n", err)
-			return nil
+			return nil, err
 		}
-		chordCount++
-
-		// Insert fingerings
-		for _, pos := range chordData.Positions {
-			_, err := tx.Stmt(fingStmt).Exec(
-				chordID,
-				pos.Frets,
-				pos.Fingers,
-				pos.Barres,
-				pos.Capo,
-			)
-			if err != nil {
-				fmt.Printf("Error inserting fingering: %v\n", err)
-				continue
-			}
-			fingeringCount++
+		rel, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return nil, err
 		}
+		sum := sha256.Sum256(data)
+		sources[i] = sourceFile{path: filepath.ToSlash(rel), hash: hex.EncodeToString(sum[:])}
+	}
+	return sources, nil
+}
 
-		// Insert aliases
-		key := chordData.Key
-		suffix := chordData.Suffix
+// openForUpdate opens a database built from a source directory, which must have recorded its source files
+func openForUpdate(path string) (*sql.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%v (run a full build first)", err)
+	}
 
-		// Generate aliases for the suffix
-		suffixAliases := getSuffixAliases(suffix)
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
 
-		// Insert aliases
-		for _, aliasStr := range suffixAliases {
-			// Skip if it's the same as the original
-			if aliasStr == suffix {
-				continue
+	var version int
+	if err := db.QueryRow(`SELECT version FROM schema_version`).Scan(&version); err != nil || version != schemaVersion {
+		db.Close()
+		return nil, fmt.Errorf("schema is outdated (run a full build first)")
+	}
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'source_files'`).Scan(&tables); err != nil || tables == 0 {
+		db.Close()
+		return nil, fmt.Errorf("no source files are recorded (run a full build from -source first)")
+	}
+	// A build from -input-file has chords but no source files, so every file would look new
+	var sources, chords int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM source_files), (SELECT COUNT(*) FROM chords)`).Scan(&sources, &chords); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if sources == 0 && chords > 0 {
+		db.Close()
+		return nil, fmt.Errorf("the chords were not built from source files (run a full build from -source first)")
+	}
+	return db, nil
+}
+
+// loadSourceFiles reads the source_files table, keyed by path
+func loadSourceFiles(db *sql.DB) (map[string]sourceFile, error) {
+	rows, err := db.Query(`SELECT path, hash, chord_id FROM source_files`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sources := make(map[string]sourceFile)
+	for rows.Next() {
+		var source sourceFile
+		if err := rows.Scan(&source.path, &source.hash, &source.chordID); err != nil {
+			return nil, err
+		}
+		sources[source.path] = source
+	}
+	return sources, rows.Err()
+}
+
+// deleteSourceFile deletes the chord built from a source file, with its fingerings and aliases, and the file's record
+func deleteSourceFile(tx *sql.Tx, source sourceFile) error {
+	for _, query := range []string{
+		`DELETE FROM fingerings WHERE chord_id = ?`,
+		`DELETE FROM chord_aliases WHERE chord_id = ?`,
+		`DELETE FROM chords WHERE id = ?`,
+		`DELETE FROM source_files WHERE chord_id = ?`,
+	} {
+		if _, err := tx.Exec(query, source.chordID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setupLogging sends the logs at level and above to output, which is "stdout", "stderr" or the path of a file
+// to append to, as one line of key=value pairs per record
+func setupLogging(level, output string) error {
+	var minLevel slog.Level
+	if err := minLevel.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid -log-level %q: must be debug, info, warn or error", level)
+	}
+
+	var w io.Writer
+	switch output {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		file, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: minLevel})))
+	return nil
+}
+
+// parsedChord is a chord file read and parsed ahead of insertion
+type parsedChord struct {
+	data            []byte
+	chordData       ChordData
+	inconsistencies int // Fingering inconsistencies found by checkFingers
+}
+
+// parseChordFiles reads and parses chord files using the given number of workers.
+// Results are in the same order as paths, with nil for files that couldn't be read or parsed,
+// and files are left unparsed once ctx is cancelled.
+func parseChordFiles(ctx context.Context, paths []string, concurrency int) []*parsedChord {
+	results := make([]*parsedChord, len(paths))
+
+	if concurrency == 1 {
+		for i, path := range paths {
+			if ctx.Err() != nil {
+				break
 			}
+			results[i] = parseChordFile(path)
+		}
+		return results
+	}
 
-			_, err := tx.Stmt(aliasStmt).Exec(
-				chordID,
-				key,
-				aliasStr,
-			)
-			if err != nil {
-				fmt.Printf("Error inserting alias: %v\n", err)
-				continue
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = parseChordFile(paths[i])
 			}
-			aliasCount++
+		}()
+	}
+
+feed:
+	for i := range paths {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			break feed
 		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// abortBuild reports why the build was cancelled, removes the partial database and exits
+func abortBuild(ctx context.Context, db *sql.DB, outputFile string) {
+	reason := "interrupted"
+	if ctx.Err() == context.DeadlineExceeded {
+		reason = "timed out"
+	}
+	slog.Error("Build stopped, removing partial database", "reason", reason, "output", outputFile)
 
+	db.Close()
+	for _, path := range []string{outputFile, outputFile + "-journal"} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Error("Error removing file", "path", path, "err", err)
+		}
+	}
+	os.Exit(1)
+}
+
+// abortUpdate reports why an incremental update was cancelled and exits. The update's transaction was rolled
+// back or never started, so the database is left as it was.
+func abortUpdate(ctx context.Context, db *sql.DB) {
+	reason := "interrupted"
+	if ctx.Err() == context.DeadlineExceeded {
+		reason = "timed out"
+	}
+	slog.Error("Update stopped, database left unchanged", "reason", reason)
+
+	db.Close()
+	os.Exit(1)
+}
+
+// parseChordFile reads and parses a single chord file, reporting and returning nil on failure
+func parseChordFile(path string) *parsedChord {
+	// Read the file
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		slog.Error("Error reading file", "path", path, "err", err)
 		return nil
-	})
+	}
+	return parseChordData(path, data)
+}
 
+// parseChordArray reads a file containing a JSON array of chords and parses each element like a chord file.
+// Elements that can't be parsed are reported and left nil; an error means the file isn't a JSON array at all.
+func parseChordArray(path string) ([]*parsedChord, error) {
+	data, err := ioutil.ReadFile(path)
 	if err != nil {
-		fmt.Printf("Error walking directory: %v\n", err)
-		tx.Rollback()
-		os.Exit(1)
+		return nil, err
 	}
 
-	// Commit the transaction
-	if err := tx.Commit(); err != nil {
-		fmt.Printf("Error committing transaction: %v\n", err)
-		os.Exit(1)
+	var elements []json.RawMessage
+	if err := json.Unmarshal(data, &elements); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
 	}
 
-	// Create indexes after inserting data (faster)
-	createIndexes(db)
+	results := make([]*parsedChord, len(elements))
+	for i, element := range elements {
+		results[i] = parseChordData(fmt.Sprintf("%s element %d", path, i+1), element)
+	}
+	return results, nil
+}
 
-	// Optimize database
-	_, err = db.Exec("VACUUM;")
-	if err != nil {
-		fmt.Printf("Error optimizing database: %v\n", err)
+// parseChordData parses and validates the JSON of a single chord read from source, reporting and returning nil on failure
+func parseChordData(source string, data []byte) *parsedChord {
+	// Parse the JSON
+	var chordData ChordData
+	if err := json.Unmarshal(data, &chordData); err != nil {
+		slog.Error("Error parsing JSON", "source", source, "err", err)
+		return nil
 	}
 
-	// Output stats
-	fmt.Println("Database creation complete!")
-	fmt.Printf("Generated SQLite database at %s\n", *outputFile)
-	fmt.Printf("Inserted %d chords\n", chordCount)
-	fmt.Printf("Inserted %d fingerings\n", fingeringCount)
-	fmt.Printf("Created %d chord aliases\n", aliasCount)
+	// Barres and delimited frets must be fret numbers so the server can draw them
+	for i, pos := range chordData.Positions {
+		if err := validateBarres(pos.Barres); err != nil {
+			slog.Error("Invalid position", "source", source, "position", i+1, "err", err)
+			return nil
+		}
+		if err := validateFrets(pos.Frets); err != nil {
+			slog.Error("Invalid position", "source", source, "position", i+1, "err", err)
+			return nil
+		}
+	}
 
-	// Output file size
-	fileInfo, err := os.Stat(*outputFile)
-	if err == nil {
-		fmt.Printf("Database size: %.2f MB\n", float64(fileInfo.Size())/(1024*1024))
+	// Fingers that don't match the frets are most likely data entry mistakes, but the chord is still usable
+	inconsistencies := 0
+	for i, pos := range chordData.Positions {
+		for _, problem := range checkFingers(pos.Frets, pos.Fingers, pos.Barres) {
+			slog.Warn("Inconsistent fingering", "source", source, "position", i+1, "problem", problem)
+			inconsistencies++
+		}
+	}
+
+	// Chords without a tuning are in standard tuning
+	if chordData.Tuning == "" {
+		chordData.Tuning = defaultTuning
+	}
+
+	return &parsedChord{data: data, chordData: chordData, inconsistencies: inconsistencies}
+}
+
+// validateFrets checks a frets field written with dashes between the strings, e.g. "x-3-2-0-1-0".
+// Each string must be muted (x) or a fret number. Packed frets such as "x32010" aren't checked.
+func validateFrets(frets string) error {
+	if !strings.Contains(frets, "-") {
+		return nil
+	}
+
+	for _, part := range strings.Split(frets, "-") {
+		if part == "x" || part == "X" {
+			continue
+		}
+		if fret, err := strconv.Atoi(part); err != nil || fret < 0 {
+			return fmt.Errorf("invalid fret %q in frets %q", part, frets)
+		}
+	}
+	return nil
+}
+
+// checkFingers cross-checks a position's fingers against its frets, string by string, and describes each problem:
+// a finger on a muted or open string, or a fretted string with no finger that no barre covers. Strings are numbered
+// from the highest (1) down. Malformed frets and barres are left to validateFrets and validateBarres.
+func checkFingers(frets, fingers, barres string) []string {
+	if fingers == "" {
+		return nil
+	}
+
+	// Frets are packed one character per string, with letters from 10 up, or separated by dashes
+	var parsed []int
+	if strings.Contains(frets, "-") {
+		for _, part := range strings.Split(frets, "-") {
+			fret, err := strconv.Atoi(part)
+			if err != nil {
+				fret = -1
+			}
+			parsed = append(parsed, fret)
+		}
+	} else {
+		for _, c := range frets {
+			switch {
+			case c == 'x' || c == 'X':
+				parsed = append(parsed, -1)
+			case c >= '0' && c <= '9':
+				parsed = append(parsed, int(c-'0'))
+			case c >= 'a' && c <= 'z':
+				parsed = append(parsed, int(c-'a')+10)
+			default:
+				parsed = append(parsed, -1)
+			}
+		}
+	}
+	if len(fingers) != len(parsed) {
+		return []string{fmt.Sprintf("fingers lists %d strings but frets lists %d", len(fingers), len(parsed))}
+	}
+
+	barred := make(map[int]bool)
+	for _, part := range strings.Split(barres, ",") {
+		if fret, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
+			barred[fret] = true
+		}
+	}
+
+	var problems []string
+	for i, fret := range parsed {
+		finger := fingers[i]
+		fingered := finger != '0' && finger != 'x' && finger != 'X'
+		switch {
+		case fret < 0 && fingered:
+			problems = append(problems, fmt.Sprintf("string %d: finger %c on a muted string", len(parsed)-i, finger))
+		case fret == 0 && fingered:
+			problems = append(problems, fmt.Sprintf("string %d: finger %c on an open string", len(parsed)-i, finger))
+		case fret > 0 && !fingered && !barred[fret]:
+			problems = append(problems, fmt.Sprintf("string %d: fret %d has no finger and no barre", len(parsed)-i, fret))
+		}
+	}
+	return problems
+}
+
+// validateBarres checks that a barres field is a comma-separated list of fret numbers, e.g. "1" or "1,3"
+func validateBarres(barres string) error {
+	if strings.TrimSpace(barres) == "" {
+		return nil
+	}
+
+	for _, part := range strings.Split(barres, ",") {
+		fret, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || fret < 1 {
+			return fmt.Errorf("invalid barre fret %q in barres %q", part, barres)
+		}
 	}
+	return nil
 }
 
 // Create database tables
@@ -232,12 +855,13 @@ func createTables(db *sql.DB) {
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			key TEXT NOT NULL,
 			suffix TEXT NOT NULL,
+			tuning TEXT NOT NULL DEFAULT 'standard',
 			full_data TEXT NOT NULL,
-			UNIQUE(key, suffix)
+			UNIQUE(key, suffix, tuning)
 		);
 	`)
 	if err != nil {
-		fmt.Printf("Error creating chords table: %v\n", err)
+		slog.Error("Error creating chords table", "err", err)
 		os.Exit(1)
 	}
 
@@ -254,7 +878,7 @@ func createTables(db *sql.DB) {
 		);
 	`)
 	if err != nil {
-		fmt.Printf("Error creating fingerings table: %v\n", err)
+		slog.Error("Error creating fingerings table", "err", err)
 		os.Exit(1)
 	}
 
@@ -265,12 +889,45 @@ func createTables(db *sql.DB) {
 			chord_id INTEGER NOT NULL,
 			alias_key TEXT NOT NULL,
 			alias_suffix TEXT NOT NULL,
-			UNIQUE(alias_key, alias_suffix),
+			alias_tuning TEXT NOT NULL DEFAULT 'standard',
+			UNIQUE(alias_key, alias_suffix, alias_tuning),
 			FOREIGN KEY(chord_id) REFERENCES chords(id)
 		);
 	`)
 	if err != nil {
-		fmt.Printf("Error creating chord_aliases table: %v\n", err)
+		slog.Error("Error creating chord_aliases table", "err", err)
+		os.Exit(1)
+	}
+
+	// Create source files table, recording the chord file each chord was built from for incremental updates.
+	// The server doesn't read it.
+	_, err = db.Exec(`
+		CREATE TABLE source_files (
+			path TEXT PRIMARY KEY,
+			hash TEXT NOT NULL,
+			chord_id INTEGER NOT NULL,
+			FOREIGN KEY(chord_id) REFERENCES chords(id)
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating source_files table", "err", err)
+		os.Exit(1)
+	}
+
+	// Create schema version table
+	_, err = db.Exec(`
+		CREATE TABLE schema_version (
+			version INTEGER NOT NULL
+		);
+	`)
+	if err != nil {
+		slog.Error("Error creating schema_version table", "err", err)
+		os.Exit(1)
+	}
+
+	_, err = db.Exec(`INSERT INTO schema_version (version) VALUES (?)`, schemaVersion)
+	if err != nil {
+		slog.Error("Error writing schema version", "err", err)
 		os.Exit(1)
 	}
 }
@@ -280,26 +937,65 @@ func createIndexes(db *sql.DB) {
 	// Index for chord lookup by key+suffix
 	_, err := db.Exec(`CREATE INDEX idx_chords_key_suffix ON chords(key, suffix);`)
 	if err != nil {
-		fmt.Printf("Error creating index on chords: %v\n", err)
+		slog.Error("Error creating index on chords", "err", err)
 	}
 
 	// Index for fingering lookup
 	_, err = db.Exec(`CREATE INDEX idx_fingerings_frets ON fingerings(frets);`)
 	if err != nil {
-		fmt.Printf("Error creating index on fingerings: %v\n", err)
+		slog.Error("Error creating index on fingerings", "err", err)
 	}
 
 	// Index for chord_id in fingerings for faster joins
 	_, err = db.Exec(`CREATE INDEX idx_fingerings_chord_id ON fingerings(chord_id);`)
 	if err != nil {
-		fmt.Printf("Error creating index on fingerings chord_id: %v\n", err)
+		slog.Error("Error creating index on fingerings chord_id", "err", err)
 	}
 
 	// Index for alias lookup
 	_, err = db.Exec(`CREATE INDEX idx_aliases_key_suffix ON chord_aliases(alias_key, alias_suffix);`)
 	if err != nil {
-		fmt.Printf("Error creating index on aliases: %v\n", err)
+		slog.Error("Error creating index on aliases", "err", err)
+	}
+}
+
+// numberSuffixes maps bare-number suffixes and the add family to the suffixes they are aliases of.
+// A bare 2 adds the second to the full triad (add9), while a bare 4 replaces the third (sus4).
+// It must match numberSuffixMap in server.go.
+var numberSuffixes = map[string]string{
+	"2":     "add9",
+	"add2":  "add9",
+	"madd2": "madd9",
+	"4":     "sus4",
+	"6":     "6",
+	"m6":    "m6",
+	"69":    "69",
+	"6/9":   "69",
+	"m69":   "m69",
+	"m6/9":  "m69",
+	"9":     "9",
+}
+
+// parseNumberSuffixes applies overrides like "2=sus2,4=sus4" to numberSuffixes
+func parseNumberSuffixes(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		suffix, target, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || suffix == "" || target == "" {
+			return fmt.Errorf("invalid mapping %q, expected suffix=target", entry)
+		}
+		numberSuffixes[suffix] = target
 	}
+	return nil
+}
+
+// germanKeys maps stored keys to their German note names. German B is B flat, which would collide with
+// the English B chords, so B flat (stored as A#) gets no German alias and only H is added, for B.
+var germanKeys = map[string]string{
+	"B": "H",
 }
 
 // getSuffixAliases returns a list of aliases for a given chord suffix
@@ -327,17 +1023,34 @@ func getSuffixAliases(suffix string) []string {
 		aliases = append(aliases, "sus2", "suspended2")
 	case "sus4":
 		aliases = append(aliases, "sus4", "suspended4")
+	case "6":
+		aliases = append(aliases, "6", "maj6", "add6", "M6")
+	case "m6":
+		aliases = append(aliases, "m6", "min6", "minor6")
+	case "69":
+		aliases = append(aliases, "69", "6add9")
+	case "m69":
+		aliases = append(aliases, "m69", "min69")
 	}
 
-	// Return unique aliases
-	uniqueAliases := make(map[string]bool)
-	for _, a := range aliases {
-		uniqueAliases[a] = true
+	// Bare numbers and the add family, in a fixed order so capping them is deterministic
+	var numbers []string
+	for number, target := range numberSuffixes {
+		if target == suffix {
+			numbers = append(numbers, number)
+		}
 	}
+	sort.Strings(numbers)
+	aliases = append(aliases, numbers...)
 
+	// Return unique aliases in the order they were listed, so capping them is deterministic
+	uniqueAliases := make(map[string]bool)
 	result := []string{}
-	for a := range uniqueAliases {
-		result = append(result, a)
+	for _, a := range aliases {
+		if !uniqueAliases[a] {
+			uniqueAliases[a] = true
+			result = append(result, a)
+		}
 	}
 
 	return result
@@ -1,52 +1,627 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
+	"embed"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"flag"
 	"fmt"
-	"log"
+	"hash/fnv"
+	"html"
+	"io"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func corsMiddleware(next http.Handler) http.Handler {
+// errorFormat selects how error responses are written: "text" or "json"
+var errorFormat string
+
+// contextKey is the type of keys for values the server stores in request contexts
+type contextKey string
+
+// requestIDKey is the context key of the request ID
+const requestIDKey contextKey = "requestID"
+
+// requestIDMiddleware tags each request with an ID, honoring one supplied by the client,
+// and echoes it in the X-Request-ID response header
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey, id)))
+	})
+}
+
+// newRequestID generates a random request ID
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestID returns the ID of a request tagged by requestIDMiddleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey).(string)
+	return id
+}
+
+// logLevel is the least severe level that is logged, set by -log-level
+var logLevel = new(slog.LevelVar)
+
+// setupLogging sends the logs to output, which is "stdout", "stderr" or the path of a file to append to,
+// as one line of key=value pairs per record
+func setupLogging(output string) error {
+	var w io.Writer
+	switch output {
+	case "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		file, err := os.OpenFile(output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		w = file
+	}
+
+	slog.SetDefault(slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: logLevel})))
+	return nil
+}
+
+// fatal logs an error that stops the server and exits
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// warnf logs a formatted warning, for code that reports problems through a printf-style function
+func warnf(format string, args ...interface{}) {
+	slog.Warn(fmt.Sprintf(format, args...))
+}
+
+// debugf logs a diagnostic message for a request at debug level
+func debugf(r *http.Request, format string, args ...interface{}) {
+	slog.Debug(fmt.Sprintf(format, args...), "request_id", requestID(r), "method", r.Method, "path", r.URL.Path)
+}
+
+// writeError logs an error response and sends it in the configured error format.
+// Server errors are logged as errors and client errors as info, since they're the client's problem.
+func writeError(w http.ResponseWriter, r *http.Request, message string, code int) {
+	id := requestID(r)
+	level := slog.LevelInfo
+	if code >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	slog.Log(r.Context(), level, message, "request_id", id, "method", r.Method, "path", r.URL.Path, "status", code)
+
+	if errorFormat == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]string{"error": message, "request_id": id})
+		return
+	}
+
+	http.Error(w, message, code)
+}
+
+// adminToken is the bearer token required by admin-gated endpoints. They are disabled when it's empty.
+var adminToken string
+
+// requireAdmin only lets requests with the admin token through to a handler
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if adminToken == "" {
+			writeError(w, r, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			writeError(w, r, "Invalid admin token", http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// writeBody writes a fully materialized response body with an explicit Content-Length,
+// so clients get a sized response instead of chunked encoding
+func writeBody(w http.ResponseWriter, body string) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	fmt.Fprint(w, body)
+}
+
+// ndjsonContentType is the media type of newline-delimited JSON, one value per line
+const ndjsonContentType = "application/x-ndjson"
+
+// wantsNDJSON reports whether the request's Accept header asks for newline-delimited JSON
+func wantsNDJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		if strings.TrimSpace(mediaType) == ndjsonContentType {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCount writes the response to a count_only request, the number of results the request would return
+func writeCount(w http.ResponseWriter, count int) {
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, fmt.Sprintf(`{"count":%d}`, count))
+}
+
+// writeNDJSON writes each result on its own line, flushing after every line so clients can process
+// results as they arrive
+func writeNDJSON(w http.ResponseWriter, results []json.RawMessage) {
+	w.Header().Set("Content-Type", ndjsonContentType)
+	controller := http.NewResponseController(w)
+
+	var line bytes.Buffer
+	for _, result := range results {
+		// Stored chord data may be indented, which would split it across lines
+		line.Reset()
+		if err := json.Compact(&line, result); err != nil {
+			slog.Error("Error encoding NDJSON line", "err", err)
+			return
+		}
+		line.WriteByte('\n')
+
+		if _, err := w.Write(line.Bytes()); err != nil {
+			return
+		}
+		controller.Flush()
+	}
+}
+
+// corsMaxAge is how long, in seconds, browsers may cache preflight responses
+var corsMaxAge int
+
+// routeMethods maps each registered route pattern to the HTTP methods it supports
+var routeMethods = make(map[string][]string)
+
+// strictParams rejects requests with query parameters their route doesn't accept, instead of ignoring them
+var strictParams bool
+
+// renderChordParams are the query parameters renderChord reads, accepted by every route that renders chords with it
+var renderChordParams = []string{"include_intervals", "include_bass", "include_finger_count", "include_flags", "include_enharmonic", "include_diagram"}
+
+// routeParams maps each route pattern to the query parameters it accepts. Routes not listed accept none.
+var routeParams = map[string][]string{
+	"/chords":              append([]string{"key", "suffix", "tuning", "limit", "offset", "include_data"}, renderChordParams...),
+	"/chords/":             append([]string{"format", "derive", "skip_missing", "instruments", "min_shared", "display_tuning", "prefer", "mode", "no_capo", "minfret", "maxfret"}, renderChordParams...),
+	"/chords/daily":        append([]string{"date"}, renderChordParams...),
+	"/fingers/":            {"fingers", "tuning", "empty_ok", "count_only", "mode", "no_capo", "minfret", "maxfret"},
+	"/search/":             {"open", "sort", "group_by", "dedupe_positions", "empty_ok", "count_only", "mode", "no_capo", "minfret", "maxfret"},
+	"/sitemap.json":        {"page"},
+	"/containing/":         {"page"},
+	"/analyze-progression": {"key", "lang"},
+	"/transpose/batch":     append([]string{"interval", "lang"}, renderChordParams...),
+	"/admin/coverage":      {"suffixes", "tuning", "format"},
+}
+
+// endpoints groups route patterns under the names -enable selects them by. Health checks are always enabled.
+var endpoints = map[string][]string{
+	"chords":       {"/chords", "/chords/", "/chords/daily"},
+	"fingers":      {"/fingers/"},
+	"search":       {"/search/"},
+	"sitemap":      {"/sitemap.json"},
+	"containing":   {"/containing/"},
+	"analyze":      {"/analyze-progression"},
+	"transpose":    {"/transpose/batch"},
+	"validate":     {"/validate"},
+	"parse":        {"/parse/"},
+	"autocomplete": {"/autocomplete/"},
+	"query":        {"/query"},
+	"admin":        {"/admin/unaliased-suffixes", "/admin/coverage", "/admin/snapshot", "/admin/verify-consistency"},
+}
+
+// disabledRoutes are the route patterns of the endpoints -enable leaves out
+var disabledRoutes = make(map[string]bool)
+
+// parseEnabledEndpoints disables the routes of every endpoint not in a comma-separated list of names, or none for "all"
+func parseEnabledEndpoints(value string) error {
+	if value == "all" {
+		return nil
+	}
+
+	enabled := make(map[string]bool)
+	for _, name := range splitList(value) {
+		if _, ok := endpoints[name]; !ok {
+			return fmt.Errorf("unknown endpoint %q", name)
+		}
+		enabled[name] = true
+	}
+	for name, patterns := range endpoints {
+		for _, pattern := range patterns {
+			disabledRoutes[pattern] = !enabled[name]
+		}
+	}
+	return nil
+}
+
+// disabledEndpoint answers requests to a route -enable left out, so they don't fall through to the catch-all route
+func disabledEndpoint(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, "Not found", http.StatusNotFound)
+}
+
+// handleRoute registers a handler on the mux and records the methods it supports.
+// Routes of disabled endpoints are registered with disabledEndpoint instead.
+func handleRoute(mux *http.ServeMux, pattern string, handler http.HandlerFunc, methods ...string) {
+	if disabledRoutes[pattern] {
+		handler = disabledEndpoint
+	}
+	routeMethods[pattern] = methods
+	mux.HandleFunc(pattern, checkParams(pattern, handler))
+}
+
+// checkParams rejects requests with query parameters the route doesn't accept when -strict-params is set
+func checkParams(pattern string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strictParams {
+			var unknown []string
+			for name := range r.URL.Query() {
+				if !slices.Contains(routeParams[pattern], name) {
+					unknown = append(unknown, name)
+				}
+			}
+			if len(unknown) > 0 {
+				sort.Strings(unknown)
+				writeError(w, r, "Unknown query parameters: "+strings.Join(unknown, ", "), http.StatusBadRequest)
+				return
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// allowedMethods returns the Access-Control-Allow-Methods value for a route pattern
+func allowedMethods(pattern string) string {
+	methods := append([]string{}, routeMethods[pattern]...)
+	methods = append(methods, "OPTIONS")
+	return strings.Join(methods, ", ")
+}
+
+func corsMiddleware(mux *http.ServeMux) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Find the route that will handle this request
+		_, pattern := mux.Handler(r)
+
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", allowedMethods(pattern))
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
 			w.WriteHeader(http.StatusOK)
 			return
 		}
 
 		// Call the next handler
-		next.ServeHTTP(w, r)
+		mux.ServeHTTP(w, r)
 	})
 }
 
 var db *sql.DB
 
+// embeddedFS holds a database built into the binary with
+// `go run build_db.go -output=embedded/chords.db`, if there is one
+//
+//go:embed all:embedded
+var embeddedFS embed.FS
+
+// schemaVersion is the database schema version this server expects, as written by build_db.go
+const schemaVersion = 3
+
+// allowEmptyPositions serves chords without positions (with a warning) instead of skipping them
+var allowEmptyPositions bool
+
+// keepDuplicatePositions serves positions listed more than once in a chord instead of merging them at load
+var keepDuplicatePositions bool
+
+// defaultTuning is the tuning assumed for chords that don't specify one
+const defaultTuning = "standard"
+
+// defaultInstrument is the instrument assumed for chords that don't specify one
+const defaultInstrument = "guitar"
+
 // ChordWithMeta extends ChordData with additional metadata for search optimization
 type ChordWithMeta struct {
 	Key              string        `json:"key"`
 	Suffix           string        `json:"suffix"`
 	Positions        []interface{} `json:"positions"`
+	Instrument       string        `json:"instrument"`
+	Tuning           string
 	NormalizedKey    string
 	NormalizedSuffix string
-	FullData         string // The original JSON string
+	Barres           [][]int `json:"-"` // Barre frets of each position
+	FullData         string  // The original JSON string
 }
 
 // In-memory data structures
 var chordCache []*ChordWithMeta
-var chordMap map[string]*ChordWithMeta        // For direct lookups by key+suffix
+var chordMap map[string]*ChordWithMeta        // For direct lookups by key+suffix+tuning
 var fingeringMap map[string][]*ChordWithMeta  // For lookups by fingering pattern
 var normalizedMap map[string][]*ChordWithMeta // For lookups by normalized key+suffix
+var aliasMap map[string]*ChordWithMeta        // For lookups by normalized key+alias suffix+tuning
+var browseOrder []*ChordWithMeta              // chordCache in canonical order for prev/next browsing
+var browseIndex map[*ChordWithMeta]int        // Position of each chord in browseOrder
+var sitemap []sitemapEntry                    // Canonical name and URL of every resolvable chord
+var nameIndex []nameEntry                     // Canonical name of every resolvable chord, sorted by name
+var pitchClassMap map[int][]*ChordWithMeta    // Chords containing each pitch class (0-11), in browse order
+
+// emptyIsOK makes searches with no matches return an empty array instead of 404
+var emptyIsOK bool
+
+// emptyResultsOK reports whether a request with no matches should get an empty array instead of 404
+func emptyResultsOK(r *http.Request) bool {
+	return emptyIsOK || r.URL.Query().Get("empty_ok") == "true"
+}
+
+// browseWrap makes prev/next wrap around at the ends of the chord list instead of returning 404
+var browseWrap bool
+
+// warmCache holds chord lookups resolved at startup by warmChords. It is rebuilt on reload.
+var warmCache = make(map[string]resolution)
+
+// dataMu guards the in-memory chord data. Handlers run under the read lock so a reload,
+// which holds the write lock, swaps the data in atomically.
+var dataMu sync.RWMutex
+
+// maxRequestBodyBytes caps the request bodies the POST routes read into memory
+const maxRequestBodyBytes = 1 << 20
+
+// dataLockMiddleware runs each handler under the data read lock. The lock is never held while talking to the
+// client: a POST route's request body is read before it is taken, and the response is buffered and sent once it
+// is released. Otherwise a slow client would keep a reload waiting for the write lock, and every request after it
+// waiting too.
+func dataLockMiddleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if r.Method == "POST" && slices.Contains(routeMethods[pattern], "POST") && r.Body != nil && r.Body != http.NoBody {
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeError(w, r, fmt.Sprintf("Request body exceeds %d bytes", maxRequestBodyBytes), http.StatusRequestEntityTooLarge)
+					return
+				}
+				writeError(w, r, "Error reading request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		buffered := &bufferedResponse{ResponseWriter: w}
+		func() {
+			dataMu.RLock()
+			defer dataMu.RUnlock()
+			next.ServeHTTP(buffered, r)
+		}()
+		buffered.send()
+	})
+}
+
+// bufferedResponse holds a response back until it is sent, remembering where the handler flushed so streamed
+// responses such as NDJSON still reach the client in the same pieces. Headers go straight to the wrapped writer.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status  int
+	body    bytes.Buffer
+	flushes []int // Length of the body at each flush
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) Flush() {
+	b.flushes = append(b.flushes, b.body.Len())
+}
+
+// send writes the buffered response to the client, flushing wherever the handler did
+func (b *bufferedResponse) send() {
+	if b.status == 0 {
+		return
+	}
+	b.ResponseWriter.WriteHeader(b.status)
+
+	controller := http.NewResponseController(b.ResponseWriter)
+	data, start := b.body.Bytes(), 0
+	for _, end := range b.flushes {
+		if _, err := b.ResponseWriter.Write(data[start:end]); err != nil {
+			return
+		}
+		controller.Flush()
+		start = end
+	}
+	b.ResponseWriter.Write(data[start:])
+}
+
+// dataSnapshot is the in-memory chord data, saved so a failed reload can restore it
+type dataSnapshot struct {
+	db            *sql.DB
+	chordCache    []*ChordWithMeta
+	chordMap      map[string]*ChordWithMeta
+	fingeringMap  map[string][]*ChordWithMeta
+	normalizedMap map[string][]*ChordWithMeta
+	aliasMap      map[string]*ChordWithMeta
+	browseOrder   []*ChordWithMeta
+	browseIndex   map[*ChordWithMeta]int
+	pitchClassMap map[int][]*ChordWithMeta
+	sitemap       []sitemapEntry
+	nameIndex     []nameEntry
+	warmCache     map[string]resolution
+}
+
+func saveData() dataSnapshot {
+	return dataSnapshot{db, chordCache, chordMap, fingeringMap, normalizedMap, aliasMap, browseOrder, browseIndex, pitchClassMap, sitemap, nameIndex, warmCache}
+}
+
+func restoreData(s dataSnapshot) {
+	db, chordCache, chordMap, fingeringMap, normalizedMap, aliasMap = s.db, s.chordCache, s.chordMap, s.fingeringMap, s.normalizedMap, s.aliasMap
+	browseOrder, browseIndex, pitchClassMap, sitemap, nameIndex, warmCache = s.browseOrder, s.browseIndex, s.pitchClassMap, s.sitemap, s.nameIndex, s.warmCache
+}
+
+// lastReload is when the data was last reloaded successfully, or zero if it never was, reported by /health
+var lastReload time.Time
+
+// lastReloadError is why the latest reload failed, or empty if it succeeded or there was none, reported by /health
+var lastReloadError string
+
+// reloadDatabase loads the chord data from the database at path and swaps it in for the current data.
+// On failure the current data is kept.
+func reloadDatabase(path string, warm bool, warmList string) error {
+	dataMu.Lock()
+	defer dataMu.Unlock()
+
+	newDB, err := sql.Open("sqlite3", path)
+	if err != nil {
+		lastReloadError = err.Error()
+		return err
+	}
+
+	old := saveData()
+	db = newDB
+	warmCache = make(map[string]resolution)
+
+	err = checkSchema()
+	if err == nil {
+		err = loadChordData()
+	}
+	if err == nil && warm {
+		err = warmChords(warmList)
+	}
+	if err != nil {
+		restoreData(old)
+		newDB.Close()
+		lastReloadError = err.Error()
+		return err
+	}
+
+	old.db.Close()
+	lastReload, lastReloadError = time.Now(), ""
+	return nil
+}
+
+// watchDatabase polls the modification time of the database at path and reloads it when it changes.
+// A change is only picked up once the modification time is the same on two polls in a row,
+// so a file that is still being written isn't loaded.
+func watchDatabase(path string, interval time.Duration, warm bool, warmList string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		slog.Error("Error watching database", "path", path, "err", err)
+		return
+	}
+	loaded, pending := info.ModTime(), time.Time{}
+
+	for range time.Tick(interval) {
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Error("Error watching database", "path", path, "err", err)
+			continue
+		}
+
+		modTime := info.ModTime()
+		if modTime.Equal(loaded) {
+			pending = time.Time{}
+			continue
+		}
+		if !modTime.Equal(pending) {
+			pending = modTime
+			continue
+		}
+
+		slog.Info("Database changed, reloading", "path", path)
+		if err := reloadDatabase(path, warm, warmList); err != nil {
+			slog.Error("Error reloading database, keeping the current data", "path", path, "err", err)
+		}
+
+		// A database that failed to load isn't retried until it changes again
+		loaded, pending = modTime, time.Time{}
+	}
+}
+
+// benchmarkChords are the chord names resolved by the startup benchmark, a mix of exact, normalized and alias lookups
+var benchmarkChords = []string{"C", "Am", "G7", "Dm7", "Bb", "F#m", "Ebmaj7", "Csus4", "Amin", "Gdom7"}
+
+// benchmarkRounds is how many times the startup benchmark resolves each chord
+const benchmarkRounds = 20
+
+// benchmarkLookups resolves a handful of chords and logs the average lookup latency along with the load time,
+// so operators can tell at a glance whether an instance is healthy and sized right. With -debug, the timing
+// of each chord is logged too.
+func benchmarkLookups(loadTime time.Duration) {
+	var total time.Duration
+	found := 0
+	for _, name := range benchmarkChords {
+		var chord *ChordWithMeta
+		start := time.Now()
+		for i := 0; i < benchmarkRounds; i++ {
+			chord = resolveChord(name)
+		}
+		elapsed := time.Since(start)
+		total += elapsed
+
+		if chord != nil {
+			found++
+		}
+		slog.Debug("Benchmark lookup", "chord", name, "per_lookup", elapsed/benchmarkRounds, "found", chord != nil)
+	}
+
+	average := total / time.Duration(len(benchmarkChords)*benchmarkRounds)
+	slog.Info("Startup complete", "load_time", loadTime.Round(time.Millisecond), "average_lookup", average,
+		"benchmark_found", found, "benchmark_chords", len(benchmarkChords))
+}
+
+// defaultWarmList is the list of common chords warmed when no -warm-list file is given
+var defaultWarmList = []string{
+	"C", "D", "E", "F", "G", "A", "B",
+	"Cm", "Dm", "Em", "Fm", "Gm", "Am", "Bm",
+	"C7", "D7", "E7", "F7", "G7", "A7", "B7",
+	"Cmaj7", "Dmaj7", "Fmaj7", "Gmaj7", "Amaj7",
+	"Am7", "Dm7", "Em7", "Bm7",
+	"Dsus2", "Dsus4", "Asus2", "Asus4", "Esus4",
+}
 
 // Map of enharmonic equivalents
 var enharmonicMap = map[string]string{
@@ -59,10 +634,25 @@ var enharmonicMap = map[string]string{
 	"E#": "F",
 }
 
+// enharmonicSpelling returns the other spelling of a key from enharmonicMap, in either direction, e.g. Bb for A#
+// and A# for Bb, or false if the key has no other spelling
+func enharmonicSpelling(key string) (string, bool) {
+	if alt, exists := enharmonicMap[strings.ToUpper(key)]; exists {
+		return alt, true
+	}
+	for spelling, alt := range enharmonicMap {
+		if alt == key {
+			return spelling[:1] + strings.ToLower(spelling[1:]), true
+		}
+	}
+	return "", false
+}
+
 // Map of suffix aliases
 var suffixAliasMap = map[string]string{
 	"M":      "major",
 	"MAJ":    "major",
+	"MAJOR":  "major",
 	"":       "major", // Empty suffix implies major
 	"m":      "minor",
 	"MIN":    "minor",
@@ -81,277 +671,4533 @@ var suffixAliasMap = map[string]string{
 	"M7":     "maj7",
 	"SUS2":   "sus2",
 	"SUS4":   "sus4",
+	// Flattened forms of parenthesized extensions such as m(maj7)
+	"mmaj7":   "mmaj7",
+	"mMaj7":   "mmaj7",
+	"mM7":     "mmaj7",
+	"MINMAJ7": "mmaj7",
+	"MIN7B5":  "m7b5",
+	// Spelled-out forms of the add family and sixth chords
+	"ADD9":  "add9",
+	"M6":    "6",
+	"MAJ6":  "6",
+	"ADD6":  "6",
+	"MIN6":  "m6",
+	"6ADD9": "69",
 }
 
-// normalizeKey normalizes a chord key for search
-func normalizeKey(key string) string {
-	key = strings.ToUpper(key)
-	if alt, exists := enharmonicMap[key]; exists {
-		return alt
-	}
-	return key
+// numberSuffixMap resolves bare-number suffixes and the add family, which lead sheets write
+// ambiguously. A bare 2 adds the second to the full triad (add9), while a bare 4 replaces the
+// third (sus4). build_db.go generates aliases from the same table. Keys are case-sensitive.
+var numberSuffixMap = map[string]string{
+	"2":     "add9",
+	"add2":  "add9",
+	"madd2": "madd9",
+	"4":     "sus4",
+	"6":     "6",
+	"m6":    "m6",
+	"69":    "69",
+	"6/9":   "69",
+	"m69":   "m69",
+	"m6/9":  "m69",
+	"9":     "9",
 }
 
-// normalizeSuffix normalizes a chord suffix for search
-func normalizeSuffix(suffix string) string {
-	suffix = strings.ToUpper(suffix)
-	if alt, exists := suffixAliasMap[suffix]; exists {
-		return alt
+// parseNumberSuffixes applies overrides like "2=sus2,4=sus4" to numberSuffixMap
+func parseNumberSuffixes(value string) error {
+	if value == "" {
+		return nil
 	}
-	return suffix
-}
 
-// healthcheck responds with a 200 status code for health monitoring
-func healthcheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
+	for _, entry := range strings.Split(value, ",") {
+		suffix, target, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || suffix == "" || target == "" {
+			return fmt.Errorf("invalid mapping %q, expected suffix=target", entry)
+		}
+		numberSuffixMap[suffix] = target
+	}
+	return nil
 }
 
-func main() {
-	// Parse command line flags
-	port := flag.Int("port", 80, "Port to run the server on")
-	flag.Parse()
+// noteNames lists the pitch classes in the sharp spelling used by normalized keys
+var noteNames = []string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
 
-	var err error
-	db, err = sql.Open("sqlite3", "chords.db")
-	if err != nil {
-		log.Fatalf("Error opening database: %v", err)
-	}
-	defer db.Close()
+// keySpellings are the English spellings of each pitch class in computed key and chord names, by mode. The
+// defaults follow the key signatures: major keys are spelled with the fewest accidentals, so Eb rather than D#,
+// and minor keys the same way, so G#m rather than Abm. F# major and D# minor have as many flats as sharps, and
+// are spelled with sharps and flats respectively. Overridden by -key-spellings.
+var keySpellings = map[string][]string{
+	"major": {"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"},
+	"minor": {"C", "C#", "D", "Eb", "E", "F", "F#", "G", "G#", "A", "Bb", "B"},
+}
 
-	// Load all chord data into memory
-	if err := loadChordData(); err != nil {
-		log.Fatalf("Error loading chord data: %v", err)
+// parseKeySpellings applies overrides like "major:Gb,minor:D#" to keySpellings, each replacing the spelling
+// of the note's pitch class in that mode
+func parseKeySpellings(value string) error {
+	if value == "" {
+		return nil
 	}
 
-	// Create a new mux
-	mux := http.NewServeMux()
-
-	// Route handlers
-	mux.HandleFunc("/chords/", getChordByName)
-	mux.HandleFunc("/fingers/", getChordsByFingering)
-	mux.HandleFunc("/search/", searchChords)
-	mux.HandleFunc("/healthcheck", healthcheck)
-	mux.HandleFunc("/", healthcheck)
+	for _, entry := range strings.Split(value, ",") {
+		mode, note, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		spellings, known := keySpellings[mode]
+		if !ok || !known {
+			return fmt.Errorf("invalid spelling %q, expected major:note or minor:note", entry)
+		}
+		if len(note) == 0 || len(note) > 2 || !strings.Contains(letterNames, note[:1]) || (len(note) == 2 && note[1] != '#' && note[1] != 'b') {
+			return fmt.Errorf("invalid note %q in %q", note, entry)
+		}
+		// Cb and Fb are valid note names but not spellings of a pitch class noteIndex knows
+		pitchClass := noteIndex(note)
+		if pitchClass < 0 {
+			return fmt.Errorf("invalid note %q in %q", note, entry)
+		}
+		spellings[pitchClass] = note
+	}
+	return nil
+}
 
-	// Apply CORS middleware
-	handler := corsMiddleware(mux)
+// spellingMode returns the mode whose spellings suit a chord quality: minor for minor and diminished chords,
+// whose roots are spelled like the tonic of a minor key, and major otherwise
+func spellingMode(quality string) string {
+	if quality == "minor" || quality == "diminished" {
+		return "minor"
+	}
+	return "major"
+}
 
-	// Start server
-	addr := fmt.Sprintf(":%d", *port)
-	fmt.Printf("Server running on http://localhost%s\n", addr)
-	log.Fatal(http.ListenAndServe(addr, handler))
+// spellKey names a pitch class as the tonic of a key in a mode. English names come from keySpellings,
+// while other languages keep their own names.
+func spellKey(names []string, pitchClass int, mode string) string {
+	if names[pitchClass] != noteNames[pitchClass] {
+		return names[pitchClass]
+	}
+	return keySpellings[spellingMode(mode)][pitchClass]
 }
 
-// loadChordData loads all chord data from the database into memory
-func loadChordData() error {
-	// Initialize the data structures
-	chordCache = make([]*ChordWithMeta, 0)
-	chordMap = make(map[string]*ChordWithMeta)
-	fingeringMap = make(map[string][]*ChordWithMeta)
-	normalizedMap = make(map[string][]*ChordWithMeta)
+// solfegeNoteNames are the fixed-do note names used in Romance languages
+var solfegeNoteNames = []string{"Do", "Do#", "Re", "Re#", "Mi", "Fa", "Fa#", "Sol", "Sol#", "La", "La#", "Si"}
+
+// localizedNoteNames maps language subtags to the note names used for computed output, indexed by pitch class.
+// German names B flat "B" and B natural "H".
+var localizedNoteNames = map[string][]string{
+	"en": noteNames,
+	"de": {"C", "Cis", "D", "Dis", "E", "F", "Fis", "G", "Gis", "A", "B", "H"},
+	"fr": solfegeNoteNames,
+	"es": solfegeNoteNames,
+	"it": solfegeNoteNames,
+	"pt": solfegeNoteNames,
+}
 
-	// Query all chords from the database
-	rows, err := db.Query(`SELECT id, key, suffix, full_data FROM chords`)
-	if err != nil {
-		return err
+// requestNoteNames returns the note names for a request's ?lang= parameter, or else its Accept-Language header.
+// Unsupported Accept-Language values fall back to English; an unsupported ?lang= is an error.
+func requestNoteNames(r *http.Request) ([]string, error) {
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if names, ok := localizedNoteNames[languageSubtag(lang)]; ok {
+			return names, nil
+		}
+		return nil, fmt.Errorf("unsupported language: %s", lang)
 	}
-	defer rows.Close()
 
-	// Process each chord
-	for rows.Next() {
-		var id int
-		var key, suffix, fullData string
-		if err := rows.Scan(&id, &key, &suffix, &fullData); err != nil {
-			return err
+	// Pick the supported language with the highest quality, earliest first on ties
+	names, best := noteNames, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, quality := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = part[:i]
+			if q, ok := strings.CutPrefix(strings.TrimSpace(part[i+1:]), "q="); ok {
+				parsed, err := strconv.ParseFloat(q, 64)
+				if err != nil {
+					continue
+				}
+				quality = parsed
+			}
 		}
 
-		// Parse the full JSON data directly into a ChordWithMeta
-		chord := &ChordWithMeta{}
-		if err := json.Unmarshal([]byte(fullData), chord); err != nil {
-			return err
+		if candidate, ok := localizedNoteNames[languageSubtag(tag)]; ok && quality > best {
+			names, best = candidate, quality
 		}
+	}
+	return names, nil
+}
 
-		// Add the additional metadata
-		chord.NormalizedKey = normalizeKey(key)
-		chord.NormalizedSuffix = normalizeSuffix(suffix)
-		chord.FullData = fullData
+// languageSubtag returns the primary subtag of a language tag, e.g. "de" for "de-AT"
+func languageSubtag(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_"); i >= 0 {
+		tag = tag[:i]
+	}
+	return tag
+}
 
-		// Add to cache and maps
-		chordCache = append(chordCache, chord)
-		chordMap[key+"|"+suffix] = chord
+// noteIndex returns the pitch class (0-11) of a key, or -1 if it isn't a valid key
+func noteIndex(key string) int {
+	normalized := normalizeKey(key)
+	for i, name := range noteNames {
+		if name == normalized {
+			return i
+		}
+	}
+	return -1
+}
+
+// transposeKey shifts a key by a number of semitones and returns it in sharp spelling
+func transposeKey(key string, semitones int) (string, bool) {
+	i := noteIndex(key)
+	if i < 0 {
+		return "", false
+	}
+	return noteNames[((i+semitones)%12+12)%12], true
+}
+
+// transposeChord finds the chord with the same suffix as the given chord, transposed by a number of semitones.
+// The bass note of a slash chord is transposed along with the root.
+func transposeChord(chord *ChordWithMeta, semitones int) *ChordWithMeta {
+	key, ok := transposeKey(chord.NormalizedKey, semitones)
+	if !ok {
+		return nil
+	}
+
+	suffix := chord.NormalizedSuffix
+	if i := strings.Index(suffix, "/"); i >= 0 {
+		bass, ok := transposeKey(suffix[i+1:], semitones)
+		if !ok {
+			return nil
+		}
+		suffix = suffix[:i+1] + bass
+	}
+
+	return normalizedChord(key+"|"+suffix, chord.Tuning)
+}
+
+// normalizedChord returns the first chord stored under a normalized key+suffix in the given tuning, or nil.
+// normalizedMap holds every tuning, so lookups that return a single chord go through here.
+func normalizedChord(normalized, tuning string) *ChordWithMeta {
+	for _, chord := range normalizedMap[normalized] {
+		if chord.Tuning == tuning {
+			return chord
+		}
+	}
+	return nil
+}
+
+// neckFrets is the highest fret a derived shape may reach
+const neckFrets = 24
+
+// deriveChord builds a chord that isn't stored from a movable shape of the same chord type stored under
+// another key, slid along the neck by the interval between the keys. The stored chord closest in pitch is used,
+// and the result is flagged "derived". It returns nil if no stored chord has a shape that fits on the neck.
+func deriveChord(key, suffix string) (*ChordWithMeta, error) {
+	// A slash chord's bass would have to move with the shape, which the stored suffix can't express
+	target := noteIndex(key)
+	if target < 0 || strings.Contains(suffix, "/") {
+		return nil, nil
+	}
+	normalizedSuffix := normalizeSuffix(suffix)
+
+	var source *ChordWithMeta
+	var positions []interface{}
+	var barres [][]int
+	bestDistance := 12
+	for _, chord := range chordCache {
+		root := noteIndex(chord.NormalizedKey)
+		if root < 0 || chord.NormalizedSuffix != normalizedSuffix || chord.Tuning != defaultTuning {
+			continue
+		}
+
+		interval := ((target-root)%12 + 12) % 12
+		distance := min(interval, 12-interval)
+		if distance == 0 || distance >= bestDistance {
+			continue
+		}
+		if shifted, shiftedBarres := shiftPositions(chord, interval); len(shifted) > 0 {
+			source, positions, barres, bestDistance = chord, shifted, shiftedBarres, distance
+		}
+	}
+	if source == nil {
+		return nil, nil
+	}
+
+	derivedKey := normalizeKey(key)
+	data, err := withFields(source.FullData, map[string]interface{}{"key": derivedKey, "positions": positions, "derived": true})
+	if err != nil {
+		return nil, err
+	}
+	derived := *source
+	derived.Key = derivedKey
+	derived.NormalizedKey = derivedKey
+	derived.Positions = positions
+	derived.Barres = barres
+	derived.FullData = data
+	return &derived, nil
+}
+
+// shiftPositions slides a chord's movable positions up the neck by interval semitones, or down by the octave
+// complement if that's a shorter move or the only one that fits. A position is movable if every played string
+// is fretted and it doesn't need a capo. Positions that can't move are left out, and the shifted positions
+// get a baseFret at their lowest fret.
+func shiftPositions(chord *ChordWithMeta, interval int) ([]interface{}, [][]int) {
+	shifts := []int{interval, interval - 12}
+	if interval > 6 {
+		shifts[0], shifts[1] = shifts[1], shifts[0]
+	}
+
+	var positions []interface{}
+	var barres [][]int
+	for i, position := range chord.Positions {
+		shape, ok := shapeOf(position)
+		if !ok || shape.open > 0 || shape.fretted == 0 || shape.capo {
+			continue
+		}
+		posMap := position.(map[string]interface{})
+
+		for _, shift := range shifts {
+			if shape.lowest+shift < 1 || shape.highest+shift > neckFrets {
+				continue
+			}
+
+			frets, _ := posMap["frets"].(string)
+			parsed := parseFrets(frets)
+			for j, fret := range parsed {
+				if fret > 0 {
+					parsed[j] = fret + shift
+				}
+			}
+
+			shifted := make(map[string]interface{}, len(posMap)+1)
+			for name, value := range posMap {
+				shifted[name] = value
+			}
+			shifted["frets"] = formatFrets(parsed, strings.Contains(frets, fretSeparator))
+			shifted["baseFret"] = shape.lowest + shift
+
+			var shiftedBarres []int
+			if i < len(chord.Barres) && len(chord.Barres[i]) > 0 {
+				names := make([]string, len(chord.Barres[i]))
+				for j, barre := range chord.Barres[i] {
+					shiftedBarres = append(shiftedBarres, barre+shift)
+					names[j] = strconv.Itoa(barre + shift)
+				}
+				shifted["barres"] = strings.Join(names, ",")
+			}
+
+			positions = append(positions, shifted)
+			barres = append(barres, shiftedBarres)
+			break
+		}
+	}
+	return positions, barres
+}
+
+// normalizeKey normalizes a chord key for search
+func normalizeKey(key string) string {
+	key = strings.ToUpper(key)
+	if alt, exists := enharmonicMap[key]; exists {
+		return alt
+	}
+	return key
+}
+
+// normalizeSuffix normalizes a chord suffix for search
+func normalizeSuffix(suffix string) string {
+	// Bare numbers are checked before slash chords so 6/9 isn't read as a 6 over a 9 bass
+	if alt, exists := numberSuffixMap[suffix]; exists {
+		return alt
+	}
+
+	// Slash chords normalize the chord quality and the bass note separately
+	if i := strings.Index(suffix, "/"); i >= 0 {
+		return normalizeSuffix(suffix[:i]) + "/" + normalizeKey(suffix[i+1:])
+	}
+
+	// Check case-sensitive aliases first so "m" (minor) isn't mistaken for "M" (major)
+	if alt, exists := suffixAliasMap[suffix]; exists {
+		return alt
+	}
+
+	suffix = strings.ToUpper(suffix)
+	if alt, exists := suffixAliasMap[suffix]; exists {
+		return alt
+	}
+	return suffix
+}
+
+// healthcheck responds with a 200 status code for health monitoring
+func healthcheck(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// health reports that the server is up as JSON, with the number of chords served and the outcome of -watch reloads
+func health(w http.ResponseWriter, r *http.Request) {
+	response := struct {
+		Status          string `json:"status"`
+		Chords          int    `json:"chords"`
+		LastReload      string `json:"last_reload,omitempty"`
+		LastReloadError string `json:"last_reload_error,omitempty"`
+	}{Status: "ok", Chords: len(chordCache), LastReloadError: lastReloadError}
+	if !lastReload.IsZero() {
+		response.LastReload = lastReload.UTC().Format(time.RFC3339)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, string(data))
+}
+
+// readinessChord is the canary chord /readyz resolves to check that lookups work end to end
+var readinessChord string
+
+// readyz reports whether the server can serve chords: the data is loaded, the database answers,
+// and the canary chord resolves and renders through the same path as /chords/
+func readyz(w http.ResponseWriter, r *http.Request) {
+	if len(chordCache) == 0 {
+		writeError(w, r, "Not ready: no chords loaded", http.StatusServiceUnavailable)
+		return
+	}
+	if err := db.PingContext(r.Context()); err != nil {
+		writeError(w, r, "Not ready: database unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	chord := resolveChord(readinessChord)
+	if chord == nil {
+		writeError(w, r, fmt.Sprintf("Not ready: canary chord %s did not resolve", readinessChord), http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := renderChord(chord, r); err != nil {
+		writeError(w, r, fmt.Sprintf("Not ready: canary chord %s failed to render: %v", readinessChord, err), http.StatusServiceUnavailable)
+		return
+	}
+
+	writeBody(w, "ok\n")
+}
+
+func main() {
+	// Parse command line flags
+	port := flag.Int("port", 80, "Port to run the server on")
+	dbPath := flag.String("db", "", "Path to the SQLite database (defaults to the embedded database, or chords.db)")
+	resolveOrderValue := flag.String("resolve-order", "exact,normalized,alias,fuzzy", "Comma-separated order of chord resolution strategies")
+	warm := flag.Bool("warm", false, "Resolve common chord lookups at startup")
+	warmList := flag.String("warm-list", "", "File with chord names to warm, one per line (defaults to a built-in list)")
+	flag.StringVar(&errorFormat, "error-format", "text", "Format of error responses: text or json")
+	flag.IntVar(&corsMaxAge, "cors-max-age", 600, "Seconds browsers may cache CORS preflight responses")
+	flag.BoolVar(&allowEmptyPositions, "allow-empty-positions", false, "Serve chords with no positions with a warning instead of skipping them")
+	flag.BoolVar(&keepDuplicatePositions, "keep-duplicate-positions", false, "Serve positions listed more than once in a chord instead of merging them")
+	debug := flag.Bool("debug", false, "Log diagnostic details, such as how each search query was interpreted (short for -log-level=debug)")
+	logLevelValue := flag.String("log-level", "info", "Least severe level logged: debug, info, warn or error")
+	logOutput := flag.String("log-output", "stderr", "Where logs go: stdout, stderr or the path of a file to append to")
+	flag.BoolVar(&emptyIsOK, "empty-is-ok", false, "Return 200 with an empty array instead of 404 when a search has no matches")
+	flag.BoolVar(&browseWrap, "browse-wrap", false, "Wrap around at the ends of the chord list when browsing with prev/next")
+	flag.IntVar(&searchLimit, "search-limit", 5, "Most chords returned by /search/")
+	flag.IntVar(&fingersLimit, "fingers-limit", 50, "Most chords returned by /fingers/")
+	flag.IntVar(&similarLimit, "similar-limit", 10, "Most chords returned by /chords/{name}/similar")
+	flag.IntVar(&allLimit, "all-limit", 20, "Most chords that can be requested at once in a comma-separated /chords/ list")
+	flag.IntVar(&maxResponseBytes, "max-response-bytes", 0, "Truncate chord arrays from /search/, /fingers/ and /chords/ lists to about this many bytes (0 for no limit)")
+	flag.StringVar(&readinessChord, "readiness-chord", "C", "Chord /readyz resolves to check that lookups work")
+	flag.BoolVar(&strictParams, "strict-params", false, "Reject requests with query parameters their endpoint doesn't accept")
+	flag.StringVar(&adminToken, "admin-token", "", "Bearer token for admin-gated endpoints such as /query (disabled if empty)")
+	keySpellingList := flag.String("key-spellings", "", "Overrides for how computed key names are spelled in each mode, e.g. major:Gb,minor:D#")
+	numberSuffixes := flag.String("number-suffixes", "", "Overrides for how bare-number suffixes resolve, e.g. 2=sus2 (pass the same value to build_db)")
+	coverageList := flag.String("coverage-suffixes", "", "Comma-separated suffixes the coverage report expects for every root (defaults to a built-in list)")
+	watch := flag.Duration("watch", 0, "Poll the database file at this interval and reload it when it changes, e.g. 30s (requires -db)")
+	snapshotPath := flag.String("snapshot", "", "Load the chord store from a snapshot file exported by /admin/snapshot instead of a database")
+	enable := flag.String("enable", "all", "Comma-separated endpoints to serve, e.g. chords,search, or all")
+	configPath := flag.String("config", "", "JSON file of flag values, keyed by flag name (flags and CHORDSERVER_* environment variables override it)")
+	flag.Parse()
+
+	if err := applyConfig(*configPath); err != nil {
+		fatal("Invalid configuration", "err", err)
+	}
+
+	if err := logLevel.UnmarshalText([]byte(*logLevelValue)); err != nil {
+		fatal("Invalid -log-level: must be debug, info, warn or error", "value", *logLevelValue)
+	}
+	if *debug {
+		logLevel.Set(slog.LevelDebug)
+	}
+	if err := setupLogging(*logOutput); err != nil {
+		fatal("Invalid -log-output", "err", err)
+	}
+
+	if errorFormat != "text" && errorFormat != "json" {
+		fatal("Invalid -error-format: must be text or json", "value", errorFormat)
+	}
+
+	if searchLimit < 1 || fingersLimit < 1 || similarLimit < 1 || allLimit < 1 {
+		fatal("Invalid result limit: -search-limit, -fingers-limit, -similar-limit and -all-limit must be at least 1")
+	}
+	if maxResponseBytes < 0 {
+		fatal("Invalid -max-response-bytes: can't be negative")
+	}
+
+	var err error
+	resolveOrder, err = parseResolveOrder(*resolveOrderValue)
+	if err != nil {
+		fatal("Invalid -resolve-order", "err", err)
+	}
+
+	if err := parseNumberSuffixes(*numberSuffixes); err != nil {
+		fatal("Invalid -number-suffixes", "err", err)
+	}
+
+	if err := parseKeySpellings(*keySpellingList); err != nil {
+		fatal("Invalid -key-spellings", "err", err)
+	}
+
+	if err := parseEnabledEndpoints(*enable); err != nil {
+		fatal("Invalid -enable", "err", err)
+	}
+
+	if *coverageList != "" {
+		coverageSuffixes = splitList(*coverageList)
+	}
+
+	// The embedded database never changes, so there is nothing to watch
+	if *watch < 0 || (*watch > 0 && *dbPath == "") {
+		fatal("Invalid -watch: must be a positive interval and requires -db")
+	}
+
+	if *snapshotPath != "" && *dbPath != "" {
+		fatal("Invalid -snapshot: can't be combined with -db")
+	}
+
+	loadStart := time.Now()
+
+	// Prefer a snapshot, then an external database, then the embedded one
+	path := *dbPath
+	if *snapshotPath != "" {
+		db, err = openSnapshot(*snapshotPath)
+		if err != nil {
+			fatal("Error loading snapshot", "err", err)
+		}
+		slog.Info("Using snapshot", "path", *snapshotPath)
+	} else if path == "" {
+		path = "chords.db"
+		if data, err := embeddedFS.ReadFile("embedded/chords.db"); err == nil {
+			path, err = writeTempDB(data)
+			if err != nil {
+				fatal("Error extracting embedded database", "err", err)
+			}
+			defer os.Remove(path)
+			slog.Info("Using embedded database")
+		}
+	}
+
+	if db == nil {
+		db, err = sql.Open("sqlite3", path)
+		if err != nil {
+			fatal("Error opening database", "err", err)
+		}
+	}
+	defer db.Close()
+
+	// Fail fast on databases built by an older build_db
+	if err := checkSchema(); err != nil {
+		fatal("Database schema outdated, rebuild with build_db", "err", err)
+	}
+
+	// Load all chord data into memory
+	if err := loadChordData(); err != nil {
+		fatal("Error loading chord data", "err", err)
+	}
+
+	// Pre-resolve popular chords so first requests don't pay for resolution
+	if *warm {
+		if err := warmChords(*warmList); err != nil {
+			fatal("Error warming cache", "err", err)
+		}
+	}
+
+	benchmarkLookups(time.Since(loadStart))
+
+	// Create a new mux
+	mux := http.NewServeMux()
+
+	// Route handlers
+	handleRoute(mux, "/chords", getChordByParts, "GET")
+	handleRoute(mux, "/chords/", getChordByName, "GET")
+	handleRoute(mux, "/chords/daily", getDailyChord, "GET")
+	handleRoute(mux, "/fingers/", getChordsByFingering, "GET")
+	handleRoute(mux, "/search/", searchChords, "GET")
+	handleRoute(mux, "/parse/", parseChordName, "GET")
+	handleRoute(mux, "/autocomplete/", getAutocomplete, "GET")
+	handleRoute(mux, "/sitemap.json", getSitemap, "GET")
+	handleRoute(mux, "/containing/", getChordsContaining, "GET")
+	handleRoute(mux, "/analyze-progression", analyzeProgression, "POST")
+	handleRoute(mux, "/transpose/batch", transposeBatch, "POST")
+	handleRoute(mux, "/validate", validateChord, "POST")
+	handleRoute(mux, "/query", requireAdmin(queryTable), "POST")
+	handleRoute(mux, "/admin/unaliased-suffixes", requireAdmin(getUnaliasedSuffixes), "GET")
+	handleRoute(mux, "/admin/coverage", requireAdmin(getCoverage), "GET")
+	handleRoute(mux, "/admin/snapshot", requireAdmin(getSnapshot), "GET")
+	handleRoute(mux, "/admin/verify-consistency", requireAdmin(verifyConsistency), "GET")
+	handleRoute(mux, "/healthcheck", healthcheck, "GET")
+	handleRoute(mux, "/health", health, "GET")
+	handleRoute(mux, "/readyz", readyz, "GET")
+	handleRoute(mux, "/", healthcheck, "GET")
+
+	// Reload the database when it's replaced on disk
+	if *watch > 0 {
+		go watchDatabase(path, *watch, *warm, *warmList)
+	}
+
+	// Apply CORS and request ID middleware, and hold the data lock so reloads don't race requests
+	handler := dataLockMiddleware(mux, requestIDMiddleware(corsMiddleware(mux)))
+
+	// Start server
+	addr := fmt.Sprintf(":%d", *port)
+	slog.Info("Server running", "url", "http://localhost"+addr)
+	fatal("Server stopped", "err", http.ListenAndServe(addr, handler))
+}
+
+// envPrefix prefixes the environment variables that override flags, e.g. CHORDSERVER_CORS_MAX_AGE for -cors-max-age
+const envPrefix = "CHORDSERVER_"
+
+// applyConfig sets flags from a JSON config file and then from the environment, so the precedence is
+// defaults < file < flags < environment. Unknown keys in the file are an error.
+func applyConfig(path string) error {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(data, &values); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+
+		// Apply in name order so errors are reported consistently
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if flag.Lookup(name) == nil || name == "config" {
+				return fmt.Errorf("%s: unknown key %q", path, name)
+			}
+			if explicit[name] {
+				continue
+			}
+
+			// Strings are used as is, numbers and booleans by their literal text
+			raw := values[name]
+			value := string(raw)
+			if raw[0] == '"' {
+				json.Unmarshal(raw, &value)
+			} else if raw[0] == '{' || raw[0] == '[' || value == "null" {
+				return fmt.Errorf("%s: %s must be a string, number or boolean", path, name)
+			}
+			if err := flag.Set(name, value); err != nil {
+				return fmt.Errorf("%s: invalid %s: %v", path, name, err)
+			}
+		}
+	}
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		if value, ok := os.LookupEnv(name); ok && err == nil && f.Name != "config" {
+			if setErr := flag.Set(f.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %v", name, setErr)
+			}
+		}
+	})
+	return err
+}
+
+// writeTempDB copies an embedded database to a temporary file so SQLite can open it
+func writeTempDB(data []byte) (string, error) {
+	f, err := os.CreateTemp("", "chords-*.db")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+// snapshotFormat and snapshotVersion identify the snapshot files written by /admin/snapshot.
+// Bump snapshotVersion whenever the snapshot layout changes.
+const (
+	snapshotFormat  = "chordserver-snapshot"
+	snapshotVersion = 1
+)
+
+// storeSnapshot is a complete, standalone copy of the chord store that can be loaded with -snapshot
+type storeSnapshot struct {
+	Format        string               `json:"format"`
+	Version       int                  `json:"version"`
+	SchemaVersion int                  `json:"schema_version"`
+	Created       time.Time            `json:"created"`
+	Chords        []snapshotChord      `json:"chords"`
+	Fingerings    []snapshotFingering  `json:"fingerings"`
+	Aliases       []snapshotChordAlias `json:"aliases"`
+}
+
+type snapshotChord struct {
+	ID     int             `json:"id"`
+	Key    string          `json:"key"`
+	Suffix string          `json:"suffix"`
+	Tuning string          `json:"tuning"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type snapshotFingering struct {
+	ChordID int     `json:"chord_id"`
+	Frets   string  `json:"frets"`
+	Fingers *string `json:"fingers"`
+	Barres  *string `json:"barres"`
+	Capo    *string `json:"capo"`
+}
+
+type snapshotChordAlias struct {
+	ChordID int    `json:"chord_id"`
+	Key     string `json:"key"`
+	Suffix  string `json:"suffix"`
+	Tuning  string `json:"tuning"`
+}
+
+// snapshotSchema recreates the tables written by build_db.go, so a snapshot can be loaded without a database file
+const snapshotSchema = `
+	CREATE TABLE chords (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		key TEXT NOT NULL,
+		suffix TEXT NOT NULL,
+		tuning TEXT NOT NULL DEFAULT 'standard',
+		full_data TEXT NOT NULL,
+		UNIQUE(key, suffix, tuning)
+	);
+	CREATE TABLE fingerings (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chord_id INTEGER NOT NULL,
+		frets TEXT NOT NULL,
+		fingers TEXT,
+		barres TEXT,
+		capo TEXT,
+		FOREIGN KEY(chord_id) REFERENCES chords(id)
+	);
+	CREATE TABLE chord_aliases (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		chord_id INTEGER NOT NULL,
+		alias_key TEXT NOT NULL,
+		alias_suffix TEXT NOT NULL,
+		alias_tuning TEXT NOT NULL DEFAULT 'standard',
+		UNIQUE(alias_key, alias_suffix, alias_tuning),
+		FOREIGN KEY(chord_id) REFERENCES chords(id)
+	);
+	CREATE TABLE schema_version (
+		version INTEGER NOT NULL
+	);
+	CREATE INDEX idx_chords_key_suffix ON chords(key, suffix);
+	CREATE INDEX idx_fingerings_frets ON fingerings(frets);
+	CREATE INDEX idx_fingerings_chord_id ON fingerings(chord_id);
+	CREATE INDEX idx_aliases_key_suffix ON chord_aliases(alias_key, alias_suffix);
+`
+
+// exportSnapshot reads every chord, fingering and alias from the database into a snapshot
+func exportSnapshot() (*storeSnapshot, error) {
+	snapshot := &storeSnapshot{
+		Format:        snapshotFormat,
+		Version:       snapshotVersion,
+		SchemaVersion: schemaVersion,
+		Created:       time.Now().UTC(),
+		Chords:        []snapshotChord{},
+		Fingerings:    []snapshotFingering{},
+		Aliases:       []snapshotChordAlias{},
+	}
+
+	rows, err := db.Query(`SELECT id, key, suffix, tuning, full_data FROM chords ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var chord snapshotChord
+		var fullData string
+		if err := rows.Scan(&chord.ID, &chord.Key, &chord.Suffix, &chord.Tuning, &fullData); err != nil {
+			return nil, err
+		}
+		chord.Data = json.RawMessage(fullData)
+		snapshot.Chords = append(snapshot.Chords, chord)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fingeringRows, err := db.Query(`SELECT chord_id, frets, fingers, barres, capo FROM fingerings ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer fingeringRows.Close()
+	for fingeringRows.Next() {
+		var f snapshotFingering
+		if err := fingeringRows.Scan(&f.ChordID, &f.Frets, &f.Fingers, &f.Barres, &f.Capo); err != nil {
+			return nil, err
+		}
+		snapshot.Fingerings = append(snapshot.Fingerings, f)
+	}
+	if err := fingeringRows.Err(); err != nil {
+		return nil, err
+	}
+
+	aliasRows, err := db.Query(`SELECT chord_id, alias_key, alias_suffix, alias_tuning FROM chord_aliases ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer aliasRows.Close()
+	for aliasRows.Next() {
+		var a snapshotChordAlias
+		if err := aliasRows.Scan(&a.ChordID, &a.Key, &a.Suffix, &a.Tuning); err != nil {
+			return nil, err
+		}
+		snapshot.Aliases = append(snapshot.Aliases, a)
+	}
+	return snapshot, aliasRows.Err()
+}
+
+// validate checks the snapshot header and that every fingering and alias belongs to a chord in the snapshot
+func (s *storeSnapshot) validate() error {
+	if s.Format != snapshotFormat {
+		return fmt.Errorf("not a chordserver snapshot (format %q)", s.Format)
+	}
+	if s.Version != snapshotVersion {
+		return fmt.Errorf("snapshot version is %d, expected %d", s.Version, snapshotVersion)
+	}
+	if s.SchemaVersion != schemaVersion {
+		return fmt.Errorf("snapshot schema version is %d, expected %d", s.SchemaVersion, schemaVersion)
+	}
+
+	ids := make(map[int]bool, len(s.Chords))
+	for _, chord := range s.Chords {
+		if ids[chord.ID] {
+			return fmt.Errorf("duplicate chord id %d", chord.ID)
+		}
+		if !json.Valid(chord.Data) {
+			return fmt.Errorf("chord %d has invalid data", chord.ID)
+		}
+		ids[chord.ID] = true
+	}
+	for _, f := range s.Fingerings {
+		if !ids[f.ChordID] {
+			return fmt.Errorf("fingering %s refers to unknown chord id %d", f.Frets, f.ChordID)
+		}
+	}
+	for _, a := range s.Aliases {
+		if !ids[a.ChordID] {
+			return fmt.Errorf("alias %s %s refers to unknown chord id %d", a.Key, a.Suffix, a.ChordID)
+		}
+	}
+	return nil
+}
+
+// openSnapshot validates the snapshot file at path and loads it into an in-memory database.
+// Search still runs SQL, so the snapshot is turned back into tables rather than loaded into the maps directly.
+func openSnapshot(path string) (*sql.DB, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshot storeSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %v", err)
+	}
+	if err := snapshot.validate(); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %v", err)
+	}
+
+	// A shared-cache in-memory database lives as long as one connection to it is open,
+	// so one is held for the life of the server
+	mem, err := sql.Open("sqlite3", "file:chordserver-snapshot?mode=memory&cache=shared")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := mem.Conn(context.Background()); err != nil {
+		mem.Close()
+		return nil, err
+	}
+
+	if err := insertSnapshot(mem, &snapshot); err != nil {
+		mem.Close()
+		return nil, err
+	}
+	return mem, nil
+}
+
+// insertSnapshot creates the database tables and fills them from the snapshot in a single transaction
+func insertSnapshot(mem *sql.DB, snapshot *storeSnapshot) error {
+	if _, err := mem.Exec(snapshotSchema); err != nil {
+		return err
+	}
+
+	tx, err := mem.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, snapshot.SchemaVersion); err != nil {
+		return err
+	}
+	for _, chord := range snapshot.Chords {
+		_, err := tx.Exec(`INSERT INTO chords (id, key, suffix, tuning, full_data) VALUES (?, ?, ?, ?, ?)`,
+			chord.ID, chord.Key, chord.Suffix, chord.Tuning, string(chord.Data))
+		if err != nil {
+			return fmt.Errorf("chord %s %s: %v", chord.Key, chord.Suffix, err)
+		}
+	}
+	for _, f := range snapshot.Fingerings {
+		_, err := tx.Exec(`INSERT INTO fingerings (chord_id, frets, fingers, barres, capo) VALUES (?, ?, ?, ?, ?)`,
+			f.ChordID, f.Frets, f.Fingers, f.Barres, f.Capo)
+		if err != nil {
+			return err
+		}
+	}
+	for _, a := range snapshot.Aliases {
+		_, err := tx.Exec(`INSERT INTO chord_aliases (chord_id, alias_key, alias_suffix, alias_tuning) VALUES (?, ?, ?, ?)`,
+			a.ChordID, a.Key, a.Suffix, a.Tuning)
+		if err != nil {
+			return fmt.Errorf("alias %s %s: %v", a.Key, a.Suffix, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// getSnapshot downloads the whole chord store as a snapshot file that -snapshot can load
+func getSnapshot(w http.ResponseWriter, r *http.Request) {
+	snapshot, err := exportSnapshot()
+	if err != nil {
+		writeError(w, r, "Error exporting snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(snapshot)
+	if err != nil {
+		writeError(w, r, "Error exporting snapshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="chords.snapshot.json"`)
+	writeBody(w, string(body))
+}
+
+// consistencyMismatch is a chord whose cached data doesn't match its database row
+type consistencyMismatch struct {
+	Chord   string `json:"chord"` // key|suffix|tuning
+	Problem string `json:"problem"`
+}
+
+// verifyConsistency compares every chord row in the database with the cached chord, after applying the same
+// fixes as loading does, and reports the chords that differ
+func verifyConsistency(w http.ResponseWriter, r *http.Request) {
+	rows, err := db.Query(`SELECT key, suffix, tuning, full_data FROM chords`)
+	if err != nil {
+		writeError(w, r, "Error reading database: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	report := struct {
+		Checked    int                   `json:"checked"`
+		Mismatches []consistencyMismatch `json:"mismatches"`
+	}{Mismatches: []consistencyMismatch{}}
+	quiet := func(string, ...interface{}) {}
+
+	inDB := make(map[string]bool)
+	for rows.Next() {
+		var key, suffix, tuning, fullData string
+		if err := rows.Scan(&key, &suffix, &tuning, &fullData); err != nil {
+			writeError(w, r, "Error reading database: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		id := key + "|" + suffix + "|" + tuning
+		inDB[id] = true
+		report.Checked++
+
+		expected, err := prepareChord(key, suffix, tuning, fullData, quiet)
+		cached := chordMap[id]
+		problem := ""
+		switch {
+		case err != nil:
+			problem = "invalid data in database: " + err.Error()
+		case expected == nil && cached != nil:
+			problem = "cached but skipped when loading"
+		case expected == nil:
+		case cached == nil:
+			problem = "missing from cache"
+		case cached.FullData != expected.FullData:
+			problem = "data differs"
+		}
+		if problem != "" {
+			report.Mismatches = append(report.Mismatches, consistencyMismatch{Chord: id, Problem: problem})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, r, "Error reading database: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for id := range chordMap {
+		if !inDB[id] {
+			report.Mismatches = append(report.Mismatches, consistencyMismatch{Chord: id, Problem: "missing from database"})
+		}
+	}
+	sort.Slice(report.Mismatches, func(i, j int) bool {
+		return report.Mismatches[i].Chord < report.Mismatches[j].Chord
+	})
+
+	response, err := json.Marshal(report)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, string(response))
+}
+
+// warmChords resolves the chord names listed in the given file (or the default list) into warmCache
+func warmChords(listFile string) error {
+	names := defaultWarmList
+	if listFile != "" {
+		data, err := os.ReadFile(listFile)
+		if err != nil {
+			return err
+		}
+
+		// One name per line, skipping blank lines and # comments
+		names = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				names = append(names, line)
+			}
+		}
+	}
+
+	for _, name := range names {
+		if chord, via := resolveChordVia(name); chord != nil {
+			warmCache[name] = resolution{chord, via}
+		}
+	}
+
+	slog.Info("Warmed chord lookups", "warmed", len(warmCache), "names", len(names))
+	return nil
+}
+
+// checkSchema verifies that the database has the tables and schema version the server expects
+func checkSchema() error {
+	for _, table := range []string{"chords", "fingerings", "chord_aliases", "schema_version"} {
+		var name string
+		err := db.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&name)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("missing table %s", table)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var version int
+	if err := db.QueryRow(`SELECT version FROM schema_version`).Scan(&version); err != nil {
+		return err
+	}
+	if version != schemaVersion {
+		return fmt.Errorf("schema version is %d, expected %d", version, schemaVersion)
+	}
+
+	return nil
+}
+
+// prepareChord parses a chord row's full_data and applies the fixes made when loading it. Chords without
+// positions are skipped, returning nil, or flagged with a warning, and duplicate positions are merged.
+// Each fix is reported through logf.
+func prepareChord(key, suffix, tuning, fullData string, logf func(format string, args ...interface{})) (*ChordWithMeta, error) {
+	// Parse the full JSON data directly into a ChordWithMeta
+	chord := &ChordWithMeta{}
+	if err := json.Unmarshal([]byte(fullData), chord); err != nil {
+		return nil, err
+	}
+
+	// Chords without positions are bad data and crash clients
+	var err error
+	if len(chord.Positions) == 0 {
+		if !allowEmptyPositions {
+			logf("Skipping chord %s %s: no positions", key, suffix)
+			return nil, nil
+		}
+
+		logf("Chord %s %s has no positions", key, suffix)
+		fullData, err = withFields(fullData, map[string]interface{}{"warning": "no positions"})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Positions listed more than once are data-entry duplicates
+	if !keepDuplicatePositions {
+		if positions, removed := uniquePositions(chord.Positions); removed > 0 {
+			logf("Chord %s %s: removed %d duplicate positions", key, suffix, removed)
+			chord.Positions = positions
+			fullData, err = withFields(fullData, map[string]interface{}{"positions": positions})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Every chord declares the instrument and tuning it is fretted for, so clients draw it on the right neck
+	declared := make(map[string]interface{})
+	if chord.Instrument == "" {
+		chord.Instrument = defaultInstrument
+		declared["instrument"] = defaultInstrument
+	}
+	if chord.Tuning == "" {
+		declared["tuning"] = tuning
+	}
+	if len(declared) > 0 {
+		if fullData, err = withFields(fullData, declared); err != nil {
+			return nil, err
+		}
+	}
+
+	// Add the additional metadata
+	chord.Tuning = tuning
+	chord.NormalizedKey = normalizeKey(key)
+	chord.NormalizedSuffix = normalizeSuffix(suffix)
+	chord.FullData = fullData
+	return chord, nil
+}
+
+// loadChordData loads all chord data from the database into memory
+func loadChordData() error {
+	// Initialize the data structures
+	chordCache = make([]*ChordWithMeta, 0)
+	chordMap = make(map[string]*ChordWithMeta)
+	fingeringMap = make(map[string][]*ChordWithMeta)
+	normalizedMap = make(map[string][]*ChordWithMeta)
+	aliasMap = make(map[string]*ChordWithMeta)
+	chordsByID := make(map[int]*ChordWithMeta)
+
+	// Query all chords from the database
+	rows, err := db.Query(`SELECT id, key, suffix, tuning, full_data FROM chords`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	// Process each chord
+	for rows.Next() {
+		var id int
+		var key, suffix, tuning, fullData string
+		if err := rows.Scan(&id, &key, &suffix, &tuning, &fullData); err != nil {
+			return err
+		}
+
+		chord, err := prepareChord(key, suffix, tuning, fullData, warnf)
+		if err != nil {
+			return err
+		}
+		if chord == nil {
+			continue
+		}
+
+		// Add to cache and maps
+		chordCache = append(chordCache, chord)
+		chordsByID[id] = chord
+		chordMap[key+"|"+suffix+"|"+tuning] = chord
+
+		// Add to normalized map
+		normalizedKey := chord.NormalizedKey
+		normalizedSuffix := chord.NormalizedSuffix
+		normalizedMapKey := normalizedKey + "|" + normalizedSuffix
+		normalizedMap[normalizedMapKey] = append(normalizedMap[normalizedMapKey], chord)
+
+		// Index by fingering patterns
+		chord.Barres = make([][]int, len(chord.Positions))
+		for i, posInterface := range chord.Positions {
+			// Convert to map to access fields
+			if posMap, ok := posInterface.(map[string]interface{}); ok {
+				if fretsValue, ok := posMap["frets"]; ok {
+					if frets, ok := fretsValue.(string); ok {
+						// Index each chord once per pattern, even if several positions share it
+						frets = normalizeFingering(frets)
+						if matches := fingeringMap[frets]; len(matches) == 0 || matches[len(matches)-1] != chord {
+							fingeringMap[frets] = append(matches, chord)
+						}
+					}
+				}
+
+				// Malformed barres are served as-is but left out of diagrams
+				if barresValue, ok := posMap["barres"].(string); ok {
+					barres, err := parseBarres(barresValue)
+					if err != nil {
+						slog.Warn("Malformed barres", "key", key, "suffix", suffix, "position", i+1, "barres", barresValue, "err", err)
+					}
+					chord.Barres[i] = barres
+				}
+			}
+		}
+	}
+
+	// Index the suffix aliases generated by build_db
+	aliasRows, err := db.Query(`SELECT chord_id, alias_key, alias_suffix, alias_tuning FROM chord_aliases`)
+	if err != nil {
+		return err
+	}
+	defer aliasRows.Close()
+
+	for aliasRows.Next() {
+		var chordID int
+		var aliasKey, aliasSuffix, aliasTuning string
+		if err := aliasRows.Scan(&chordID, &aliasKey, &aliasSuffix, &aliasTuning); err != nil {
+			return err
+		}
+
+		if chord, ok := chordsByID[chordID]; ok {
+			aliasMap[normalizeKey(aliasKey)+"|"+aliasSuffix+"|"+aliasTuning] = chord
+		}
+	}
+
+	buildBrowseIndex()
+	buildPitchClassIndex()
+	buildSitemap()
+
+	slog.Info("Loaded chords into memory", "chords", len(chordCache))
+	return nil
+}
+
+// buildBrowseIndex sorts the chords by key, then suffix priority, for prev/next browsing
+func buildBrowseIndex() {
+	browseOrder = make([]*ChordWithMeta, len(chordCache))
+	copy(browseOrder, chordCache)
+
+	sort.SliceStable(browseOrder, func(i, j int) bool {
+		a, b := browseOrder[i], browseOrder[j]
+		if ai, bi := noteIndex(a.Key), noteIndex(b.Key); ai != bi {
+			return ai < bi
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if ap, bp := getChordTypePriority(a.Suffix), getChordTypePriority(b.Suffix); ap != bp {
+			return ap < bp
+		}
+		if a.Suffix != b.Suffix {
+			return a.Suffix < b.Suffix
+		}
+		return a.Tuning < b.Tuning
+	})
+
+	browseIndex = make(map[*ChordWithMeta]int, len(browseOrder))
+	for i, chord := range browseOrder {
+		browseIndex[chord] = i
+	}
+}
+
+// buildPitchClassIndex indexes the chords in browse order by the pitch classes of their notes.
+// Chords whose suffix has no known formula aren't indexed.
+func buildPitchClassIndex() {
+	pitchClassMap = make(map[int][]*ChordWithMeta)
+	for _, chord := range browseOrder {
+		for _, pitchClass := range chordPitchClasses(chord) {
+			pitchClassMap[pitchClass] = append(pitchClassMap[pitchClass], chord)
+		}
+	}
+}
+
+// parseBarres parses a barres field, a comma-separated list of the frets barred in a position, e.g. "1" or "1,3"
+func parseBarres(value string) ([]int, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
+	}
+
+	var barres []int
+	for _, part := range strings.Split(value, ",") {
+		fret, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || fret < 1 {
+			return nil, fmt.Errorf("invalid barre fret %q", part)
+		}
+		barres = append(barres, fret)
+	}
+	return barres, nil
+}
+
+// uniquePositions drops positions with the same frets, fingers, barres and capo as an earlier one,
+// returning the remaining positions and how many were dropped
+func uniquePositions(positions []interface{}) ([]interface{}, int) {
+	seen := make(map[string]bool)
+	unique := make([]interface{}, 0, len(positions))
+	for _, position := range positions {
+		if posMap, ok := position.(map[string]interface{}); ok {
+			fingerprint := fmt.Sprintf("%v|%v|%v|%v", posMap["frets"], posMap["fingers"], posMap["barres"], posMap["capo"])
+			if seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+		}
+		unique = append(unique, position)
+	}
+	return unique, len(positions) - len(unique)
+}
+
+// withFields returns the chord JSON with the given fields added
+func withFields(fullData string, extra map[string]interface{}) (string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(fullData), &fields); err != nil {
+		return "", err
+	}
+
+	for name, value := range extra {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		fields[name] = encoded
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// fingerCount returns the number of distinct fretting fingers in a fingers string, ignoring open ('0')
+// and muted ('x') strings, so a barre held with one finger counts once
+func fingerCount(fingers string) int {
+	used := make(map[rune]bool)
+	for _, c := range fingers {
+		if c != '0' && c != 'x' && c != 'X' && c != '-' {
+			used[c] = true
+		}
+	}
+	return len(used)
+}
+
+// renderChord returns the JSON for a chord with any optional fields requested by the client
+func renderChord(chord *ChordWithMeta, r *http.Request) (string, error) {
+	query := r.URL.Query()
+	extra := make(map[string]interface{})
+
+	if query.Get("include_intervals") == "true" {
+		if intervals := chordIntervals(chord.Suffix); intervals != nil {
+			extra["intervals"] = intervals
+		}
+	}
+
+	if query.Get("include_bass") == "true" {
+		quality, bass := splitSlash(chord.Suffix)
+		extra["chord"] = chord.Key + shortQuality(quality)
+		extra["bass"] = bass
+	}
+
+	if query.Get("include_finger_count") == "true" {
+		counts := make([]int, len(chord.Positions))
+		for i, position := range chord.Positions {
+			if posMap, ok := position.(map[string]interface{}); ok {
+				fingers, _ := posMap["fingers"].(string)
+				counts[i] = fingerCount(fingers)
+			}
+		}
+		extra["finger_counts"] = counts
+	}
+
+	// The same chord stored separately under the key's other spelling, e.g. Bb major for A# major
+	if query.Get("include_enharmonic") == "true" {
+		if spelling, ok := enharmonicSpelling(chord.Key); ok {
+			if other := chordMap[spelling+"|"+chord.Suffix+"|"+chord.Tuning]; other != nil && other != chord {
+				extra["enharmonic"] = json.RawMessage(other.FullData)
+			}
+		}
+	}
+
+	// Positions without frets have null flags
+	if query.Get("include_flags") == "true" {
+		flags := make([]*positionFlags, len(chord.Positions))
+		for i, position := range chord.Positions {
+			if analyzed, ok := analyzePosition(position); ok {
+				flags[i] = &analyzed
+			}
+		}
+		extra["position_flags"] = flags
+	}
+
+	// The diagram is null for chords that can't be drawn, e.g. without positions
+	if query.Get("include_diagram") == "svg" {
+		diagram, err := renderChordSVG(chord, r)
+		switch {
+		case errors.Is(err, errFormatUnavailable):
+			extra["diagram"] = nil
+		case err != nil:
+			return "", err
+		default:
+			extra["diagram"] = diagram
+		}
+	}
+
+	if len(extra) == 0 {
+		return chord.FullData, nil
+	}
+	return withFields(chord.FullData, extra)
+}
+
+// shortQuality spells the major and minor suffixes the way chord names write them, e.g. "" in C and "m" in Cm
+func shortQuality(quality string) string {
+	switch quality {
+	case "major":
+		return ""
+	case "minor":
+		return "m"
+	}
+	return quality
+}
+
+// splitSlash splits a slash chord suffix into the chord quality and the bass note, e.g. "m7/G" into
+// "m7" and "G". The bass is empty for suffixes without a slash.
+func splitSlash(suffix string) (string, string) {
+	if i := strings.Index(suffix, "/"); i >= 0 {
+		return suffix[:i], suffix[i+1:]
+	}
+	return suffix, ""
+}
+
+// suffixIntervals maps chord suffixes to the intervals the chord is built from
+var suffixIntervals = map[string][]string{
+	"major": {"1", "3", "5"},
+	"minor": {"1", "b3", "5"},
+	"5":     {"1", "5"},
+	"7":     {"1", "3", "5", "b7"},
+	"maj7":  {"1", "3", "5", "7"},
+	"m7":    {"1", "b3", "5", "b7"},
+	"dim":   {"1", "b3", "b5"},
+	"dim7":  {"1", "b3", "b5", "bb7"},
+	"m7b5":  {"1", "b3", "b5", "b7"},
+	"aug":   {"1", "3", "#5"},
+	"sus2":  {"1", "2", "5"},
+	"sus4":  {"1", "4", "5"},
+	"7sus4": {"1", "4", "5", "b7"},
+	"6":     {"1", "3", "5", "6"},
+	"m6":    {"1", "b3", "5", "6"},
+	"9":     {"1", "3", "5", "b7", "9"},
+	"maj9":  {"1", "3", "5", "7", "9"},
+	"m9":    {"1", "b3", "5", "b7", "9"},
+	"add9":  {"1", "3", "5", "9"},
+}
+
+// degreeSemitones are the semitones above the root of each natural scale degree in a chord formula
+var degreeSemitones = map[int]int{1: 0, 2: 2, 3: 4, 4: 5, 5: 7, 6: 9, 7: 11, 9: 14, 11: 17, 13: 21}
+
+// intervalSemitonesAbove returns the semitones above the root of a formula interval such as "b3" or "#11"
+func intervalSemitonesAbove(interval string) (int, bool) {
+	alter := 0
+	for len(interval) > 0 && (interval[0] == 'b' || interval[0] == '#') {
+		if interval[0] == 'b' {
+			alter--
+		} else {
+			alter++
+		}
+		interval = interval[1:]
+	}
+
+	degree, err := strconv.Atoi(interval)
+	semitones, ok := degreeSemitones[degree]
+	if err != nil || !ok {
+		return 0, false
+	}
+	return semitones + alter, true
+}
+
+// chordPitchClasses returns the distinct pitch classes (0-11) of a chord's notes from its formula, including
+// the bass note of a slash chord, or nil if the root or formula is unknown
+func chordPitchClasses(chord *ChordWithMeta) []int {
+	root := noteIndex(chord.NormalizedKey)
+	intervals := chordIntervals(chord.Suffix)
+	if root < 0 || intervals == nil {
+		return nil
+	}
+
+	seen := make(map[int]bool)
+	var pitchClasses []int
+	add := func(pitchClass int) {
+		if !seen[pitchClass] {
+			seen[pitchClass] = true
+			pitchClasses = append(pitchClasses, pitchClass)
+		}
+	}
+	for _, interval := range intervals {
+		if semitones, ok := intervalSemitonesAbove(interval); ok {
+			add((root + semitones) % 12)
+		}
+	}
+	if _, bass := splitSlash(chord.Suffix); bass != "" {
+		if i := noteIndex(bass); i >= 0 {
+			add(i)
+		}
+	}
+	return pitchClasses
+}
+
+// chordIntervals returns the intervals of a chord suffix, or nil if the suffix is unknown
+func chordIntervals(suffix string) []string {
+	// The bass note of a slash chord doesn't change its construction
+	if i := strings.Index(suffix, "/"); i >= 0 {
+		suffix = suffix[:i]
+	}
+
+	if intervals, ok := suffixIntervals[suffix]; ok {
+		return intervals
+	}
+	return suffixIntervals[normalizeSuffix(suffix)]
+}
+
+// musicXMLContentType is the media type of uncompressed MusicXML
+const musicXMLContentType = "application/vnd.recordare.musicxml+xml"
+
+// suffixKinds maps chord suffixes to MusicXML harmony kinds. Other suffixes are emitted as kind "other".
+var suffixKinds = map[string]string{
+	"major": "major",
+	"minor": "minor",
+	"5":     "power",
+	"7":     "dominant",
+	"maj7":  "major-seventh",
+	"m7":    "minor-seventh",
+	"mmaj7": "major-minor",
+	"dim":   "diminished",
+	"dim7":  "diminished-seventh",
+	"m7b5":  "half-diminished",
+	"aug":   "augmented",
+	"sus2":  "suspended-second",
+	"sus4":  "suspended-fourth",
+	"6":     "major-sixth",
+	"m6":    "minor-sixth",
+	"9":     "dominant-ninth",
+	"maj9":  "major-ninth",
+	"m9":    "minor-ninth",
+	"11":    "dominant-11th",
+	"13":    "dominant-13th",
+}
+
+// musicXMLHarmony is a MusicXML <harmony> element with an optional fret diagram
+type musicXMLHarmony struct {
+	XMLName xml.Name       `xml:"harmony"`
+	Root    musicXMLRoot   `xml:"root"`
+	Kind    musicXMLKind   `xml:"kind"`
+	Bass    *musicXMLBass  `xml:"bass,omitempty"`
+	Frame   *musicXMLFrame `xml:"frame,omitempty"`
+}
+
+type musicXMLRoot struct {
+	Step  string `xml:"root-step"`
+	Alter int    `xml:"root-alter,omitempty"`
+}
+
+type musicXMLKind struct {
+	Text  string `xml:"text,attr"`
+	Value string `xml:",chardata"`
+}
+
+type musicXMLBass struct {
+	Step  string `xml:"bass-step"`
+	Alter int    `xml:"bass-alter,omitempty"`
+}
+
+type musicXMLFrame struct {
+	Strings   int                 `xml:"frame-strings"`
+	Frets     int                 `xml:"frame-frets"`
+	FirstFret int                 `xml:"first-fret,omitempty"`
+	Notes     []musicXMLFrameNote `xml:"frame-note"`
+}
+
+type musicXMLFrameNote struct {
+	String    int            `xml:"string"`
+	Fret      int            `xml:"fret"`
+	Fingering string         `xml:"fingering,omitempty"`
+	Barre     *musicXMLBarre `xml:"barre,omitempty"`
+}
+
+// musicXMLBarre marks the strings where a barre starts and stops
+type musicXMLBarre struct {
+	Type string `xml:"type,attr"`
+}
+
+// noteStep splits a note name like "F#" or "Bb" into its step and alteration in semitones
+func noteStep(note string) (string, int) {
+	if note == "" {
+		return "", 0
+	}
+	alter := 0
+	for _, c := range note[1:] {
+		switch c {
+		case '#':
+			alter++
+		case 'b':
+			alter--
+		}
+	}
+	return strings.ToUpper(note[:1]), alter
+}
+
+// fretNumber decodes a fret character, where letters stand for frets 10 and above (a=10, b=11, etc.)
+func fretNumber(c byte) (int, bool) {
+	switch {
+	case c == 'x' || c == 'X':
+		return 0, false
+	case c >= '0' && c <= '9':
+		return int(c - '0'), true
+	case c >= 'a' && c <= 'z':
+		return int(c-'a') + 10, true
+	}
+	return 0, false
+}
+
+// fretChar encodes a fret for a packed frets string, the reverse of fretNumber, or returns false
+// if the fret is negative or past the letters
+func fretChar(fret int) (byte, bool) {
+	switch {
+	case fret < 0:
+		return 0, false
+	case fret <= 9:
+		return byte('0' + fret), true
+	case fret <= 35:
+		return byte('a' + fret - 10), true
+	}
+	return 0, false
+}
+
+// formatFrets encodes frets as returned by parseFrets. They are packed one character per string unless
+// delimited is set or a fret is past the letters, in which case they are separated by dashes.
+func formatFrets(frets []int, delimited bool) string {
+	if !delimited {
+		packed := make([]byte, len(frets))
+		for i, fret := range frets {
+			c, ok := fretChar(fret)
+			if fret < 0 {
+				c, ok = 'x', true
+			}
+			if !ok {
+				return formatFrets(frets, true)
+			}
+			packed[i] = c
+		}
+		return string(packed)
+	}
+
+	parts := make([]string, len(frets))
+	for i, fret := range frets {
+		parts[i] = "x"
+		if fret >= 0 {
+			parts[i] = strconv.Itoa(fret)
+		}
+	}
+	return strings.Join(parts, fretSeparator)
+}
+
+// fretSeparator separates the strings of a delimited frets string, e.g. "x-3-2-0-1-0"
+const fretSeparator = "-"
+
+// parseFrets decodes a frets string into one fret per string from the lowest string up, with -1 for muted strings.
+// Frets are either packed one character per string, or delimited by dashes, which reads better and allows
+// frets past the letters, e.g. "8-10-10-9-8-8".
+func parseFrets(frets string) []int {
+	if !strings.Contains(frets, fretSeparator) {
+		parsed := make([]int, len(frets))
+		for i := 0; i < len(frets); i++ {
+			fret, played := fretNumber(frets[i])
+			if !played {
+				fret = -1
+			}
+			parsed[i] = fret
+		}
+		return parsed
+	}
+
+	parts := strings.Split(frets, fretSeparator)
+	parsed := make([]int, len(parts))
+	for i, part := range parts {
+		fret, err := strconv.Atoi(part)
+		if err != nil || fret < 0 {
+			fret = -1
+		}
+		parsed[i] = fret
+	}
+	return parsed
+}
+
+// renderMusicXML returns a MusicXML <harmony> element for a chord, with a fret diagram of its first position
+func renderMusicXML(chord *ChordWithMeta) (string, error) {
+	quality, bass := splitSlash(chord.Suffix)
+	if quality == "" {
+		quality = "major"
+	}
+
+	kind, ok := suffixKinds[quality]
+	if !ok {
+		kind = suffixKinds[normalizeSuffix(quality)]
+	}
+	if kind == "" {
+		kind = "other"
+	}
+
+	harmony := musicXMLHarmony{Kind: musicXMLKind{Text: quality, Value: kind}}
+	harmony.Root.Step, harmony.Root.Alter = noteStep(chord.Key)
+	if bass != "" {
+		step, alter := noteStep(bass)
+		harmony.Bass = &musicXMLBass{Step: step, Alter: alter}
+	}
+
+	if len(chord.Positions) > 0 {
+		if position, ok := chord.Positions[0].(map[string]interface{}); ok {
+			frets, _ := position["frets"].(string)
+			fingers, _ := position["fingers"].(string)
+			harmony.Frame = fretDiagram(frets, fingers, chord.Barres[0])
+		}
+	}
+
+	data, err := xml.MarshalIndent(harmony, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// fretDiagram builds a MusicXML frame from a fret pattern listed from the lowest string up.
+// Muted strings are left out of the frame, and each barre spans the outermost strings fretted at its fret.
+func fretDiagram(frets, fingers string, barres []int) *musicXMLFrame {
+	if frets == "" {
+		return nil
+	}
+
+	parsed := parseFrets(frets)
+	frame := &musicXMLFrame{Strings: len(parsed)}
+	lowest, highest := 0, 0
+	for i, fret := range parsed {
+		if fret < 0 {
+			continue
+		}
+
+		// MusicXML numbers strings from the highest pitched string down
+		note := musicXMLFrameNote{String: len(parsed) - i, Fret: fret}
+		if i < len(fingers) && fingers[i] >= '1' && fingers[i] <= '9' {
+			note.Fingering = fingers[i : i+1]
+		}
+		frame.Notes = append(frame.Notes, note)
+
+		if fret > 0 && (lowest == 0 || fret < lowest) {
+			lowest = fret
+		}
+		if fret > highest {
+			highest = fret
+		}
+	}
+
+	for _, barre := range barres {
+		start, stop := -1, -1
+		for i, note := range frame.Notes {
+			if note.Fret == barre {
+				if start < 0 {
+					start = i
+				}
+				stop = i
+			}
+		}
+		if start >= 0 && stop > start {
+			frame.Notes[start].Barre = &musicXMLBarre{Type: "start"}
+			frame.Notes[stop].Barre = &musicXMLBarre{Type: "stop"}
+		}
+	}
+
+	// Diagrams show at least four frets, starting at the nut unless the shape sits higher up the neck
+	frame.Frets = 4
+	if highest > 4 {
+		frame.FirstFret = lowest
+		if span := highest - lowest + 1; span > frame.Frets {
+			frame.Frets = span
+		}
+	}
+	return frame
+}
+
+// chordFormat renders a resolved chord in one of the response formats of the chord endpoint
+type chordFormat struct {
+	ContentType string
+	Render      func(chord *ChordWithMeta, r *http.Request) (string, error)
+}
+
+// chordFormats is the registry of response formats for /chords/{name}, keyed by the name used in ?format=.
+// Adding a format is a matter of registering its renderer here and, optionally, an extension in formatExtensions.
+var chordFormats = map[string]chordFormat{
+	"json":     {"application/json", renderChord},
+	"musicxml": {musicXMLContentType, func(chord *ChordWithMeta, r *http.Request) (string, error) { return renderMusicXML(chord) }},
+	"text":     {"text/plain; charset=utf-8", renderChordText},
+	"csv":      {"text/csv; charset=utf-8", renderChordCSV},
+	"svg":      {"image/svg+xml", renderChordSVG},
+	"midi":     {"audio/midi", renderChordMIDI},
+	"compact":  {compactContentType, renderChordCompact},
+}
+
+// formatExtensions maps the extensions that can end a chord name to their formats, e.g. /chords/Am.svg
+var formatExtensions = map[string]string{
+	".json": "json",
+	".xml":  "musicxml",
+	".txt":  "text",
+	".csv":  "csv",
+	".svg":  "svg",
+	".mid":  "midi",
+}
+
+// errFormatUnavailable is returned by renderers that can't render a particular chord, e.g. a diagram of a chord without positions
+var errFormatUnavailable = errors.New("format not available for this chord")
+
+// negotiateChordFormat picks the response format of a chord request and strips a format extension from the chord name.
+// An extension takes precedence over ?format=, which takes precedence over the Accept header, and JSON is the default.
+// It returns false if ?format= names an unknown format.
+func negotiateChordFormat(r *http.Request, chordPath string) (string, string, bool) {
+	if i := strings.LastIndex(chordPath, "."); i > 0 {
+		if format, ok := formatExtensions[chordPath[i:]]; ok {
+			return chordPath[:i], format, true
+		}
+	}
+
+	if format := r.URL.Query().Get("format"); format != "" {
+		_, ok := chordFormats[format]
+		return chordPath, format, ok
+	}
+
+	// The first acceptable media type with a renderer wins, and anything else falls back to JSON
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(params, " ", "") == "q=0" {
+			continue
+		}
+		for format, renderer := range chordFormats {
+			if contentType, _, _ := strings.Cut(renderer.ContentType, ";"); strings.EqualFold(mediaType, contentType) {
+				return chordPath, format, true
+			}
+		}
+	}
+	return chordPath, "json", true
+}
+
+// chordDisplayName spells a chord the way chord names write it, e.g. "Am7/G"
+func chordDisplayName(chord *ChordWithMeta) string {
+	quality, bass := splitSlash(chord.Suffix)
+	name := chord.Key + shortQuality(quality)
+	if bass != "" {
+		name += "/" + bass
+	}
+	return name
+}
+
+// positionField returns a field of a position as text, or "" if it is missing
+func positionField(position interface{}, field string) string {
+	posMap, ok := position.(map[string]interface{})
+	if !ok || posMap[field] == nil {
+		return ""
+	}
+	return fmt.Sprint(posMap[field])
+}
+
+// renderChordText returns a chord as a plain-text table with one row per position
+func renderChordText(chord *ChordWithMeta, r *http.Request) (string, error) {
+	var text strings.Builder
+	fmt.Fprintf(&text, "%s (%s %s, %s tuning)\n", chordDisplayName(chord), chord.Key, chord.Suffix, chord.Tuning)
+
+	tw := tabwriter.NewWriter(&text, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "position\tfrets\tfingers\tbarres\tcapo")
+	for i, position := range chord.Positions {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", i+1, positionField(position, "frets"), positionField(position, "fingers"),
+			positionField(position, "barres"), positionField(position, "capo"))
+	}
+	if err := tw.Flush(); err != nil {
+		return "", err
+	}
+	return text.String(), nil
+}
+
+// compactContentType is the media type of the compact chord format
+const compactContentType = "application/vnd.chordserver.compact+json"
+
+// compactSuffixes are the short codes of suffixes in the compact format. Other suffixes are written as they are.
+var compactSuffixes = map[string]string{"major": "", "minor": "m"}
+
+// renderChordCompact returns a chord as a fixed-schema JSON array without field names, for bandwidth-constrained
+// clients: [key, suffix, tuning, [frets, ...]]. The suffix is written as its short code from compactSuffixes,
+// the tuning is empty for the default tuning, and each position is reduced to its frets.
+func renderChordCompact(chord *ChordWithMeta, r *http.Request) (string, error) {
+	suffix, ok := compactSuffixes[chord.Suffix]
+	if !ok {
+		suffix = chord.Suffix
+	}
+	tuning := chord.Tuning
+	if tuning == defaultTuning {
+		tuning = ""
+	}
+
+	frets := make([]string, len(chord.Positions))
+	for i, position := range chord.Positions {
+		frets[i] = positionField(position, "frets")
+	}
+
+	data, err := json.Marshal([]interface{}{chord.Key, suffix, tuning, frets})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// renderChordCSV returns a chord as CSV with a header row and one row per position
+func renderChordCSV(chord *ChordWithMeta, r *http.Request) (string, error) {
+	var data strings.Builder
+	cw := csv.NewWriter(&data)
+	cw.Write([]string{"key", "suffix", "tuning", "position", "frets", "fingers", "barres", "capo"})
+	for i, position := range chord.Positions {
+		cw.Write([]string{chord.Key, chord.Suffix, chord.Tuning, strconv.Itoa(i + 1), positionField(position, "frets"),
+			positionField(position, "fingers"), positionField(position, "barres"), positionField(position, "capo")})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return "", err
+	}
+	return data.String(), nil
+}
+
+// Chord diagram geometry in SVG user units
+const (
+	svgMargin       = 30 // Space around the grid for the title, fret number and open and muted markers
+	svgStringGap    = 20 // Distance between strings
+	svgFretGap      = 24 // Distance between frets
+	svgDotRadius    = 8
+	svgTitleHeight  = 20
+	svgMarkerOffset = 10 // Distance of the open and muted markers above the nut
+)
+
+// renderChordSVG returns a diagram of a chord's first position as a standalone SVG image. It is drawn from the
+// same frame as the MusicXML diagram, with strings from the lowest on the left and the nut at the top.
+func renderChordSVG(chord *ChordWithMeta, r *http.Request) (string, error) {
+	if len(chord.Positions) == 0 {
+		return "", errFormatUnavailable
+	}
+	frets := positionField(chord.Positions[0], "frets")
+	frame := fretDiagram(frets, positionField(chord.Positions[0], "fingers"), chord.Barres[0])
+	if frame == nil || frame.Strings < 2 {
+		return "", errFormatUnavailable
+	}
+
+	firstFret := max(frame.FirstFret, 1)
+	left, top := svgMargin, svgMargin+svgTitleHeight
+	width := 2*svgMargin + (frame.Strings-1)*svgStringGap
+	height := top + frame.Frets*svgFretGap + svgMargin
+	stringX := func(number int) int { return left + (frame.Strings-number)*svgStringGap }
+	fretY := func(fret int) int { return top + (fret-firstFret)*svgFretGap + svgFretGap/2 }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		width, height, width, height)
+	fmt.Fprintf(&svg, `<title>%s</title>`+"\n", html.EscapeString(chordDisplayName(chord)))
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle" font-size="16">%s</text>`+"\n",
+		width/2, svgTitleHeight, html.EscapeString(chordDisplayName(chord)))
+
+	// The grid, with a thick nut for shapes that start at the first fret and the fret number otherwise
+	right, bottom := left+(frame.Strings-1)*svgStringGap, top+frame.Frets*svgFretGap
+	for i := 0; i < frame.Strings; i++ {
+		x := left + i*svgStringGap
+		fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", x, top, x, bottom)
+	}
+	for i := 0; i <= frame.Frets; i++ {
+		y := top + i*svgFretGap
+		fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`+"\n", left, y, right, y)
+	}
+	if frame.FirstFret == 0 {
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="4"/>`+"\n", left, top-4, right-left)
+	} else {
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end">%d</text>`+"\n", left-svgDotRadius-4, fretY(firstFret)+4, firstFret)
+	}
+
+	// Muted strings are left out of the frame, so mark every string that has no note
+	played := make(map[int]bool)
+	for _, note := range frame.Notes {
+		played[note.String] = true
+	}
+	for number := 1; number <= frame.Strings; number++ {
+		if !played[number] {
+			fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle">x</text>`+"\n", stringX(number), top-svgMarkerOffset)
+		}
+	}
+
+	barreStart := -1
+	for _, note := range frame.Notes {
+		x := stringX(note.String)
+		if note.Fret == 0 {
+			fmt.Fprintf(&svg, `<circle cx="%d" cy="%d" r="4" fill="none" stroke="black"/>`+"\n", x, top-svgMarkerOffset-4)
+			continue
+		}
+
+		if note.Barre != nil {
+			if note.Barre.Type == "start" {
+				barreStart = x
+			} else if barreStart >= 0 {
+				fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" rx="%d"/>`+"\n",
+					barreStart, fretY(note.Fret)-svgDotRadius, x-barreStart, 2*svgDotRadius, svgDotRadius)
+				barreStart = -1
+			}
+		}
+		fmt.Fprintf(&svg, `<circle cx="%d" cy="%d" r="%d"/>`+"\n", x, fretY(note.Fret), svgDotRadius)
+		if note.Fingering != "" {
+			fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle" fill="white">%s</text>`+"\n", x, fretY(note.Fret)+4, note.Fingering)
+		}
+	}
+
+	svg.WriteString("</svg>\n")
+	return svg.String(), nil
+}
+
+// tuningMIDINotes are the MIDI note numbers of the open strings of each tuning, from the lowest string up
+var tuningMIDINotes = map[string][]int{
+	defaultTuning: {40, 45, 50, 55, 59, 64}, // E2 A2 D3 G3 B3 E4
+}
+
+// maxTuningOctave is the highest octave a string of a ?display_tuning= spec may be tuned to
+const maxTuningOctave = 6
+
+// maxMIDINote is the highest MIDI note number. Higher values would be read as status bytes, corrupting the file.
+const maxMIDINote = 127
+
+// errNoteOutOfRange is returned by renderChordMIDI when a fret sounds above maxMIDINote on the display tuning
+var errNoteOutOfRange = errors.New("the display tuning sounds notes above the MIDI range")
+
+// requestDisplayTuning returns the open string notes ?display_tuning= asks frets to be sounded on, as MIDI note
+// numbers from the lowest string up, or nil if it isn't set. The tuning is either a name from tuningMIDINotes or
+// each string's note and octave, comma-separated, e.g. D2,A2,D3,G3,B3,E4 for drop D.
+func requestDisplayTuning(r *http.Request) ([]int, error) {
+	value := r.URL.Query().Get("display_tuning")
+	if value == "" {
+		return nil, nil
+	}
+	if notes, ok := tuningMIDINotes[value]; ok {
+		return notes, nil
+	}
+
+	var notes []int
+	for _, note := range strings.Split(value, ",") {
+		name := strings.TrimRight(note, "0123456789")
+		octave, err := strconv.Atoi(note[len(name):])
+		pitch := noteIndex(name)
+
+		// B# is spelled in the octave below the C it sounds as
+		if strings.EqualFold(name, "B#") {
+			octave++
+		}
+		midiNote := 12*(octave+1) + pitch
+		if err != nil || pitch < 0 || octave > maxTuningOctave || midiNote > maxMIDINote {
+			return nil, fmt.Errorf("invalid display tuning: %s (expected a tuning name or notes like E2,A2,D3,G3,B3,E4)", value)
+		}
+		notes = append(notes, midiNote)
+	}
+	return notes, nil
+}
+
+// chordOpenNotes returns the MIDI notes of the open strings a chord's frets are sounded on: the display tuning if
+// one was requested, otherwise the chord's own tuning, or false if that tuning's pitches aren't known
+func chordOpenNotes(chord *ChordWithMeta, displayTuning []int) ([]int, bool) {
+	if displayTuning != nil {
+		return displayTuning, true
+	}
+	notes, ok := tuningMIDINotes[chord.Tuning]
+	return notes, ok
+}
+
+// MIDI file timing: the chord is held for a whole note at the default tempo of 120 beats per minute
+const (
+	midiTicksPerBeat = 480
+	midiChordTicks   = 4 * midiTicksPerBeat
+	midiVelocity     = 96
+)
+
+// renderChordMIDI returns a chord's first position as a standard MIDI file that plays every sounding string
+// at once, on ?display_tuning= if given. Chords in tunings without known open string pitches can't be rendered.
+func renderChordMIDI(chord *ChordWithMeta, r *http.Request) (string, error) {
+	displayTuning, err := requestDisplayTuning(r)
+	if err != nil {
+		return "", err
+	}
+	openNotes, ok := chordOpenNotes(chord, displayTuning)
+	if !ok || len(chord.Positions) == 0 {
+		return "", errFormatUnavailable
+	}
+	frets := parseFrets(positionField(chord.Positions[0], "frets"))
+	if len(frets) != len(openNotes) {
+		return "", errFormatUnavailable
+	}
+
+	var notes []byte
+	for i, fret := range frets {
+		if fret < 0 {
+			continue
+		}
+		if openNotes[i]+fret > maxMIDINote {
+			return "", errNoteOutOfRange
+		}
+		notes = append(notes, byte(openNotes[i]+fret))
+	}
+	if len(notes) == 0 {
+		return "", errFormatUnavailable
+	}
+
+	// A single track of note-ons, then note-offs after the chord's length, each event preceded by its delta time
+	var track []byte
+	for _, note := range notes {
+		track = append(track, 0x00, 0x90, note, midiVelocity)
+	}
+	for i, note := range notes {
+		delta := []byte{0x00}
+		if i == 0 {
+			delta = midiVarLen(midiChordTicks)
+		}
+		track = append(track, delta...)
+		track = append(track, 0x80, note, 0x40)
+	}
+	track = append(track, 0x00, 0xFF, 0x2F, 0x00) // End of track
+
+	var file bytes.Buffer
+	file.WriteString("MThd")
+	binary.Write(&file, binary.BigEndian, []uint32{6})
+	binary.Write(&file, binary.BigEndian, []uint16{0, 1, midiTicksPerBeat}) // Format 0, one track
+	file.WriteString("MTrk")
+	binary.Write(&file, binary.BigEndian, uint32(len(track)))
+	file.Write(track)
+	return file.String(), nil
+}
+
+// midiVarLen encodes a MIDI variable-length quantity, seven bits per byte with the high bit set on all but the last
+func midiVarLen(value int) []byte {
+	encoded := []byte{byte(value & 0x7F)}
+	for value >>= 7; value > 0; value >>= 7 {
+		encoded = append([]byte{byte(value&0x7F) | 0x80}, encoded...)
+	}
+	return encoded
+}
+
+// chordSubresources are handlers for paths like /chords/{name}/neighbors that act on a resolved chord
+var chordSubresources = map[string]func(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta){
+	"circle":      getCircleNeighbors,
+	"common-tone": getCommonToneChords,
+	"inversions":  getChordInversions,
+	"neighbors":   getChordNeighbors,
+	"next":        getNextChord,
+	"prev":        getPrevChord,
+	"relative":    getRelativeChord,
+	"similar":     getSimilarChords,
+	"simplify":    getSimplifiedChord,
+}
+
+// Result caps for each endpoint, set by -search-limit, -fingers-limit and -all-limit
+var (
+	searchLimit  int // Most chords a search returns
+	fingersLimit int // Most chords a fingering lookup returns
+	similarLimit int // Most chords a similarity ranking returns
+	allLimit     int // Most chords that can be requested in one comma-separated list
+)
+
+// maxResponseBytes caps the size of chord arrays, set by -max-response-bytes. 0 means no cap.
+var maxResponseBytes int
+
+// capResults drops the results that would take a JSON array of them past maxResponseBytes, and flags the
+// response with X-Truncated if any were dropped. Results are kept in order until the next one doesn't fit.
+func capResults(w http.ResponseWriter, results []json.RawMessage) []json.RawMessage {
+	if maxResponseBytes == 0 {
+		return results
+	}
+
+	size := len("[]")
+	for i, result := range results {
+		size += len(result)
+		if i > 0 {
+			size += len(",")
+		}
+		if result == nil {
+			size += len("null")
+		}
+		if size > maxResponseBytes {
+			w.Header().Set("X-Truncated", "true")
+			return results[:i]
+		}
+	}
+	return results
+}
+
+func getChordByName(w http.ResponseWriter, r *http.Request) {
+	// Extract chord name from URL
+	chordPath := r.URL.Path[len("/chords/"):]
+	if chordPath == "" {
+		writeError(w, r, "Chord name required", http.StatusBadRequest)
+		return
+	}
+
+	// Prepare response
+	w.Header().Set("Content-Type", "application/json")
+
+	// Sub-resources act on the chord named before the last slash
+	if i := strings.LastIndex(chordPath, "/"); i > 0 {
+		if handler, ok := chordSubresources[chordPath[i+1:]]; ok {
+			chord := resolveChord(chordPath[:i])
+			if chord == nil {
+				writeError(w, r, "Chord not found", http.StatusNotFound)
+				return
+			}
+
+			handler(w, r, chord)
+			return
+		}
+	}
+
+	// Extending names the extension after the chord, as in /chords/C/extend/7
+	if i := strings.LastIndex(chordPath, "/extend/"); i > 0 {
+		chord := resolveChord(chordPath[:i])
+		if chord == nil {
+			writeError(w, r, "Chord not found", http.StatusNotFound)
+			return
+		}
+
+		getExtendedChord(w, r, chord, chordPath[i+len("/extend/"):])
+		return
+	}
+
+	// A comma-separated list of names returns an array of chords
+	if escapedPath := r.URL.EscapedPath()[len("/chords/"):]; strings.Contains(escapedPath, ",") {
+		getChordList(w, r, escapedPath)
+		return
+	}
+
+	// The format comes from an extension on the name, ?format= or the Accept header, in that order
+	w.Header().Add("Vary", "Accept")
+	chordPath, format, ok := negotiateChordFormat(r, chordPath)
+	if !ok {
+		writeError(w, r, "Invalid format: "+format, http.StatusBadRequest)
+		return
+	}
+	if diagram := r.URL.Query().Get("include_diagram"); diagram != "" && diagram != "svg" {
+		writeError(w, r, "Invalid diagram format: "+diagram, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := requestPositionFilter(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	preferredFrets, err := requestPreferredFrets(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if _, err := requestDisplayTuning(r); err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var chord *ChordWithMeta
+	var via string
+	if r.URL.Query().Get("derive") == "true" {
+		if chord, via, err = resolveOrDerive(chordPath); err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		chord, via = resolveChordVia(chordPath)
+	}
+	if chord == nil {
+		writeError(w, r, "Chord not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("X-Resolved-Via", via)
+
+	// Group the chord's voicings on every instrument instead of returning the one resolved
+	if r.URL.Query().Has("instruments") {
+		if format != "json" {
+			writeError(w, r, "Instrument grouping is only available as JSON", http.StatusNotAcceptable)
+			return
+		}
+		getChordInstruments(w, r, chord)
+		return
+	}
+
+	// Keep only the positions the filters allow
+	if filter != nil {
+		filtered, err := applyPositionFilter([]*ChordWithMeta{chord}, filter)
+		if err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+		if len(filtered) == 0 {
+			writeError(w, r, "No positions match the filters", http.StatusNotFound)
+			return
+		}
+		chord = filtered[0]
+	}
+
+	// Pin the preferred voicing first, if the chord has it
+	if preferredFrets != "" {
+		if chord, err = preferPositions(chord, preferredFrets); err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	renderer := chordFormats[format]
+	data, err := renderer.Render(chord, r)
+	if errors.Is(err, errFormatUnavailable) {
+		writeError(w, r, "Format "+format+" is not available for this chord", http.StatusNotAcceptable)
+		return
+	}
+	if errors.Is(err, errNoteOutOfRange) {
+		writeError(w, r, "Invalid display tuning: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", renderer.ContentType)
+	writeBody(w, data)
+}
+
+// getChordInstruments writes the stored voicings of a chord grouped by instrument, as an object mapping each instrument
+// to the chord's data in each of its tunings. ?instruments= lists the instruments to include, or is empty or "all"
+// for every instrument the chord is stored for. Listed instruments without the chord map to an empty array.
+func getChordInstruments(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	var instruments []string
+	if value := r.URL.Query().Get("instruments"); value != "all" {
+		instruments = splitList(value)
+	}
+
+	grouped := make(map[string][]json.RawMessage)
+	for _, instrument := range instruments {
+		grouped[instrument] = []json.RawMessage{}
+	}
+	for _, voicing := range normalizedMap[chord.NormalizedKey+"|"+chord.NormalizedSuffix] {
+		if instruments != nil && !slices.Contains(instruments, voicing.Instrument) {
+			continue
+		}
+		data, err := renderChord(voicing, r)
+		if err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+		grouped[voicing.Instrument] = append(grouped[voicing.Instrument], json.RawMessage(data))
+	}
+
+	data, err := json.Marshal(grouped)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(data))
+}
+
+// getChordByParts looks up a chord by separate key, suffix and tuning query parameters, e.g. /chords?key=C&suffix=maj7.
+// The parts go straight to the exact and normalized lookups, skipping the name parser and fuzzy matching.
+func getChordByParts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	// Without a key or suffix there's no chord to look up, so list the catalog instead
+	if !query.Has("key") && !query.Has("suffix") {
+		getCatalog(w, r)
+		return
+	}
+
+	key := query.Get("key")
+	if key == "" {
+		writeError(w, r, "Key parameter required", http.StatusBadRequest)
+		return
+	}
+
+	suffix := query.Get("suffix")
+	if suffix == "" {
+		suffix = "major"
+	}
+
+	tuning := query.Get("tuning")
+	if tuning == "" {
+		tuning = defaultTuning
+	}
+
+	chord := chordMap[key+"|"+suffix+"|"+tuning]
+	if chord == nil {
+		for _, candidate := range normalizedMap[normalizeKey(key)+"|"+normalizeSuffix(suffix)] {
+			if candidate.Tuning == tuning {
+				chord = candidate
+				break
+			}
+		}
+	}
+	if chord == nil {
+		writeError(w, r, "Chord not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	data, err := renderChord(chord, r)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, data)
+}
+
+// Page sizes of the catalog listing, selected with ?limit=
+const (
+	catalogDefaultLimit = 100
+	catalogMaxLimit     = 1000
+)
+
+// catalogEntry names a chord in the catalog listing, with its data if include_data=true is set
+type catalogEntry struct {
+	Name   string          `json:"name"`
+	Key    string          `json:"key"`
+	Suffix string          `json:"suffix"`
+	Tuning string          `json:"tuning"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// getCatalog lists every chord in browsing order, by key and then chord type, a page at a time selected with
+// ?limit= and ?offset=. ?tuning= restricts the listing to one tuning. The number of chords in the whole listing
+// is sent in X-Total-Count, so clients can sync the catalog page by page.
+func getCatalog(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := catalogDefaultLimit
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil || limit < 1 || limit > catalogMaxLimit {
+			writeError(w, r, fmt.Sprintf("Invalid limit: %s (must be 1 to %d)", value, catalogMaxLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	offset := 0
+	if value := query.Get("offset"); value != "" {
+		var err error
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			writeError(w, r, "Invalid offset: "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
+	tuning := query.Get("tuning")
+	chords := browseOrder
+	if tuning != "" {
+		chords = nil
+		for _, chord := range browseOrder {
+			if chord.Tuning == tuning {
+				chords = append(chords, chord)
+			}
+		}
+	}
+
+	// An offset past the end is an empty page rather than an error, so a sync loop ends cleanly
+	start := min(offset, len(chords))
+	end := min(start+limit, len(chords))
+
+	includeData := query.Get("include_data") == "true"
+	entries := []catalogEntry{}
+	for _, chord := range chords[start:end] {
+		entry := catalogEntry{Name: chordDisplayName(chord), Key: chord.Key, Suffix: chord.Suffix, Tuning: chord.Tuning}
+		if includeData {
+			entry.Data = json.RawMessage(chord.FullData)
+		}
+		entries = append(entries, entry)
+	}
+
+	response, err := json.Marshal(entries)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(chords)))
+	writeBody(w, string(response))
+}
+
+// getChordList resolves each name in a comma-separated list of escaped chord names.
+// Misses are returned as nulls unless skip_missing=true is set.
+func getChordList(w http.ResponseWriter, r *http.Request, escapedPath string) {
+	names := strings.Split(escapedPath, ",")
+	if len(names) > allLimit {
+		writeError(w, r, fmt.Sprintf("Too many chords requested (max %d)", allLimit), http.StatusBadRequest)
+		return
+	}
+
+	skipMissing := r.URL.Query().Get("skip_missing") == "true"
+
+	results := []json.RawMessage{}
+	for _, escapedName := range names {
+		name, err := url.PathUnescape(escapedName)
+		if err != nil || name == "" {
+			writeError(w, r, "Invalid chord name in list: "+escapedName, http.StatusBadRequest)
+			return
+		}
+
+		chord := resolveChord(name)
+		if chord == nil {
+			if !skipMissing {
+				results = append(results, nil)
+			}
+			continue
+		}
+
+		data, err := renderChord(chord, r)
+		if err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+		results = append(results, json.RawMessage(data))
+	}
+	results = capResults(w, results)
+
+	response, err := json.Marshal(results)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// maxTransposeBatchSize caps the number of chords in one batch transposition
+const maxTransposeBatchSize = 100
+
+// transposeBatch transposes a list of chord names by the same number of semitones, returning the data of
+// each transposed chord in input order. Chords that can't be resolved or whose transposition isn't in
+// the dataset are returned as nulls.
+func transposeBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Chords    []string `json:"chords"`
+		Semitones int      `json:"semitones"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request.Chords) == 0 {
+		writeError(w, r, "Batch must include at least one chord", http.StatusBadRequest)
+		return
+	}
+	if len(request.Chords) > maxTransposeBatchSize {
+		writeError(w, r, fmt.Sprintf("Too many chords in batch (max %d)", maxTransposeBatchSize), http.StatusBadRequest)
+		return
+	}
+
+	names, err := requestNoteNames(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
+	// A named interval replaces the semitone offset, and the transposed names are spelled by letter
+	var interval *musicalInterval
+	if value := r.URL.Query().Get("interval"); value != "" {
+		parsed, err := parseInterval(value)
+		if err != nil {
+			writeError(w, r, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Semitones != 0 {
+			writeError(w, r, "Use either semitones or interval, not both", http.StatusBadRequest)
+			return
+		}
+		interval = &parsed
+		request.Semitones = parsed.semitones
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	results := make([]json.RawMessage, len(request.Chords))
+	for i, name := range request.Chords {
+		chord := resolveChord(strings.TrimSpace(name))
+		if chord == nil {
+			continue
+		}
+
+		transposed := transposeChord(chord, request.Semitones)
+		if transposed == nil {
+			continue
+		}
+
+		data, err := renderChord(transposed, r)
+		if err == nil && interval != nil {
+			data, err = withFields(data, map[string]interface{}{"name": spellTransposed(names, name, transposed, *interval)})
+		}
+		if err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+		results[i] = json.RawMessage(data)
+	}
+
+	response, err := json.Marshal(results)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// musicalInterval is a named interval, measured in letter names and semitones. Both are negative for
+// downward intervals.
+type musicalInterval struct {
+	steps     int // Letter names moved, e.g. 2 for a third
+	semitones int
+}
+
+// intervalSemitones maps interval names to their size in semitones. The number in the name gives the letter steps.
+var intervalSemitones = map[string]int{
+	"P1": 0, "A1": 1,
+	"m2": 1, "M2": 2, "A2": 3,
+	"d3": 2, "m3": 3, "M3": 4,
+	"P4": 5, "A4": 6,
+	"d5": 6, "P5": 7, "A5": 8,
+	"m6": 8, "M6": 9,
+	"d7": 9, "m7": 10, "M7": 11,
+	"P8": 12,
+}
+
+// parseInterval parses an interval name such as "m3" or "P5", with a leading "-" for downward intervals
+func parseInterval(value string) (musicalInterval, error) {
+	name, direction := value, 1
+	if strings.HasPrefix(name, "-") {
+		name, direction = name[1:], -1
+	}
+
+	semitones, ok := intervalSemitones[name]
+	if !ok {
+		return musicalInterval{}, fmt.Errorf("unsupported interval: %s", value)
+	}
+	number, _ := strconv.Atoi(name[1:])
+	return musicalInterval{steps: direction * (number - 1), semitones: direction * semitones}, nil
+}
+
+// letterNames are the natural notes in order, and letterPitches their pitch classes
+const letterNames = "CDEFGAB"
+
+var letterPitches = []int{0, 2, 4, 5, 7, 9, 11}
+
+// spellNote transposes a note by an interval, spelling the result from the letter the interval lands on,
+// so C up a minor second is Db rather than C#. It returns false if the note isn't a letter with accidentals.
+func spellNote(note string, interval musicalInterval) (string, bool) {
+	if note == "" {
+		return "", false
+	}
+	letter := strings.IndexByte(letterNames, note[0])
+	if letter < 0 {
+		return "", false
+	}
+	pitch := letterPitches[letter]
+	for _, c := range note[1:] {
+		switch c {
+		case '#':
+			pitch++
+		case 'b':
+			pitch--
+		default:
+			return "", false
+		}
+	}
+
+	target := ((pitch+interval.semitones)%12 + 12) % 12
+	letter = ((letter+interval.steps)%7 + 7) % 7
+
+	// Pick the accidentals that bring the new letter to the target, the short way around
+	offset := ((target-letterPitches[letter]+6)%12+12)%12 - 6
+	spelled := letterNames[letter : letter+1]
+	if offset > 0 {
+		spelled += strings.Repeat("#", offset)
+	} else {
+		spelled += strings.Repeat("b", -offset)
+	}
+	return spelled, true
+}
+
+// spellTransposed names a chord transposed by an interval, spelling the root and any bass note from the
+// name as it was requested. Notes that can't be spelled, such as German names, are spelled from keySpellings
+// for the chord's quality instead. The notes are then named in the language of names.
+func spellTransposed(names []string, name string, transposed *ChordWithMeta, interval musicalInterval) string {
+	key, suffix := splitChordName(strings.TrimSpace(name))
+	quality, bass := splitSlash(transposed.Suffix)
+	spellings := keySpellings[spellingMode(chordQuality(quality, chordIntervals(quality)))]
+
+	spelled, ok := spellNote(key, interval)
+	if !ok {
+		spelled = transposed.Key
+		if pitchClass := noteIndex(transposed.Key); pitchClass >= 0 {
+			spelled = spellings[pitchClass]
+		}
+	}
+	spelled = localizeNote(names, transposed.Key, spelled) + shortQuality(quality)
+
+	if bass != "" {
+		_, requestedBass := splitSlash(suffix)
+		note, ok := spellNote(requestedBass, interval)
+		if !ok {
+			note = bass
+			if pitchClass := noteIndex(bass); pitchClass >= 0 {
+				note = spellings[pitchClass]
+			}
+		}
+		spelled += "/" + localizeNote(names, bass, note)
+	}
+	return spelled
+}
+
+// localizeNote names a note in the requested language. English keeps the spelling given, while other
+// languages have a single name per pitch class, as in spellKey.
+func localizeNote(names []string, note, spelled string) string {
+	if pitchClass := noteIndex(note); pitchClass >= 0 && !slices.Equal(names, noteNames) {
+		return names[pitchClass]
+	}
+	return spelled
+}
+
+// getChordNeighbors returns the chords one and two semitones above and below a chord with the same suffix
+func getChordNeighbors(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	type neighbor struct {
+		Offset int             `json:"offset"`
+		Chord  json.RawMessage `json:"chord"`
+	}
+
+	// Only include neighbors that exist in the dataset
+	neighbors := []neighbor{}
+	for _, offset := range []int{-2, -1, 1, 2} {
+		if match := transposeChord(chord, offset); match != nil {
+			neighbors = append(neighbors, neighbor{Offset: offset, Chord: json.RawMessage(match.FullData)})
+		}
+	}
+
+	response, err := json.Marshal(neighbors)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// primaryFrets returns the frets of a chord's first position, its primary voicing, or nil if it has none
+func primaryFrets(chord *ChordWithMeta) []int {
+	if len(chord.Positions) == 0 {
+		return nil
+	}
+	if frets := positionField(chord.Positions[0], "frets"); frets != "" {
+		return parseFrets(frets)
+	}
+	return nil
+}
+
+// getSimilarChords returns the chords whose primary voicing is closest to a chord's, ranked by the number of
+// strings fretted differently. Only chords in the same tuning are compared, and ties keep browsing order.
+func getSimilarChords(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	type similar struct {
+		Distance int             `json:"distance"`
+		Chord    json.RawMessage `json:"chord"`
+	}
+
+	reference := primaryFrets(chord)
+	if reference == nil {
+		writeError(w, r, "Chord has no voicing to compare", http.StatusNotFound)
+		return
+	}
+
+	matches := []similar{}
+	for _, other := range browseOrder {
+		frets := primaryFrets(other)
+		if other == chord || other.Tuning != chord.Tuning || len(frets) != len(reference) {
+			continue
+		}
+
+		distance := 0
+		for i := range frets {
+			if frets[i] != reference[i] {
+				distance++
+			}
+		}
+		matches = append(matches, similar{Distance: distance, Chord: json.RawMessage(other.FullData)})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Distance < matches[j].Distance })
+	if len(matches) > similarLimit {
+		matches = matches[:similarLimit]
+	}
+
+	response, err := json.Marshal(matches)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// commonToneLimit caps the chords returned by /chords/{name}/common-tone
+const commonToneLimit = 20
+
+// getCommonToneChords returns the chords sharing at least ?min_shared= pitch classes (default 1) with a chord, most
+// shared first, for exploring smooth voice leading. Only chords in the same tuning are compared, and chords sharing
+// as many tones keep browsing order.
+func getCommonToneChords(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	type commonTone struct {
+		Shared int             `json:"shared"`
+		Tones  []string        `json:"tones"`
+		Chord  json.RawMessage `json:"chord"`
+	}
+
+	minShared := 1
+	if value := r.URL.Query().Get("min_shared"); value != "" {
+		var err error
+		if minShared, err = strconv.Atoi(value); err != nil || minShared < 1 {
+			writeError(w, r, "Invalid min_shared: "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
+	reference := chordPitchClasses(chord)
+	if reference == nil {
+		writeError(w, r, "Chord tones are unknown for this chord", http.StatusNotFound)
+		return
+	}
+
+	matches := []commonTone{}
+	for _, other := range browseOrder {
+		if other == chord || other.Tuning != chord.Tuning {
+			continue
+		}
+
+		tones := []string{}
+		for _, pitchClass := range chordPitchClasses(other) {
+			if slices.Contains(reference, pitchClass) {
+				tones = append(tones, noteNames[pitchClass])
+			}
+		}
+		if len(tones) >= minShared {
+			matches = append(matches, commonTone{Shared: len(tones), Tones: tones, Chord: json.RawMessage(other.FullData)})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Shared > matches[j].Shared })
+	if len(matches) > commonToneLimit {
+		matches = matches[:commonToneLimit]
+	}
+
+	response, err := json.Marshal(matches)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// circleNeighbors are the neighbors of a chord on the circle of fifths, by their offset in semitones
+var circleNeighbors = []struct {
+	relation  string
+	semitones int
+}{
+	{"dominant", 7},    // A fifth above
+	{"subdominant", 5}, // A fifth below
+}
+
+// getCircleNeighbors returns the chords with the same suffix a fifth above and below a chord, its neighbors on the circle of fifths
+func getCircleNeighbors(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	type neighbor struct {
+		Relation string          `json:"relation"`
+		Chord    json.RawMessage `json:"chord"`
+	}
+
+	// Only include neighbors that exist in the dataset
+	neighbors := []neighbor{}
+	for _, circle := range circleNeighbors {
+		if match := transposeChord(chord, circle.semitones); match != nil {
+			neighbors = append(neighbors, neighbor{Relation: circle.relation, Chord: json.RawMessage(match.FullData)})
+		}
+	}
+
+	response, err := json.Marshal(neighbors)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// inversionDegrees names the inversion with each scale degree of the chord in the bass
+var inversionDegrees = map[int]string{1: "root", 3: "first", 5: "second", 7: "third"}
+
+// inversionOrder lists the inversion groups in the order they are returned
+var inversionOrder = []string{"root", "first", "second", "third", "other", "unknown"}
+
+// positionInversion names the inversion a position is played in from its lowest sounded note: "root", "first",
+// "second" or "third" for the root, third, fifth or seventh in the bass, "other" for any other note, and "unknown"
+// if the note can't be worked out, e.g. in a tuning without known open string pitches. It returns the bass note too.
+// openNotes are the MIDI notes of the open strings, nil if they aren't known.
+func positionInversion(openNotes []int, position interface{}, root int, intervals []string) (string, string) {
+	frets := parseFrets(positionField(position, "frets"))
+	if openNotes == nil || len(frets) != len(openNotes) {
+		return "unknown", ""
+	}
+
+	lowest := -1
+	for i, fret := range frets {
+		if fret >= 0 && (lowest < 0 || openNotes[i]+fret < lowest) {
+			lowest = openNotes[i] + fret
+		}
+	}
+	if lowest < 0 {
+		return "unknown", ""
+	}
+
+	bass := lowest % 12
+	for _, interval := range intervals {
+		semitones, ok := intervalSemitonesAbove(interval)
+		if !ok || (root+semitones)%12 != bass {
+			continue
+		}
+		degree, _ := strconv.Atoi(strings.TrimLeft(interval, "b#"))
+		if inversion, ok := inversionDegrees[degree]; ok {
+			return inversion, noteNames[bass]
+		}
+	}
+	return "other", noteNames[bass]
+}
+
+// getChordInversions groups a chord's positions by the inversion they are played in, from the chord tone in the bass.
+// ?display_tuning= works the bass notes out as if the frets were played on another tuning.
+func getChordInversions(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	displayTuning, err := requestDisplayTuning(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	openNotes, _ := chordOpenNotes(chord, displayTuning)
+
+	root := noteIndex(chord.NormalizedKey)
+	intervals := chordIntervals(chord.Suffix)
+	if root < 0 || intervals == nil {
+		writeError(w, r, "Inversions are not available for this chord", http.StatusNotFound)
+		return
+	}
+
+	type inversionGroup struct {
+		Inversion string        `json:"inversion"`
+		Bass      []string      `json:"bass"`
+		Positions []interface{} `json:"positions"`
+	}
+	groups := make(map[string]*inversionGroup)
+	for _, position := range chord.Positions {
+		inversion, bass := positionInversion(openNotes, position, root, intervals)
+		group, ok := groups[inversion]
+		if !ok {
+			group = &inversionGroup{Inversion: inversion, Bass: []string{}}
+			groups[inversion] = group
+		}
+		if bass != "" && !slices.Contains(group.Bass, bass) {
+			group.Bass = append(group.Bass, bass)
+		}
+		group.Positions = append(group.Positions, position)
+	}
+
+	response := struct {
+		Key        string            `json:"key"`
+		Suffix     string            `json:"suffix"`
+		Inversions []*inversionGroup `json:"inversions"`
+	}{Key: chord.Key, Suffix: chord.Suffix, Inversions: []*inversionGroup{}}
+	for _, inversion := range inversionOrder {
+		if group, ok := groups[inversion]; ok {
+			response.Inversions = append(response.Inversions, group)
+		}
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(data))
+}
+
+// getRelativeChord returns the relative minor of a major chord, a minor third below it, or the relative
+// major of a minor chord, a minor third above it
+func getRelativeChord(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	var semitones int
+	var suffix string
+	switch chord.NormalizedSuffix {
+	case "major":
+		semitones, suffix = -3, "minor"
+	case "minor":
+		semitones, suffix = 3, "major"
+	default:
+		writeError(w, r, "Only major and minor chords have a relative", http.StatusNotFound)
+		return
+	}
+
+	key, ok := transposeKey(chord.NormalizedKey, semitones)
+	relative := normalizedChord(key+"|"+suffix, chord.Tuning)
+	if !ok || relative == nil {
+		writeError(w, r, "Relative chord not found", http.StatusNotFound)
+		return
+	}
+
+	data, err := renderChord(relative, r)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, data)
+}
+
+// extensionRung is a step on an extension ladder: the extension that reaches it and the resulting suffix
+type extensionRung struct {
+	extension string
+	suffix    string
+}
+
+// extensionLadders are the chord families that extensions climb, each from its triad up. A chord is extended
+// by moving up its ladder to the rung of an extension and simplified by moving down one rung.
+var extensionLadders = [][]extensionRung{
+	{{"", "major"}, {"7", "7"}, {"9", "9"}, {"11", "11"}, {"13", "13"}},
+	{{"", "major"}, {"maj7", "maj7"}, {"maj9", "maj9"}, {"maj11", "maj11"}, {"maj13", "maj13"}},
+	{{"", "minor"}, {"7", "m7"}, {"9", "m9"}, {"11", "m11"}},
+	{{"", "minor"}, {"maj7", "mmaj7"}, {"maj9", "mmaj9"}, {"maj11", "mmaj11"}},
+}
+
+// extendSuffix returns the suffix of a chord extended with an extension, which must be above the suffix on its
+// ladder. Extensions can be named by the resulting suffix as well, e.g. "m9" as well as "9" for a minor chord.
+func extendSuffix(suffix, extension string) (string, bool) {
+	for _, ladder := range extensionLadders {
+		for i, rung := range ladder {
+			if rung.suffix != suffix {
+				continue
+			}
+			for _, higher := range ladder[i+1:] {
+				if higher.extension == extension || higher.suffix == extension {
+					return higher.suffix, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// simplifySuffix returns the suffix one rung below a suffix on its ladder, stripping its top extension
+func simplifySuffix(suffix string) (string, bool) {
+	for _, ladder := range extensionLadders {
+		for i, rung := range ladder {
+			if i > 0 && rung.suffix == suffix {
+				return ladder[i-1].suffix, true
+			}
+		}
+	}
+	return "", false
+}
+
+// getExtendedChord returns the chord with the same root as a chord, extended with an extension such as "7" or "9"
+func getExtendedChord(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta, extension string) {
+	suffix, ok := extendSuffix(chord.NormalizedSuffix, extension)
+	if !ok {
+		writeError(w, r, fmt.Sprintf("Can't extend a %s chord with %s", chord.NormalizedSuffix, extension), http.StatusBadRequest)
+		return
+	}
+
+	writeChordWithSuffix(w, r, chord, suffix, "Extended chord not found")
+}
+
+// getSimplifiedChord returns the chord with the same root as a chord and its top extension stripped, e.g. C7 from C9
+func getSimplifiedChord(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	suffix, ok := simplifySuffix(chord.NormalizedSuffix)
+	if !ok {
+		writeError(w, r, "Chord has no extension to strip", http.StatusNotFound)
+		return
+	}
+
+	writeChordWithSuffix(w, r, chord, suffix, "Simplified chord not found")
+}
+
+// writeChordWithSuffix writes the chord with the same key as a chord and another suffix, or a 404 with the
+// given message if the dataset doesn't have it
+func writeChordWithSuffix(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta, suffix, notFound string) {
+	other := normalizedChord(chord.NormalizedKey+"|"+suffix, chord.Tuning)
+	if other == nil {
+		writeError(w, r, notFound, http.StatusNotFound)
+		return
+	}
+
+	data, err := renderChord(other, r)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, data)
+}
+
+// sitemapPageSize is the number of chords in each page of the sitemap
+const sitemapPageSize = 1000
+
+// sitemapEntry is a chord's canonical name and the URL it resolves at
+type sitemapEntry struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// buildSitemap lists the shortest name that resolves to each chord, in browsing order, and indexes the same names
+// for autocompletion. Only chords in the default tuning can be looked up by name, so other tunings are left out.
+func buildSitemap() {
+	sitemap = []sitemapEntry{}
+	nameIndex = []nameEntry{}
+	for _, chord := range browseOrder {
+		if chord.Tuning != defaultTuning {
+			continue
+		}
+
+		// Prefer the short spelling, e.g. "C" and "Cm" over "Cmajor" and "Cminor"
+		candidates := []string{chord.Key + chord.Suffix}
+		switch chord.Suffix {
+		case "major":
+			candidates = append([]string{chord.Key}, candidates...)
+		case "minor":
+			candidates = append([]string{chord.Key + "m"}, candidates...)
+		}
+
+		for _, name := range candidates {
+			if resolveChord(name) == chord {
+				path := &url.URL{Path: "/chords/" + name}
+				sitemap = append(sitemap, sitemapEntry{Name: name, URL: path.EscapedPath()})
+				nameIndex = append(nameIndex, nameEntry{name: name, chord: chord})
+				break
+			}
+		}
+	}
+
+	// Autocompletion scans the names in order
+	sort.Slice(nameIndex, func(i, j int) bool { return nameIndex[i].name < nameIndex[j].name })
+}
+
+// nameEntry is a chord's canonical name in the autocompletion index
+type nameEntry struct {
+	name  string
+	chord *ChordWithMeta
+}
+
+// autocompleteLimit caps the names returned by /autocomplete/
+const autocompleteLimit = 10
+
+// getAutocomplete returns up to autocompleteLimit chord names starting with a prefix, for type-ahead. Names whose
+// root is the prefix's own root come first, then common chord types (see getChordTypePriority), then by name.
+// A flat root is matched against the stored sharp names, and the names are returned spelled with the flat.
+func getAutocomplete(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.TrimSpace(r.URL.Path[len("/autocomplete/"):])
+	if prefix == "" {
+		writeError(w, r, "Prefix required", http.StatusBadRequest)
+		return
+	}
+	prefix = strings.ToUpper(prefix[:1]) + prefix[1:]
+
+	// Names are stored with sharp roots, so scan for the sharp spelling of a flat root and respell the matches
+	key, _ := splitChordName(prefix)
+	scan, root := prefix, ""
+	if alt, ok := enharmonicMap[strings.ToUpper(key)]; ok {
+		scan, root = alt+prefix[len(key):], alt
+	}
+
+	type candidate struct {
+		name     string
+		sameRoot bool
+		priority int
+	}
+	var candidates []candidate
+	for i := sort.Search(len(nameIndex), func(i int) bool { return nameIndex[i].name >= scan }); i < len(nameIndex); i++ {
+		entry := nameIndex[i]
+		if !strings.HasPrefix(entry.name, scan) {
+			break
+		}
+		name := entry.name
+		if root != "" {
+			name = key + name[len(root):]
+		}
+		candidates = append(candidates, candidate{name, entry.chord.NormalizedKey == normalizeKey(key), getChordTypePriority(entry.chord.Suffix)})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.sameRoot != b.sameRoot {
+			return a.sameRoot
+		}
+		return a.priority < b.priority
+	})
+
+	names := []string{}
+	for _, candidate := range candidates[:min(len(candidates), autocompleteLimit)] {
+		names = append(names, candidate.name)
+	}
+
+	data, err := json.Marshal(names)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, string(data))
+}
+
+// getSitemap returns a page of the canonical URLs of all chords, selected with ?page= (default 1)
+func getSitemap(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	page := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			writeError(w, r, "Invalid page: "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
+	pages := (len(sitemap) + sitemapPageSize - 1) / sitemapPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page > pages {
+		writeError(w, r, "Page not found", http.StatusNotFound)
+		return
+	}
+
+	start := (page - 1) * sitemapPageSize
+	end := start + sitemapPageSize
+	if end > len(sitemap) {
+		end = len(sitemap)
+	}
+
+	response := struct {
+		Total  int            `json:"total"`
+		Page   int            `json:"page"`
+		Pages  int            `json:"pages"`
+		Next   string         `json:"next,omitempty"`
+		Chords []sitemapEntry `json:"chords"`
+	}{Total: len(sitemap), Page: page, Pages: pages, Chords: sitemap[start:end]}
+	if page < pages {
+		response.Next = fmt.Sprintf("/sitemap.json?page=%d", page+1)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprint(w, string(data))
+}
+
+// containingPageSize is the number of chords in each page of /containing/
+const containingPageSize = 50
+
+// getChordsContaining returns a page of the chords whose notes include a pitch class, sorted by root,
+// selected with ?page= (default 1). The note may be written with sharps or flats.
+func getChordsContaining(w http.ResponseWriter, r *http.Request) {
+	note := strings.TrimSpace(r.URL.Path[len("/containing/"):])
+	if note == "" {
+		writeError(w, r, "Note required", http.StatusBadRequest)
+		return
+	}
+	pitchClass := noteIndex(note)
+	if pitchClass < 0 {
+		writeError(w, r, "Invalid note: "+note, http.StatusBadRequest)
+		return
+	}
+
+	page := 1
+	if value := r.URL.Query().Get("page"); value != "" {
+		var err error
+		if page, err = strconv.Atoi(value); err != nil || page < 1 {
+			writeError(w, r, "Invalid page: "+value, http.StatusBadRequest)
+			return
+		}
+	}
+
+	chords := pitchClassMap[pitchClass]
+	pages := (len(chords) + containingPageSize - 1) / containingPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if page > pages {
+		writeError(w, r, "Page not found", http.StatusNotFound)
+		return
+	}
+
+	start := (page - 1) * containingPageSize
+	end := min(start+containingPageSize, len(chords))
+	results := []json.RawMessage{}
+	for _, chord := range chords[start:end] {
+		results = append(results, json.RawMessage(chord.FullData))
+	}
+
+	response := struct {
+		Note   string            `json:"note"`
+		Total  int               `json:"total"`
+		Page   int               `json:"page"`
+		Pages  int               `json:"pages"`
+		Next   string            `json:"next,omitempty"`
+		Chords []json.RawMessage `json:"chords"`
+	}{Note: noteNames[pitchClass], Total: len(chords), Page: page, Pages: pages, Chords: results}
+	if page < pages {
+		response.Next = fmt.Sprintf("/containing/%s?page=%d", url.PathEscape(noteNames[pitchClass]), page+1)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, string(data))
+}
+
+// getNextChord returns the chord after a chord in browsing order
+func getNextChord(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	browseChord(w, r, chord, 1)
+}
+
+// getPrevChord returns the chord before a chord in browsing order
+func getPrevChord(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta) {
+	browseChord(w, r, chord, -1)
+}
+
+// browseChord returns the chord step places away from a chord in browsing order
+func browseChord(w http.ResponseWriter, r *http.Request, chord *ChordWithMeta, step int) {
+	i := browseIndex[chord] + step
+	if i < 0 || i >= len(browseOrder) {
+		if !browseWrap {
+			writeError(w, r, "No more chords", http.StatusNotFound)
+			return
+		}
+		i = (i + len(browseOrder)) % len(browseOrder)
+	}
+
+	data, err := renderChord(browseOrder[i], r)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, data)
+}
+
+// getDailyChord returns the chord of the day, picked from the browsing order by a hash of the date, so every client
+// gets the same chord all day. The day is today in UTC unless ?date= gives one as YYYY-MM-DD.
+func getDailyChord(w http.ResponseWriter, r *http.Request) {
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = time.Now().UTC().Format(time.DateOnly)
+	} else if _, err := time.Parse(time.DateOnly, date); err != nil {
+		writeError(w, r, "Invalid date: "+date+" (expected YYYY-MM-DD)", http.StatusBadRequest)
+		return
+	}
+
+	if len(browseOrder) == 0 {
+		writeError(w, r, "Chord not found", http.StatusNotFound)
+		return
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(date))
+	chord := browseOrder[hash.Sum32()%uint32(len(browseOrder))]
+
+	w.Header().Set("Content-Type", "application/json")
+	data, err := renderChord(chord, r)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, data)
+}
+
+// maxProgressionLength caps the number of chords in a progression analysis
+const maxProgressionLength = 64
+
+// scaleDegree is a diatonic chord of a mode: its root's offset from the tonic and its triad quality
+type scaleDegree struct {
+	offset  int
+	quality string
+}
+
+// modeDegrees lists the diatonic chords of the major and natural minor modes
+var modeDegrees = map[string][]scaleDegree{
+	"major": {{0, "major"}, {2, "minor"}, {4, "minor"}, {5, "major"}, {7, "major"}, {9, "minor"}, {11, "diminished"}},
+	"minor": {{0, "minor"}, {2, "diminished"}, {3, "major"}, {5, "minor"}, {7, "minor"}, {8, "major"}, {10, "major"}},
+}
+
+var romanNumerals = []string{"I", "II", "III", "IV", "V", "VI", "VII"}
+
+// progressionChord is a chord of a progression parsed for analysis
+type progressionChord struct {
+	name      string
+	root      int
+	quality   string
+	intervals []string
+}
+
+// chordQuality returns the triad quality of a suffix: major, minor, diminished or augmented
+func chordQuality(suffix string, intervals []string) string {
+	// Guess from the spelling of suffixes without a known formula
+	if intervals == nil {
+		quality := strings.ToLower(suffix)
+		switch {
+		case strings.HasPrefix(quality, "dim"):
+			return "diminished"
+		case strings.HasPrefix(quality, "aug"):
+			return "augmented"
+		case strings.HasPrefix(suffix, "m") && !strings.HasPrefix(quality, "maj"):
+			return "minor"
+		}
+		return "major"
+	}
+
+	switch {
+	case hasInterval(intervals, "b3") && hasInterval(intervals, "b5"):
+		return "diminished"
+	case hasInterval(intervals, "b3"):
+		return "minor"
+	case hasInterval(intervals, "#5"):
+		return "augmented"
+	}
+	return "major"
+}
+
+// hasInterval reports whether a chord formula contains an interval
+func hasInterval(intervals []string, interval string) bool {
+	for _, i := range intervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// romanNumeral returns the Roman numeral of a chord relative to a key, and whether the chord is diatonic to it
+func romanNumeral(chord progressionChord, tonic int, mode string) (string, bool) {
+	degrees := modeDegrees[mode]
+	offset := ((chord.root-tonic)%12 + 12) % 12
+
+	// Chromatic roots are written as the flattened degree above or the sharpened degree below
+	degree, accidental, inKey := -1, "", false
+	for i, d := range degrees {
+		if d.offset == offset {
+			degree, inKey = i, d.quality == chord.quality
+		}
+	}
+	if degree < 0 {
+		for i, d := range degrees {
+			if d.offset == offset+1 {
+				degree, accidental = i, "b"
+			}
+		}
+	}
+	if degree < 0 {
+		for i, d := range degrees {
+			if d.offset == offset-1 {
+				degree, accidental = i, "#"
+			}
+		}
+	}
+
+	numeral := romanNumerals[degree]
+	if chord.quality == "minor" || chord.quality == "diminished" {
+		numeral = strings.ToLower(numeral)
+	}
+
+	seventh := hasInterval(chord.intervals, "7") || hasInterval(chord.intervals, "b7") || hasInterval(chord.intervals, "bb7")
+	switch {
+	case chord.quality == "diminished" && hasInterval(chord.intervals, "b7"):
+		numeral += "ø"
+	case chord.quality == "diminished":
+		numeral += "°"
+	case chord.quality == "augmented":
+		numeral += "+"
+	}
+	if seventh {
+		numeral += "7"
+	}
+
+	return accidental + numeral, inKey
+}
+
+// detectKey finds the major or minor key that the most chords of a progression fit.
+// Ties go to keys whose tonic chord starts or ends the progression, then to major keys.
+func detectKey(chords []progressionChord) (int, string) {
+	bestTonic, bestMode, bestScore := 0, "major", -1
+	for tonic := 0; tonic < 12; tonic++ {
+		for _, mode := range []string{"major", "minor"} {
+			score := 0
+			for _, chord := range chords {
+				if _, inKey := romanNumeral(chord, tonic, mode); inKey {
+					score += 4
+				}
+			}
+
+			isTonic := func(chord progressionChord) bool {
+				return chord.root == tonic && chord.quality == modeDegrees[mode][0].quality
+			}
+			if isTonic(chords[0]) {
+				score += 2
+			}
+			if isTonic(chords[len(chords)-1]) {
+				score++
+			}
+
+			if score > bestScore {
+				bestTonic, bestMode, bestScore = tonic, mode, score
+			}
+		}
+	}
+	return bestTonic, bestMode
+}
+
+// parseKeyName parses a key like "C", "F#m" or "Bbm" into its tonic and mode
+func parseKeyName(name string) (int, string, bool) {
+	key, suffix := splitChordName(name)
+	tonic := noteIndex(key)
+	if tonic < 0 {
+		return 0, "", false
+	}
+
+	switch normalizeSuffix(suffix) {
+	case "major":
+		return tonic, "major", true
+	case "minor":
+		return tonic, "minor", true
+	}
+	return 0, "", false
+}
+
+// fingeringProblem is an inconsistency found in a position by validateChord
+type fingeringProblem struct {
+	Position int    `json:"position"`         // Numbered from 1 in the order of the positions
+	String   int    `json:"string,omitempty"` // Numbered from the highest string (1) down, omitted for the whole position
+	Problem  string `json:"problem"`
+}
+
+// checkFingers cross-checks a position's fingers against its frets, string by string: a finger on a muted or open
+// string is a problem, as is a fretted string with no finger unless a barre covers its fret. Fingers are optional,
+// so a position without them has no problems. Only the String and Problem fields of the results are set.
+func checkFingers(frets, fingers string, barres []int) []fingeringProblem {
+	if fingers == "" {
+		return nil
+	}
+
+	parsed := parseFrets(frets)
+	if len(fingers) != len(parsed) {
+		return []fingeringProblem{{Problem: fmt.Sprintf("fingers lists %d strings but frets lists %d", len(fingers), len(parsed))}}
+	}
+
+	var problems []fingeringProblem
+	for i, fret := range parsed {
+		finger := fingers[i]
+		fingered := finger != '0' && finger != 'x' && finger != 'X'
+		problem := ""
+		switch {
+		case fret < 0 && fingered:
+			problem = fmt.Sprintf("finger %c on a muted string", finger)
+		case fret == 0 && fingered:
+			problem = fmt.Sprintf("finger %c on an open string", finger)
+		case fret > 0 && !fingered && !slices.Contains(barres, fret):
+			problem = fmt.Sprintf("fret %d has no finger and no barre", fret)
+		}
+		if problem != "" {
+			problems = append(problems, fingeringProblem{String: len(parsed) - i, Problem: problem})
+		}
+	}
+	return problems
+}
+
+// validateChord checks a chord posted in the dataset's JSON format and reports the problems in its positions,
+// such as fingers that don't match the frets, as {"valid": bool, "problems": [...]}
+func validateChord(w http.ResponseWriter, r *http.Request) {
+	var chord struct {
+		Positions []struct {
+			Frets   string `json:"frets"`
+			Fingers string `json:"fingers"`
+			Barres  string `json:"barres"`
+		} `json:"positions"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&chord); err != nil {
+		writeError(w, r, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	problems := []fingeringProblem{}
+	for i, position := range chord.Positions {
+		barres, err := parseBarres(position.Barres)
+		if err != nil {
+			problems = append(problems, fingeringProblem{Position: i + 1, Problem: err.Error()})
+		}
+		for _, problem := range checkFingers(position.Frets, position.Fingers, barres) {
+			problem.Position = i + 1
+			problems = append(problems, problem)
+		}
+	}
+
+	response, err := json.Marshal(map[string]interface{}{"valid": len(problems) == 0, "problems": problems})
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, string(response))
+}
+
+// analyzeProgression returns a Roman numeral analysis of a chord progression posted as {"chords": [...]},
+// relative to the key given in ?key= or else detected from the chords
+func analyzeProgression(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	names, err := requestNoteNames(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Add("Vary", "Accept-Language")
+
+	var request struct {
+		Chords []string `json:"chords"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, r, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(request.Chords) == 0 {
+		writeError(w, r, "Progression must include at least one chord", http.StatusBadRequest)
+		return
+	}
+	if len(request.Chords) > maxProgressionLength {
+		writeError(w, r, fmt.Sprintf("Too many chords in progression (max %d)", maxProgressionLength), http.StatusBadRequest)
+		return
+	}
+
+	// Analysis only needs each chord's root and formula, so chords don't have to be in the dataset
+	chords := make([]progressionChord, len(request.Chords))
+	for i, name := range request.Chords {
+		key, suffix := splitChordName(strings.TrimSpace(name))
+		root := noteIndex(key)
+		if root < 0 {
+			writeError(w, r, "Invalid chord name in progression: "+name, http.StatusBadRequest)
+			return
+		}
+
+		intervals := chordIntervals(suffix)
+		chords[i] = progressionChord{name: name, root: root, quality: chordQuality(suffix, intervals), intervals: intervals}
+	}
+
+	tonic, mode, detected := 0, "", false
+	if keyName := r.URL.Query().Get("key"); keyName != "" {
+		var ok bool
+		if tonic, mode, ok = parseKeyName(keyName); !ok {
+			writeError(w, r, "Invalid key: "+keyName, http.StatusBadRequest)
+			return
+		}
+	} else {
+		tonic, mode = detectKey(chords)
+		detected = true
+	}
+
+	type analyzedChord struct {
+		Name    string `json:"name"`
+		Numeral string `json:"numeral"`
+		InKey   bool   `json:"in_key"`
+	}
+	analysis := struct {
+		Key      string          `json:"key"`
+		Mode     string          `json:"mode"`
+		Detected bool            `json:"detected"`
+		Chords   []analyzedChord `json:"chords"`
+	}{Key: spellKey(names, tonic, mode), Mode: mode, Detected: detected}
+
+	for _, chord := range chords {
+		numeral, inKey := romanNumeral(chord, tonic, mode)
+		analysis.Chords = append(analysis.Chords, analyzedChord{Name: chord.name, Numeral: numeral, InKey: inKey})
+	}
+
+	response, err := json.Marshal(analysis)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, string(response))
+}
+
+// resolveChord finds the chord best matching a chord name, or nil if there is none
+func resolveChord(chordPath string) *ChordWithMeta {
+	chord, _ := resolveChordVia(chordPath)
+	return chord
+}
 
-		// Add to normalized map
-		normalizedKey := chord.NormalizedKey
-		normalizedSuffix := chord.NormalizedSuffix
-		normalizedMapKey := normalizedKey + "|" + normalizedSuffix
-		normalizedMap[normalizedMapKey] = append(normalizedMap[normalizedMapKey], chord)
+// resolution is a resolved chord along with the rule that matched it
+type resolution struct {
+	chord *ChordWithMeta
+	via   string
+}
 
-		// Index by fingering patterns
-		for _, posInterface := range chord.Positions {
-			// Convert to map to access fields
-			if posMap, ok := posInterface.(map[string]interface{}); ok {
-				if fretsValue, ok := posMap["frets"]; ok {
-					if frets, ok := fretsValue.(string); ok {
-						fingeringMap[frets] = append(fingeringMap[frets], chord)
-					}
+// resolveChordVia resolves a chord name like resolveChord and also describes the rule that matched,
+// see describeResolution
+func resolveChordVia(chordPath string) (*ChordWithMeta, string) {
+	// Use the lookup resolved at startup if there is one
+	if warm, ok := warmCache[chordPath]; ok {
+		return warm.chord, warm.via
+	}
+
+	// Try each configured strategy in order, returning the first hit
+	key, suffix := splitChordName(chordPath)
+	name := key + suffix
+	for _, strategy := range resolveOrder {
+		if chord := resolveStrategies[strategy](name, key, suffix); chord != nil {
+			return chord, describeResolution(strategy, key, suffix, chord)
+		}
+	}
+
+	return nil, ""
+}
+
+// parseDiagnostics describes how a chord name is parsed and normalized, for /parse/
+type parseDiagnostics struct {
+	Name             string `json:"name"`
+	Root             string `json:"root"`
+	Suffix           string `json:"suffix"`
+	NormalizedKey    string `json:"normalized_key"`
+	NormalizedSuffix string `json:"normalized_suffix"`
+	Exists           bool   `json:"exists"`
+	ResolvedVia      string `json:"resolved_via,omitempty"`
+	MatchKey         string `json:"match_key,omitempty"`
+	MatchSuffix      string `json:"match_suffix,omitempty"`
+}
+
+// parseChordName reports how a chord name is parsed into a root and suffix, how those normalize, and which chord,
+// if any, the name resolves to as on /chords/. It answers 200 whether or not the name resolves.
+func parseChordName(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Path[len("/parse/"):]
+	if name == "" {
+		writeError(w, r, "Chord name required", http.StatusBadRequest)
+		return
+	}
+
+	key, suffix := splitChordName(name)
+	diagnostics := parseDiagnostics{
+		Name:             name,
+		Root:             key,
+		Suffix:           suffix,
+		NormalizedKey:    normalizeKey(key),
+		NormalizedSuffix: normalizeSuffix(suffix),
+	}
+	if chord, via := resolveChordVia(name); chord != nil {
+		diagnostics.Exists = true
+		diagnostics.ResolvedVia = via
+		diagnostics.MatchKey = chord.Key
+		diagnostics.MatchSuffix = chord.Suffix
+	}
+
+	data, err := json.Marshal(diagnostics)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	writeBody(w, string(data))
+}
+
+// describeResolution describes how a strategy took the queried key and suffix to a chord, for the X-Resolved-Via header.
+// Exact and fuzzy matches are named after their strategy. Otherwise each rewrite that fired is listed, e.g.
+// "enharmonic:Bb→A#, suffix-alias:M→major", where suffix-alias rewrites come from the built-in aliases and alias
+// rewrites from the chord_aliases table. Short spellings such as "" for major and "m" for minor count as exact.
+func describeResolution(strategy, key, suffix string, chord *ChordWithMeta) string {
+	if strategy == "exact" || strategy == "fuzzy" {
+		return strategy
+	}
+
+	var rules []string
+	if key != chord.Key {
+		rule := "enharmonic"
+		if key == "H" {
+			rule = "german"
+		}
+		rules = append(rules, rule+":"+key+"→"+chord.Key)
+	}
+
+	quality, bass := splitSlash(suffix)
+	chordQuality, chordBass := splitSlash(chord.Suffix)
+	if quality != chordQuality && quality != shortQuality(chordQuality) {
+		rule := "suffix-alias"
+		if strategy == "alias" {
+			rule = "alias"
+		}
+		rules = append(rules, rule+":"+quality+"→"+chordQuality)
+	}
+	if bass != chordBass {
+		rules = append(rules, "enharmonic:"+bass+"→"+chordBass)
+	}
+
+	if len(rules) == 0 {
+		return "exact"
+	}
+	return strings.Join(rules, ", ")
+}
+
+// resolveOrDerive resolves a chord name like resolveChordVia, except that a chord that isn't stored is derived
+// from a movable shape before falling back to the fuzzy strategy, which would return a different chord.
+// Derived chords are described as "derived".
+func resolveOrDerive(chordPath string) (*ChordWithMeta, string, error) {
+	key, suffix := splitChordName(chordPath)
+	name := key + suffix
+	for _, strategy := range resolveOrder {
+		if strategy == "fuzzy" {
+			continue
+		}
+		if chord := resolveStrategies[strategy](name, key, suffix); chord != nil {
+			return chord, describeResolution(strategy, key, suffix, chord), nil
+		}
+	}
+
+	if chord, err := deriveChord(key, suffix); chord != nil || err != nil {
+		return chord, "derived", err
+	}
+	chord, via := resolveChordVia(chordPath)
+	return chord, via, nil
+}
+
+// splitChordName parses a chord name into its key and suffix, flattening any parenthesized extensions
+func splitChordName(name string) (string, string) {
+	var key, suffix string
+	for i, c := range name {
+		// A leading H is the German name for B, resolved through aliases built with build_db -german-aliases
+		if i == 0 && c == 'H' {
+			continue
+		}
+		if !((c >= 'A' && c <= 'G') || c == '#' || c == 'b') {
+			key = name[:i]
+			suffix = name[i:]
+			break
+		}
+	}
+	if key == "" {
+		key = name
+		suffix = ""
+	}
+	return key, flattenParentheses(suffix)
+}
+
+// parenthesesReplacer strips the punctuation lead sheets put around chord extensions
+var parenthesesReplacer = strings.NewReplacer("(", "", ")", "", ",", "", " ", "")
+
+// flattenParentheses flattens parenthesized extensions, e.g. "7(b9,#11)" becomes "7b9#11"
+func flattenParentheses(s string) string {
+	return parenthesesReplacer.Replace(s)
+}
+
+// resolveStrategies are the ways resolveChord can match a chord name, keyed by their -resolve-order name
+var resolveStrategies = map[string]func(name, key, suffix string) *ChordWithMeta{
+	"exact":      resolveExact,
+	"normalized": resolveNormalized,
+	"alias":      resolveAlias,
+	"fuzzy":      resolveFuzzy,
+}
+
+// resolveOrder is the sequence of strategies resolveChord tries
+var resolveOrder = []string{"exact", "normalized", "alias", "fuzzy"}
+
+// parseResolveOrder parses a comma-separated list of resolution strategies
+func parseResolveOrder(value string) ([]string, error) {
+	var order []string
+	for _, strategy := range strings.Split(value, ",") {
+		strategy = strings.TrimSpace(strategy)
+		if _, ok := resolveStrategies[strategy]; !ok {
+			return nil, fmt.Errorf("unknown resolution strategy %q", strategy)
+		}
+		order = append(order, strategy)
+	}
+	return order, nil
+}
+
+// resolveExact looks up the chord by its exact key and suffix
+func resolveExact(name, key, suffix string) *ChordWithMeta {
+	return chordMap[key+"|"+suffix+"|"+defaultTuning]
+}
+
+// resolveNormalized looks up the chord by its normalized key and suffix
+func resolveNormalized(name, key, suffix string) *ChordWithMeta {
+	return normalizedChord(normalizeKey(key)+"|"+normalizeSuffix(suffix), defaultTuning)
+}
+
+// resolveAlias looks up the chord through the suffix aliases generated by build_db
+func resolveAlias(name, key, suffix string) *ChordWithMeta {
+	return aliasMap[normalizeKey(key)+"|"+suffix+"|"+defaultTuning]
+}
+
+// resolveFuzzy falls back to the best search result for the name
+func resolveFuzzy(name, key, suffix string) *ChordWithMeta {
+	if results := searchByChordNameInMemory(name, ""); len(results) > 0 {
+		return results[0]
+	}
+	return nil
+}
+
+func getChordsByFingering(w http.ResponseWriter, r *http.Request) {
+	// Extract fingering pattern from URL
+	fingering := normalizeFingering(strings.TrimSpace(r.URL.Path[len("/fingers/"):]))
+	if fingering == "" {
+		writeError(w, r, "Fingering pattern required", http.StatusBadRequest)
+		return
+	}
+
+	// An all-muted pattern is meaningless and would prefix-match a huge number of chords
+	if !hasPlayedString(fingering) {
+		writeError(w, r, "Fingering pattern must include at least one played string", http.StatusBadRequest)
+		return
+	}
+
+	// Prepare response
+	w.Header().Set("Content-Type", "application/json")
+
+	// Look up chords by fingering pattern
+	var chords []*ChordWithMeta
+	if exactMatches, ok := fingeringMap[fingering]; ok {
+		// Exact match found
+		chords = exactMatches
+	} else {
+		// Try prefix matches, ordered by frets and then chord type so the response is stable
+		var matchingFrets []string
+		for frets := range fingeringMap {
+			if strings.HasPrefix(frets, fingering) {
+				matchingFrets = append(matchingFrets, frets)
+			}
+		}
+		sort.Strings(matchingFrets)
+
+		// A chord with several matching positions is listed once, at its lowest frets
+		seen := make(map[*ChordWithMeta]bool)
+		for _, frets := range matchingFrets {
+			matchingChords := append([]*ChordWithMeta{}, fingeringMap[frets]...)
+			sort.SliceStable(matchingChords, func(i, j int) bool {
+				return getChordTypePriority(matchingChords[i].Suffix) < getChordTypePriority(matchingChords[j].Suffix)
+			})
+			for _, chord := range matchingChords {
+				if !seen[chord] {
+					seen[chord] = true
+					chords = append(chords, chord)
 				}
 			}
 		}
 	}
 
-	log.Printf("Loaded %d chords into memory", len(chordCache))
-	return nil
+	// Frets only describe the same chord in the same tuning, so keep the chords stored under the requested one
+	tuning := r.URL.Query().Get("tuning")
+	if tuning == "" {
+		tuning = defaultTuning
+	}
+	inTuning := make([]*ChordWithMeta, 0, len(chords))
+	for _, chord := range chords {
+		if chord.Tuning == tuning {
+			inTuning = append(inTuning, chord)
+		}
+	}
+	chords = inTuning
+
+	// Keep only the chords and positions the filters allow
+	filter, err := requestPositionFilter(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if filter != nil {
+		if chords, err = applyPositionFilter(chords, filter); err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Narrow the matches down to the positions played with the requested fingers
+	fingers := r.URL.Query().Get("fingers")
+	var results []json.RawMessage
+	if fingers != "" {
+		var err error
+		if results, err = filterByFingers(chords, fingering, fingers); err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	} else {
+		for _, chord := range chords {
+			results = append(results, json.RawMessage(chord.FullData))
+		}
+	}
+
+	// Only the number of results was asked for, which can be 0
+	if r.URL.Query().Get("count_only") == "true" {
+		writeCount(w, min(len(results), fingersLimit))
+		return
+	}
+
+	if len(results) == 0 && !emptyResultsOK(r) {
+		writeError(w, r, "No chords found with this fingering", http.StatusNotFound)
+		return
+	}
+	if len(results) > fingersLimit {
+		results = results[:fingersLimit]
+	}
+	results = capResults(w, results)
+	if results == nil {
+		results = []json.RawMessage{}
+	}
+
+	w.Header().Add("Vary", "Accept")
+	if wantsNDJSON(r) {
+		writeNDJSON(w, results)
+		return
+	}
+
+	// Return the results as JSON array
+	response, err := json.Marshal(results)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
 }
 
-func getChordByName(w http.ResponseWriter, r *http.Request) {
-	// Extract chord name from URL
-	chordPath := r.URL.Path[len("/chords/"):]
-	if chordPath == "" {
-		http.Error(w, "Chord name required", http.StatusBadRequest)
+// filterByFingers returns the chords that have a position matching both a frets pattern and a fingers pattern,
+// each a prefix of the position's value, with their positions narrowed to the matching ones
+func filterByFingers(chords []*ChordWithMeta, frets, fingers string) ([]json.RawMessage, error) {
+	var results []json.RawMessage
+	for _, chord := range chords {
+		var matched []interface{}
+		for _, posInterface := range chord.Positions {
+			posMap, ok := posInterface.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			posFrets, _ := posMap["frets"].(string)
+			posFingers, _ := posMap["fingers"].(string)
+			if strings.HasPrefix(normalizeFingering(posFrets), frets) && strings.HasPrefix(posFingers, fingers) {
+				matched = append(matched, posInterface)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		data, err := withFields(chord.FullData, map[string]interface{}{"positions": matched})
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, json.RawMessage(data))
+	}
+	return results, nil
+}
+
+// searchChords handles the search endpoint that can search for both chord names and fingerings
+func searchChords(w http.ResponseWriter, r *http.Request) {
+	// Extract search query from URL
+	query := r.URL.Path[len("/search/"):]
+	if query == "" {
+		writeError(w, r, "Search query required", http.StatusBadRequest)
+		return
+	}
+
+	// Prepare response
+	w.Header().Set("Content-Type", "application/json")
+
+	// Determine if the query is likely a fingering pattern or a chord name
+	isFingeringPattern := isLikelyFingeringPattern(query)
+	isChordName := isLikelyChordName(query)
+
+	// An all-muted pattern is meaningless and would prefix-match a huge number of chords
+	if isFingeringPattern && !isChordName && !hasPlayedString(query) {
+		writeError(w, r, "Fingering pattern must include at least one played string", http.StatusBadRequest)
+		return
+	}
+
+	// Order of the results: relevance (default) or positions
+	order := r.URL.Query().Get("sort")
+	if order != "" && order != "relevance" && order != "positions" {
+		writeError(w, r, "Unsupported sort value: "+order, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := requestPositionFilter(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	openStrings, err := requestOpenStrings(r)
+	if err != nil {
+		writeError(w, r, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Results to return
+	var chords []*ChordWithMeta
+
+	// If it's clearly a fingering pattern, search only fingerings
+	if isFingeringPattern && !isChordName {
+		chords = searchByFingeringInMemory(query, order)
+		debugf(r, "search interpretation=fingering query=%q fingering_results=%d", query, len(chords))
+	} else if isChordName && !isFingeringPattern {
+		// If it's clearly a chord name, search only chord names
+		chords = searchByChordNameInMemory(query, order)
+		debugf(r, "search interpretation=name query=%q name_results=%d", query, len(chords))
+	} else {
+		// If it could be either or we're not sure, search both but prioritize simpler chords
+		var nameCount, fingeringCount int
+		chords, nameCount, fingeringCount = searchBothInMemory(query, order)
+		debugf(r, "search interpretation=both query=%q looks_like_fingering=%t looks_like_name=%t name_results=%d fingering_results=%d results=%d",
+			query, isFingeringPattern, isChordName, nameCount, fingeringCount, len(chords))
+	}
+
+	// Keep only the chords and positions the filters allow
+	if filter != nil {
+		if chords, err = applyPositionFilter(chords, filter); err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Keep only the chords that can ring the requested strings open
+	if openStrings != nil {
+		var open []*ChordWithMeta
+		for _, chord := range chords {
+			if hasOpenStrings(chord, openStrings) {
+				open = append(open, chord)
+			}
+		}
+		debugf(r, "search open=%v results=%d open_results=%d", openStrings, len(chords), len(open))
+		chords = open
+	}
+
+	countOnly := r.URL.Query().Get("count_only") == "true"
+	if len(chords) == 0 && !emptyResultsOK(r) && !countOnly {
+		writeError(w, r, "No results found", http.StatusNotFound)
+		return
+	}
+
+	// Searches that weren't truncated return their matches unsorted
+	chords = sortResults(chords, order)
+
+	// Drop voicings repeated across the results if requested
+	if r.URL.Query().Get("dedupe_positions") == "true" {
+		deduped, err := dedupePositions(chords)
+		if err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+		debugf(r, "search dedupe_positions results=%d deduped=%d", len(chords), len(deduped))
+		chords = deduped
+	}
+
+	// Only the number of results was asked for, which can be 0
+	if countOnly {
+		writeCount(w, len(chords))
+		return
+	}
+
+	// Group the results by key if requested
+	switch groupBy := r.URL.Query().Get("group_by"); groupBy {
+	case "":
+	case "key":
+		grouped := make(map[string][]json.RawMessage)
+		for _, chord := range chords {
+			grouped[chord.Key] = append(grouped[chord.Key], json.RawMessage(chord.FullData))
+		}
+
+		response, err := json.Marshal(grouped)
+		if err != nil {
+			writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+			return
+		}
+
+		writeBody(w, string(response))
+		return
+	default:
+		writeError(w, r, "Unsupported group_by value: "+groupBy, http.StatusBadRequest)
+		return
+	}
+
+	// Convert to JSON array
+	results := []json.RawMessage{}
+	for _, chord := range chords {
+		results = append(results, json.RawMessage(chord.FullData))
+	}
+	results = capResults(w, results)
+
+	w.Header().Add("Vary", "Accept")
+	if wantsNDJSON(r) {
+		writeNDJSON(w, results)
+		return
+	}
+
+	// Return the results as JSON array
+	response, err := json.Marshal(results)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
+		return
+	}
+
+	writeBody(w, string(response))
+}
+
+// queryableColumns lists the tables /query can read and their columns
+var queryableColumns = map[string][]string{
+	"chords":        {"id", "key", "suffix", "tuning", "full_data"},
+	"fingerings":    {"id", "chord_id", "frets", "fingers", "barres", "capo"},
+	"chord_aliases": {"id", "chord_id", "alias_key", "alias_suffix", "alias_tuning"},
+}
+
+// maxQueryRows caps the number of rows /query returns
+const maxQueryRows = 1000
+
+// queryTable runs a restricted SELECT described by a JSON request and returns the rows.
+// Table and column names must be whitelisted and filter values are bound as parameters,
+// so the request can't inject SQL or write to the database.
+func queryTable(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		writeError(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var request struct {
+		Table   string                 `json:"table"`
+		Columns []string               `json:"columns"`
+		Where   map[string]interface{} `json:"where"`
+		OrderBy string                 `json:"order_by"`
+		Limit   int                    `json:"limit"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		writeError(w, r, "Invalid request body: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	// Prepare response
-	w.Header().Set("Content-Type", "application/json")
+	allowed, ok := queryableColumns[request.Table]
+	if !ok {
+		writeError(w, r, "Unknown table: "+request.Table, http.StatusBadRequest)
+		return
+	}
+	isColumn := func(name string) bool {
+		for _, column := range allowed {
+			if column == name {
+				return true
+			}
+		}
+		return false
+	}
 
-	// Parse the chord name into key and suffix
-	var key, suffix string
-	for i, c := range chordPath {
-		if !((c >= 'A' && c <= 'G') || c == '#' || c == 'b') {
-			key = chordPath[:i]
-			suffix = chordPath[i:]
-			break
+	columns := request.Columns
+	if len(columns) == 0 {
+		columns = allowed
+	}
+	for _, column := range columns {
+		if !isColumn(column) {
+			writeError(w, r, "Unknown column: "+column, http.StatusBadRequest)
+			return
 		}
 	}
-	if key == "" {
-		key = chordPath
-		suffix = ""
+
+	// Filter columns are sorted so the generated statement is deterministic
+	var filters []string
+	for column := range request.Where {
+		if !isColumn(column) {
+			writeError(w, r, "Unknown column: "+column, http.StatusBadRequest)
+			return
+		}
+		filters = append(filters, column)
 	}
+	sort.Strings(filters)
 
-	// Normalize the key and suffix
-	normalizedKey := normalizeKey(key)
-	normalizedSuffix := normalizeSuffix(suffix)
+	var conditions []string
+	var args []interface{}
+	for _, column := range filters {
+		switch value := request.Where[column].(type) {
+		case string, float64, bool, nil:
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		default:
+			writeError(w, r, "Filter values must be strings, numbers or booleans", http.StatusBadRequest)
+			return
+		}
+	}
 
-	// Try direct lookup in the map
-	mapKey := key + "|" + suffix
-	if chord, ok := chordMap[mapKey]; ok {
-		fmt.Fprint(w, chord.FullData)
+	if request.OrderBy != "" && !isColumn(request.OrderBy) {
+		writeError(w, r, "Unknown column: "+request.OrderBy, http.StatusBadRequest)
 		return
 	}
-
-	// Try normalized lookup
-	normalizedMapKey := normalizedKey + "|" + normalizedSuffix
-	if chords, ok := normalizedMap[normalizedMapKey]; ok && len(chords) > 0 {
-		fmt.Fprint(w, chords[0].FullData)
+	if request.Limit < 0 || request.Limit > maxQueryRows {
+		writeError(w, r, fmt.Sprintf("Limit must be between 1 and %d", maxQueryRows), http.StatusBadRequest)
 		return
 	}
+	if request.Limit == 0 {
+		request.Limit = maxQueryRows
+	}
+
+	query := "SELECT " + strings.Join(columns, ", ") + " FROM " + request.Table
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if request.OrderBy != "" {
+		query += " ORDER BY " + request.OrderBy
+	}
+	query += " LIMIT ?"
+	args = append(args, request.Limit)
 
-	// If not found, try a more flexible search
-	results := searchByChordNameInMemory(chordPath)
-	if len(results) > 0 {
-		fmt.Fprint(w, results[0].FullData)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		writeError(w, r, "Error running query", http.StatusInternalServerError)
 		return
 	}
+	defer rows.Close()
 
-	// If still not found, return 404
-	http.Error(w, "Chord not found", http.StatusNotFound)
-}
+	results := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			writeError(w, r, "Error reading query results", http.StatusInternalServerError)
+			return
+		}
 
-func getChordsByFingering(w http.ResponseWriter, r *http.Request) {
-	// Extract fingering pattern from URL
-	fingering := r.URL.Path[len("/fingers/"):]
-	if fingering == "" {
-		http.Error(w, "Fingering pattern required", http.StatusBadRequest)
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		writeError(w, r, "Error reading query results", http.StatusInternalServerError)
+		return
+	}
+
+	response, err := json.Marshal(results)
+	if err != nil {
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 
-	// Prepare response
 	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, string(response))
+}
 
-	// Look up chords by fingering pattern
-	var chords []*ChordWithMeta
-	if exactMatches, ok := fingeringMap[fingering]; ok {
-		// Exact match found
-		chords = exactMatches
-	} else {
-		// Try prefix matches
-		for frets, matchingChords := range fingeringMap {
-			if strings.HasPrefix(frets, fingering) {
-				chords = append(chords, matchingChords...)
-			}
+// getUnaliasedSuffixes lists the suffixes in the dataset that can't be searched by any alternative name,
+// most common first
+func getUnaliasedSuffixes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Suffixes are aliased if the server or build_db knows another name for them
+	aliased := make(map[string]bool)
+	for _, suffix := range suffixAliasMap {
+		aliased[suffix] = true
+	}
+	for _, suffix := range numberSuffixMap {
+		aliased[suffix] = true
+	}
+	for _, chord := range aliasMap {
+		aliased[chord.Suffix] = true
+	}
+
+	counts := make(map[string]int)
+	for _, chord := range chordCache {
+		if !aliased[chord.Suffix] {
+			counts[chord.Suffix]++
 		}
 	}
 
-	if len(chords) == 0 {
-		http.Error(w, "No chords found with this fingering", http.StatusNotFound)
-		return
+	type suffixCount struct {
+		Suffix string `json:"suffix"`
+		Count  int    `json:"count"`
 	}
 
-	// Convert to JSON array
-	var results []json.RawMessage
-	for _, chord := range chords {
-		results = append(results, json.RawMessage(chord.FullData))
+	results := []suffixCount{}
+	for suffix, count := range counts {
+		results = append(results, suffixCount{Suffix: suffix, Count: count})
 	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Count != results[j].Count {
+			return results[i].Count > results[j].Count
+		}
+		return results[i].Suffix < results[j].Suffix
+	})
 
-	// Return the results as JSON array
 	response, err := json.Marshal(results)
 	if err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 
 	fmt.Fprint(w, string(response))
 }
 
-// searchChords handles the search endpoint that can search for both chord names and fingerings
-func searchChords(w http.ResponseWriter, r *http.Request) {
-	// Extract search query from URL
-	query := r.URL.Path[len("/search/"):]
-	if query == "" {
-		http.Error(w, "Search query required", http.StatusBadRequest)
-		return
+// coverageSuffixes are the suffixes the coverage report expects every root to have
+var coverageSuffixes = []string{"major", "minor", "7", "maj7", "m7", "dim", "dim7", "aug", "sus2", "sus4", "6", "m6", "9", "add9", "m7b5"}
+
+// splitList splits a comma-separated list, dropping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
 	}
+	return items
+}
 
-	// Prepare response
-	w.Header().Set("Content-Type", "application/json")
+// coverageReport records which of the expected chords the dataset contains
+type coverageReport struct {
+	Tuning   string   `json:"tuning"`
+	Roots    []string `json:"roots"`
+	Suffixes []string `json:"suffixes"`
+	Present  int      `json:"present"`
+	Total    int      `json:"total"`
+	Matrix   [][]bool `json:"matrix"`  // One row per root, one column per suffix
+	Missing  []string `json:"missing"` // Names of the absent chords, e.g. "G# dim7"
+}
 
-	// Determine if the query is likely a fingering pattern or a chord name
-	isFingeringPattern := isLikelyFingeringPattern(query)
-	isChordName := isLikelyChordName(query)
+// getCoverage reports, for every root and expected suffix, whether the dataset contains the chord.
+// Suffixes default to coverageSuffixes and can be given with ?suffixes=, and the report is JSON or a
+// printable grid with ?format=grid.
+func getCoverage(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
 
-	// Results to return
-	var chords []*ChordWithMeta
+	format := query.Get("format")
+	if format != "" && format != "json" && format != "grid" {
+		writeError(w, r, "Invalid format: "+format, http.StatusBadRequest)
+		return
+	}
 
-	// If it's clearly a fingering pattern, search only fingerings
-	if isFingeringPattern && !isChordName {
-		chords = searchByFingeringInMemory(query)
-	} else if isChordName && !isFingeringPattern {
-		// If it's clearly a chord name, search only chord names
-		chords = searchByChordNameInMemory(query)
-	} else {
-		// If it could be either or we're not sure, search both but prioritize simpler chords
-		chords = searchBothInMemory(query)
+	suffixes := coverageSuffixes
+	if value := query.Get("suffixes"); value != "" {
+		suffixes = splitList(value)
 	}
 
-	if len(chords) == 0 {
-		http.Error(w, "No results found", http.StatusNotFound)
-		return
+	tuning := query.Get("tuning")
+	if tuning == "" {
+		tuning = defaultTuning
 	}
 
-	// Convert to JSON array
-	var results []json.RawMessage
-	for _, chord := range chords {
-		results = append(results, json.RawMessage(chord.FullData))
+	// Chords are matched after normalization, so Ab covers G# and min covers minor
+	available := make(map[string]bool)
+	for _, chord := range chordCache {
+		if chord.Tuning == tuning {
+			available[chord.NormalizedKey+"|"+chord.NormalizedSuffix] = true
+		}
 	}
 
-	// Return the results as JSON array
-	response, err := json.Marshal(results)
+	report := coverageReport{
+		Tuning:   tuning,
+		Roots:    noteNames,
+		Suffixes: suffixes,
+		Total:    len(noteNames) * len(suffixes),
+		Matrix:   [][]bool{},
+		Missing:  []string{},
+	}
+	for _, root := range noteNames {
+		row := make([]bool, len(suffixes))
+		for i, suffix := range suffixes {
+			row[i] = available[root+"|"+normalizeSuffix(suffix)]
+			if row[i] {
+				report.Present++
+			} else {
+				report.Missing = append(report.Missing, root+" "+suffix)
+			}
+		}
+		report.Matrix = append(report.Matrix, row)
+	}
+
+	if format == "grid" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		var grid strings.Builder
+		tw := tabwriter.NewWriter(&grid, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(tw, "\t%s\n", strings.Join(suffixes, "\t"))
+		for i, root := range noteNames {
+			cells := make([]string, len(suffixes))
+			for j, present := range report.Matrix[i] {
+				cells[j] = "."
+				if present {
+					cells[j] = "x"
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", root, strings.Join(cells, "\t"))
+		}
+		tw.Flush()
+		fmt.Fprintf(&grid, "%d of %d present\n", report.Present, report.Total)
+		writeBody(w, grid.String())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	response, err := json.Marshal(report)
 	if err != nil {
-		http.Error(w, "Error encoding response", http.StatusInternalServerError)
+		writeError(w, r, "Error encoding response", http.StatusInternalServerError)
 		return
 	}
 
-	fmt.Fprint(w, string(response))
+	writeBody(w, string(response))
 }
 
 // isLikelyFingeringPattern determines if a query is likely a fingering pattern
@@ -360,14 +5206,25 @@ func isLikelyFingeringPattern(query string) bool {
 	// - digits (0-9) for frets 0-9
 	// - lowercase letters (a-z) for frets 10 and above (a=10, b=11, etc.)
 	// - 'x' or 'X' for muted strings
+	// - dashes between the strings of a delimited pattern
 	for _, c := range query {
-		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || c == 'x' || c == 'X') {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || c == 'x' || c == 'X' || c == '-') {
 			return false
 		}
 	}
 	return true
 }
 
+// hasPlayedString reports whether a fingering pattern has at least one string that isn't muted
+func hasPlayedString(pattern string) bool {
+	for _, c := range pattern {
+		if c != 'x' && c != 'X' && c != '-' {
+			return true
+		}
+	}
+	return false
+}
+
 // isLikelyChordName determines if a query is likely a chord name
 func isLikelyChordName(query string) bool {
 	// Chord names typically start with a letter A-G, possibly followed by # or b
@@ -385,12 +5242,18 @@ func isLikelyChordName(query string) bool {
 }
 
 // searchByFingeringInMemory searches for chords by fingering pattern using in-memory data
-func searchByFingeringInMemory(query string) []*ChordWithMeta {
+func searchByFingeringInMemory(query, order string) []*ChordWithMeta {
 	var results []*ChordWithMeta
+	query = normalizeFingering(query)
+
+	// Don't match anything for empty or all-muted patterns
+	if !hasPlayedString(query) {
+		return nil
+	}
 
 	// First try exact matches
 	if chords, ok := fingeringMap[query]; ok {
-		return chords
+		return limitSearchResults(chords)
 	}
 
 	// Then try prefix matches
@@ -399,13 +5262,36 @@ func searchByFingeringInMemory(query string) []*ChordWithMeta {
 			results = append(results, chords...)
 		}
 	}
+	results = sortResults(results, order)
+
+	return limitSearchResults(results)
+}
 
-	// limit results to 5
-	if len(results) > 5 {
-		results = results[:5]
+// normalizeFingering returns the canonical form of a fingering pattern, so the same pattern always finds the
+// same chords. The mute character may be written 'x' or 'X', and a delimited pattern such as "x-3-2-0-1-0"
+// is packed to "x32010" when every fret fits in one character. A trailing dash is dropped, so "x-3-" is a prefix.
+func normalizeFingering(pattern string) string {
+	pattern = strings.ReplaceAll(pattern, "X", "x")
+	if !strings.Contains(pattern, fretSeparator) {
+		return pattern
 	}
 
-	return results
+	pattern = strings.TrimSuffix(pattern, fretSeparator)
+	parts := strings.Split(pattern, fretSeparator)
+	packed := make([]byte, 0, len(parts))
+	for _, part := range parts {
+		if part == "x" {
+			packed = append(packed, 'x')
+			continue
+		}
+		fret, err := strconv.Atoi(part)
+		c, ok := fretChar(fret)
+		if err != nil || !ok {
+			return pattern
+		}
+		packed = append(packed, c)
+	}
+	return string(packed)
 }
 
 // searchByChordName searches for chords by name
@@ -572,7 +5458,7 @@ func searchByChordName(query string) ([]json.RawMessage, error) {
 	// Special case for Bb which might be capitalized differently
 	if strings.ToUpper(key) == "BB" {
 		alternateKeys = []string{"BB", "A#"}
-		fmt.Printf("DEBUG: Special case for Bb, alternateKeys = %v\n", alternateKeys)
+		slog.Debug("Special case for Bb", "alternate_keys", alternateKeys)
 	}
 
 	// Handle special enharmonic equivalents
@@ -739,7 +5625,7 @@ func searchByChordName(query string) ([]json.RawMessage, error) {
 // searchBoth searches for both chord names and fingerings, prioritizing simpler chords
 func searchBoth(query string) ([]json.RawMessage, error) {
 	// Use the in-memory implementation
-	chords := searchBothInMemory(query)
+	chords, _, _ := searchBothInMemory(query, "")
 
 	// Convert to JSON array
 	var results []json.RawMessage
@@ -751,7 +5637,9 @@ func searchBoth(query string) ([]json.RawMessage, error) {
 }
 
 // searchByChordNameInMemory searches for chords by name using in-memory data
-func searchByChordNameInMemory(query string) []*ChordWithMeta {
+func searchByChordNameInMemory(query, order string) []*ChordWithMeta {
+	query = flattenParentheses(query)
+
 	// Special case for Bb/A# chords
 	if strings.ToUpper(query) == "BB" || strings.HasPrefix(strings.ToUpper(query), "BB") {
 		// Look for A# chords
@@ -779,7 +5667,7 @@ func searchByChordNameInMemory(query string) []*ChordWithMeta {
 
 				// Return A# major as the first result, followed by other A# chords
 				results = append([]*ChordWithMeta{aSharpMajor}, otherASharp...)
-				return results
+				return limitSearchResults(results)
 			}
 		}
 
@@ -793,7 +5681,7 @@ func searchByChordNameInMemory(query string) []*ChordWithMeta {
 		if len(results) > 0 {
 			// Sort by common chord types
 			sortByChordType(results)
-			return results
+			return limitSearchResults(results)
 		}
 	}
 
@@ -821,7 +5709,7 @@ func searchByChordNameInMemory(query string) []*ChordWithMeta {
 			// Return A minor as the first result, followed by other A minor-like chords
 			results := []*ChordWithMeta{aMinor}
 			results = append(results, otherAm...)
-			return results
+			return limitSearchResults(results)
 		}
 	}
 
@@ -849,7 +5737,7 @@ func searchByChordNameInMemory(query string) []*ChordWithMeta {
 			// Return C# major as the first result, followed by other C# chords
 			results := []*ChordWithMeta{cSharpMajor}
 			results = append(results, otherCSharp...)
-			return results
+			return limitSearchResults(results)
 		}
 	}
 
@@ -874,7 +5762,7 @@ func searchByChordNameInMemory(query string) []*ChordWithMeta {
 	// Try exact match first
 	normalizedMapKey := normalizedKey + "|" + normalizedSuffix
 	if chords, ok := normalizedMap[normalizedMapKey]; ok && len(chords) > 0 {
-		return chords
+		return limitSearchResults(chords)
 	}
 
 	// If no exact match, try partial matches
@@ -892,13 +5780,363 @@ func searchByChordNameInMemory(query string) []*ChordWithMeta {
 
 	// Sort results by chord type priority
 	sortByChordType(results)
+	results = sortResults(results, order)
+
+	return limitSearchResults(results)
+}
 
-	// limit results to 5
-	if len(results) > 5 {
-		results = results[:5]
+// sortResults returns search results in the requested order. The default order is kept unless order is
+// "positions", which puts the chords with the most positions first, then common chord types.
+func sortResults(chords []*ChordWithMeta, order string) []*ChordWithMeta {
+	if order != "positions" {
+		return chords
 	}
 
-	return results
+	// Sort a copy, since results may share a slice with the lookup maps
+	sorted := append([]*ChordWithMeta{}, chords...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if a, b := len(sorted[i].Positions), len(sorted[j].Positions); a != b {
+			return a > b
+		}
+		return getChordTypePriority(sorted[i].Suffix) < getChordTypePriority(sorted[j].Suffix)
+	})
+	return sorted
+}
+
+// positionFingerprint identifies a voicing by its frets, fingers and base fret
+func positionFingerprint(position interface{}) (string, bool) {
+	posMap, ok := position.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	frets, _ := posMap["frets"].(string)
+	fingers, _ := posMap["fingers"].(string)
+	return fmt.Sprintf("%s|%s|%v", normalizeFingering(frets), fingers, posMap["baseFret"]), true
+}
+
+// dedupePositions drops positions that repeat a voicing from an earlier chord in the results, keeping
+// the first occurrence. Chords left without positions are dropped.
+func dedupePositions(chords []*ChordWithMeta) ([]*ChordWithMeta, error) {
+	seen := make(map[string]bool)
+	var deduped []*ChordWithMeta
+	for _, chord := range chords {
+		var kept []int
+		for i, position := range chord.Positions {
+			fingerprint, ok := positionFingerprint(position)
+			if ok && seen[fingerprint] {
+				continue
+			}
+			seen[fingerprint] = true
+			kept = append(kept, i)
+		}
+		if len(kept) == 0 {
+			continue
+		}
+
+		chord, err := withPositions(chord, kept)
+		if err != nil {
+			return nil, err
+		}
+		deduped = append(deduped, chord)
+	}
+	return deduped, nil
+}
+
+// requestPreferredFrets parses a request's ?prefer= voicing, written "frets:" followed by a fret pattern such as
+// "frets:x32010", into normalized frets. It returns "" if unset.
+func requestPreferredFrets(r *http.Request) (string, error) {
+	value := r.URL.Query().Get("prefer")
+	if value == "" {
+		return "", nil
+	}
+
+	frets, ok := strings.CutPrefix(value, "frets:")
+	if !ok || frets == "" {
+		return "", fmt.Errorf("invalid preference: %s (expected frets:<pattern>)", value)
+	}
+	return normalizeFingering(frets), nil
+}
+
+// preferPositions moves the positions with the preferred frets to the front of a chord's positions, keeping the
+// order of the rest. The chord is returned unchanged if none of its positions match.
+func preferPositions(chord *ChordWithMeta, frets string) (*ChordWithMeta, error) {
+	var preferred, rest []int
+	for i, position := range chord.Positions {
+		posMap, _ := position.(map[string]interface{})
+		if positionFrets, _ := posMap["frets"].(string); normalizeFingering(positionFrets) == frets {
+			preferred = append(preferred, i)
+		} else {
+			rest = append(rest, i)
+		}
+	}
+	return withPositions(chord, append(preferred, rest...))
+}
+
+// withPositions returns the chord with only the positions at the given indices, in that order.
+// The chord is copied if its positions change, since the original is shared with the lookup maps.
+func withPositions(chord *ChordWithMeta, indices []int) (*ChordWithMeta, error) {
+	unchanged := len(indices) == len(chord.Positions)
+	for i, index := range indices {
+		unchanged = unchanged && i == index
+	}
+	if unchanged {
+		return chord, nil
+	}
+
+	positions := make([]interface{}, len(indices))
+	var barres [][]int
+	if chord.Barres != nil {
+		barres = make([][]int, len(indices))
+	}
+	for i, index := range indices {
+		positions[i] = chord.Positions[index]
+		if barres != nil {
+			barres[i] = chord.Barres[index]
+		}
+	}
+
+	data, err := withFields(chord.FullData, map[string]interface{}{"positions": positions})
+	if err != nil {
+		return nil, err
+	}
+	copied := *chord
+	copied.Positions = positions
+	copied.Barres = barres
+	copied.FullData = data
+	return &copied, nil
+}
+
+// positionFilter narrows and orders the positions of returned chords
+type positionFilter struct {
+	MaxSpan        int  // Most frets a position may span, or 0 for no limit
+	MaxFingers     int  // Most strings a position may fret, or 0 for no limit
+	PreferOpen     bool // Positions with more open strings first
+	PreferLowFret  bool // Positions closer to the nut first
+	CommonSuffixes bool // Only chords with a common suffix (see getChordTypePriority)
+	NoCapo         bool // Only positions played without a capo
+	MinFret        int  // Lowest fret a position may fret, or 0 for no limit
+	MaxFret        int  // Highest fret a position may fret, or 0 for no limit
+}
+
+// modePresets are the filters applied by ?mode=
+var modePresets = map[string]positionFilter{
+	"beginner": {MaxSpan: 4, MaxFingers: 4, PreferOpen: true, PreferLowFret: true, CommonSuffixes: true},
+}
+
+// requestPositionFilter returns the filter for the request's mode and voicing parameters, or nil if none were requested
+func requestPositionFilter(r *http.Request) (*positionFilter, error) {
+	query := r.URL.Query()
+
+	var filter positionFilter
+	if mode := query.Get("mode"); mode != "" {
+		preset, ok := modePresets[mode]
+		if !ok {
+			return nil, fmt.Errorf("unsupported mode: %s", mode)
+		}
+		filter = preset
+	}
+	filter.NoCapo = query.Get("no_capo") == "true"
+
+	for _, bound := range []struct {
+		name  string
+		value *int
+	}{{"minfret", &filter.MinFret}, {"maxfret", &filter.MaxFret}} {
+		if value := query.Get(bound.name); value != "" {
+			fret, err := strconv.Atoi(value)
+			if err != nil || fret < 1 {
+				return nil, fmt.Errorf("invalid %s: %s", bound.name, value)
+			}
+			*bound.value = fret
+		}
+	}
+	if filter.MinFret > 0 && filter.MaxFret > 0 && filter.MinFret > filter.MaxFret {
+		return nil, fmt.Errorf("minfret %d is above maxfret %d", filter.MinFret, filter.MaxFret)
+	}
+
+	if filter == (positionFilter{}) {
+		return nil, nil
+	}
+	return &filter, nil
+}
+
+// positionShape summarizes how a position is played
+type positionShape struct {
+	open    int  // Open strings
+	fretted int  // Fretted strings
+	lowest  int  // Lowest fretted fret, or 0 if none
+	highest int  // Highest fretted fret, or 0 if none
+	span    int  // Frets between the lowest and highest fretted frets, inclusive
+	capo    bool // Played with a capo
+	barre   bool // Frets strings with a barre
+}
+
+// shapeOf returns the shape of a position, or false if it has no frets
+func shapeOf(position interface{}) (positionShape, bool) {
+	posMap, ok := position.(map[string]interface{})
+	if !ok {
+		return positionShape{}, false
+	}
+	frets, _ := posMap["frets"].(string)
+	if frets == "" {
+		return positionShape{}, false
+	}
+
+	// Any capo value other than empty or "0" means the position needs a capo
+	capo, _ := posMap["capo"].(string)
+	barres, _ := posMap["barres"].(string)
+	shape := positionShape{capo: capo != "" && capo != "0", barre: strings.Trim(barres, ", ") != ""}
+	for _, fret := range parseFrets(frets) {
+		switch {
+		case fret < 0:
+		case fret == 0:
+			shape.open++
+		default:
+			shape.fretted++
+			if shape.lowest == 0 || fret < shape.lowest {
+				shape.lowest = fret
+			}
+			if fret > shape.highest {
+				shape.highest = fret
+			}
+		}
+	}
+	if shape.fretted > 0 {
+		shape.span = shape.highest - shape.lowest + 1
+	}
+	return shape, true
+}
+
+// positionFlags are the playability flags of a position returned by ?include_flags=true
+type positionFlags struct {
+	IsBarre      bool `json:"isBarre"`      // Frets strings with a barre
+	IsOpen       bool `json:"isOpen"`       // Lets at least one string ring open
+	IsMovable    bool `json:"isMovable"`    // Frets every played string, so the shape can slide along the neck
+	RequiresCapo bool `json:"requiresCapo"` // Played with a capo
+}
+
+// flags returns the playability flags of a shape
+func (shape positionShape) flags() positionFlags {
+	return positionFlags{
+		IsBarre:      shape.barre,
+		IsOpen:       shape.open > 0,
+		IsMovable:    shape.open == 0 && shape.fretted > 0,
+		RequiresCapo: shape.capo,
+	}
+}
+
+// analyzePosition returns the playability flags of a position, or false if it has no frets
+func analyzePosition(position interface{}) (positionFlags, bool) {
+	shape, ok := shapeOf(position)
+	if !ok {
+		return positionFlags{}, false
+	}
+	return shape.flags(), true
+}
+
+// requestOpenStrings parses a request's ?open= list of strings that must ring open, numbered from the
+// highest string (1) down as guitarists do, e.g. "1,2" for the two highest strings. It returns nil if unset.
+func requestOpenStrings(r *http.Request) ([]int, error) {
+	value := r.URL.Query().Get("open")
+	if value == "" {
+		return nil, nil
+	}
+
+	var openStrings []int
+	for _, part := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid open string: %s", part)
+		}
+		openStrings = append(openStrings, n)
+	}
+	return openStrings, nil
+}
+
+// hasOpenStrings reports whether a chord has a position where every given string is played open.
+// Strings are numbered from the highest down, so string 1 is the last fret in the frets string.
+func hasOpenStrings(chord *ChordWithMeta, openStrings []int) bool {
+	for _, position := range chord.Positions {
+		posMap, ok := position.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		frets, _ := posMap["frets"].(string)
+		parsed := parseFrets(frets)
+
+		open := true
+		for _, n := range openStrings {
+			if n > len(parsed) || parsed[len(parsed)-n] != 0 {
+				open = false
+				break
+			}
+		}
+		if open {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPositionFilter drops the chords and positions the filter excludes and orders the remaining positions.
+// Chords left without positions are dropped.
+func applyPositionFilter(chords []*ChordWithMeta, filter *positionFilter) ([]*ChordWithMeta, error) {
+	var filtered []*ChordWithMeta
+	for _, chord := range chords {
+		if filter.CommonSuffixes && getChordTypePriority(chord.Suffix) == 100 {
+			continue
+		}
+
+		var kept []int
+		shapes := make(map[int]positionShape)
+		for i, position := range chord.Positions {
+			shape, ok := shapeOf(position)
+			if !ok {
+				continue
+			}
+			if filter.MaxSpan > 0 && shape.span > filter.MaxSpan {
+				continue
+			}
+			if filter.MaxFingers > 0 && shape.fretted > filter.MaxFingers {
+				continue
+			}
+			if filter.NoCapo && shape.flags().RequiresCapo {
+				continue
+			}
+			// Every fretted note must be in the fret range, and open-string-only positions are in no range
+			if (filter.MinFret > 0 || filter.MaxFret > 0) && shape.fretted == 0 {
+				continue
+			}
+			if filter.MinFret > 0 && shape.lowest < filter.MinFret {
+				continue
+			}
+			if filter.MaxFret > 0 && shape.highest > filter.MaxFret {
+				continue
+			}
+			kept = append(kept, i)
+			shapes[i] = shape
+		}
+		if len(kept) == 0 {
+			continue
+		}
+
+		sort.SliceStable(kept, func(i, j int) bool {
+			a, b := shapes[kept[i]], shapes[kept[j]]
+			if filter.PreferOpen && a.open != b.open {
+				return a.open > b.open
+			}
+			if filter.PreferLowFret && a.lowest != b.lowest {
+				return a.lowest < b.lowest
+			}
+			return false
+		})
+
+		chord, err := withPositions(chord, kept)
+		if err != nil {
+			return nil, err
+		}
+		filtered = append(filtered, chord)
+	}
+	return filtered, nil
 }
 
 // sortByChordType sorts chords by common chord types (major, minor, 7, etc.)
@@ -941,18 +6179,20 @@ func getChordTypePriority(suffix string) int {
 	}
 }
 
-// searchBothInMemory searches for chords by both name and fingering pattern
-func searchBothInMemory(query string) []*ChordWithMeta {
+// searchBothInMemory searches for chords by both name and fingering pattern.
+// It also returns how many chords each search found; the fingering search is skipped when names alone fill the results.
+func searchBothInMemory(query, order string) ([]*ChordWithMeta, int, int) {
 	// First try chord name search
-	chordResults := searchByChordNameInMemory(query)
+	chordResults := searchByChordNameInMemory(query, order)
 
 	// If we have enough chord results, return them
-	if len(chordResults) >= 5 {
-		return chordResults[:5]
+	if len(chordResults) >= searchLimit {
+		return chordResults, len(chordResults), 0
 	}
 
 	// Otherwise, try fingering search as well
-	fingeringResults := searchByFingeringInMemory(query)
+	fingeringResults := searchByFingeringInMemory(query, order)
+	nameCount, fingeringCount := len(chordResults), len(fingeringResults)
 
 	// Combine results, prioritizing chord results
 	results := append(chordResults, fingeringResults...)
@@ -962,17 +6202,23 @@ func searchBothInMemory(query string) []*ChordWithMeta {
 	var uniqueResults []*ChordWithMeta
 
 	for _, chord := range results {
-		key := chord.Key + "|" + chord.Suffix
+		key := chord.Key + "|" + chord.Suffix + "|" + chord.Tuning
 		if !seen[key] {
 			seen[key] = true
 			uniqueResults = append(uniqueResults, chord)
 		}
 	}
 
-	// Limit to 10 results
-	if len(uniqueResults) > 5 {
-		uniqueResults = uniqueResults[:5]
-	}
+	uniqueResults = sortResults(uniqueResults, order)
+
+	return limitSearchResults(uniqueResults), nameCount, fingeringCount
+}
 
-	return uniqueResults
+// limitSearchResults caps search results at -search-limit. The slice is clipped so appending to it
+// never writes into the backing array of an index entry it was taken from.
+func limitSearchResults(results []*ChordWithMeta) []*ChordWithMeta {
+	if len(results) > searchLimit {
+		results = results[:searchLimit]
+	}
+	return slices.Clip(results)
 }
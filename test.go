@@ -1,20 +1,31 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
 	"time"
+
+	_ "github.com/mattn/go-sqlite3"
 )
 
 // TestChordResponse represents the expected structure of a chord response
 type TestChordResponse struct {
 	Key       string              `json:"key"`
 	Suffix    string              `json:"suffix"`
+	Tuning    string              `json:"tuning,omitempty"`
 	Positions []TestChordPosition `json:"positions"`
 }
 
@@ -26,341 +37,4255 @@ type TestChordPosition struct {
 	Capo    string `json:"capo,omitempty"`
 }
 
-func main() {
-	// Define test port (different from default 8080)
-	testPort := 8079
+// fixtureChords are chords with data shapes the real dataset doesn't contain.
+// They are loaded into a scratch database served by a separate server instance.
+var fixtureChords = []string{
+	`{"key":"C","suffix":"major","positions":[{"frets":"x32010","fingers":"032010","capo":"0"}]}`,
+	`{"key":"E","suffix":"7","positions":[]}`,
+	`{"key":"G","suffix":"/B","positions":[{"frets":"x20003","fingers":"010003"}]}`,
+	`{"key":"D","suffix":"/F#","positions":[{"frets":"200232","fingers":"100243"}]}`,
+	`{"key":"D","suffix":"major","positions":[{"frets":"xx0232","fingers":"000132"}]}`,
+	`{"key":"D","suffix":"major","tuning":"drop-d","positions":[{"frets":"xx0232","fingers":"000132"},{"frets":"000232","fingers":"000132"}]}`,
+	`{"key":"C#","suffix":"major","positions":[{"frets":"x46664","fingers":"013331","barres":"4"}]}`,
+	`{"key":"A#","suffix":"major","positions":[{"frets":"x13331","fingers":"012341","barres":"1"}]}`,
+	`{"key":"C","suffix":"7b9","positions":[{"frets":"x32320","fingers":"032410"}]}`,
+	`{"key":"G","suffix":"mmaj7","positions":[{"frets":"354333","fingers":"132111","barres":"3"},{"frets":"3x433x","fingers":"2x341x"}]}`,
+	`{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"002310"}]}`,
+	`{"key":"A","suffix":"7","positions":[{"frets":"x02020","fingers":"002030"},{"frets":"575685","fingers":"131241","barres":"5"},{"frets":"x0b9a9","fingers":"003142"}]}`,
+	`{"key":"A","suffix":"m7b5","positions":[{"frets":"x0101x","fingers":"001020","barres":"1,"}]}`,
+	`{"key":"E","suffix":"major","positions":[{"frets":"022100","fingers":"023100"},{"frets":"022100","fingers":"034200"}]}`,
+	`{"key":"F","suffix":"add9","positions":[{"frets":"xx3213","fingers":"003214"}]}`,
+	`{"key":"F","suffix":"sus4","positions":[{"frets":"113311","fingers":"113411","barres":"1"},{"frets":"xx3311","fingers":"003411","capo":"1"}]}`,
+	`{"key":"F","suffix":"6","positions":[{"frets":"xx3231","fingers":"003241"}]}`,
+	`{"key":"F","suffix":"69","positions":[{"frets":"xx3233","fingers":"002134"},{"frets":"xx3233","fingers":"002134"}]}`,
+	`{"key":"F","suffix":"m6","positions":[{"frets":"xx0111","fingers":"000111","capo":"1"}]}`,
+	`{"key":"F#","suffix":"major","tuning":"standard","positions":[{"frets":"244322","fingers":"134211","barres":"2"}]}`,
+	`{"key":"F#","suffix":"major","tuning":"drop-d","positions":[{"frets":"444322","fingers":"344211","barres":"2"}]}`,
+	`{"key":"B","suffix":"7","positions":[{"frets":"x21202","fingers":"021304"}]}`,
+	`{"key":"G","suffix":"7","positions":[{"frets":"3-2-0-0-0-1","fingers":"320001"},{"frets":"x-10-12-10-12-10","fingers":"013141","barres":"10"}]}`,
+}
 
-	// Test chords that were previously not found
-	testChords := []string{
-		"Ab", "Abmin", "B#", "B%23",
-		// Add more test cases as needed
+// fixtureAliases are chord_aliases rows for the fixture chords: key, suffix, alias key, alias suffix
+var fixtureAliases = [][4]string{
+	{"C", "major", "C", "maj"},
+	// build_db -german-aliases
+	{"B", "7", "H", "7"},
+	{"B", "7", "H", "dom7"},
+}
+
+// fixtureTest describes a single request against the fixture server
+type fixtureTest struct {
+	name       string
+	flags      []string // extra server flags
+	method     string   // defaults to GET
+	path       string
+	body       string            // optional request body
+	headers    map[string]string // optional request headers
+	wantStatus int
+	wantHeader map[string]string       // optional expected response headers
+	wantLength bool                    // expect an explicit Content-Length matching the body
+	check      func(body []byte) error // optional
+}
+
+// Fixture tests
+var fixtureTests = []fixtureTest{
+	{
+		name:       "Empty positions - skipped by default",
+		path:       "/chords/E7",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Empty positions - served with warning when allowed",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E7",
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var chord map[string]interface{}
+			if err := json.Unmarshal(body, &chord); err != nil {
+				return err
+			}
+			if chord["warning"] != "no positions" {
+				return fmt.Errorf("expected warning \"no positions\", got %v", chord["warning"])
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Chord list - misses returned as null",
+		path:       "/chords/C,E7",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("C", ""),
+	},
+	{
+		name:       "Chord list - skip_missing omits misses",
+		path:       "/chords/C,E7?skip_missing=true",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("C"),
+	},
+	{
+		name:       "Slash chord - G/B",
+		path:       "/chords/G/B",
+		wantStatus: http.StatusOK,
+		check:      expectChord("G", "/B"),
+	},
+	{
+		name:       "Slash chord - G/B encoded",
+		path:       "/chords/G%2FB",
+		wantStatus: http.StatusOK,
+		check:      expectChord("G", "/B"),
+	},
+	{
+		name:       "Slash chord - D/F#",
+		path:       "/chords/D/F%23",
+		wantStatus: http.StatusOK,
+		check:      expectChord("D", "/F#"),
+	},
+	{
+		name:       "Slash chord - D/F# encoded",
+		path:       "/chords/D%2FF%23",
+		wantStatus: http.StatusOK,
+		check:      expectChord("D", "/F#"),
+	},
+	{
+		name:       "Slash chord - D/Gb (enharmonic bass)",
+		path:       "/chords/D/Gb",
+		wantStatus: http.StatusOK,
+		check:      expectChord("D", "/F#"),
+	},
+	{
+		name:       "Neighbors - only existing chords with offsets",
+		path:       "/chords/C/neighbors",
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var neighbors []struct {
+				Offset int               `json:"offset"`
+				Chord  TestChordResponse `json:"chord"`
+			}
+			if err := json.Unmarshal(body, &neighbors); err != nil {
+				return err
+			}
+			if len(neighbors) != 3 || neighbors[0].Offset != -2 || neighbors[0].Chord.Key != "A#" ||
+				neighbors[1].Offset != 1 || neighbors[1].Chord.Key != "C#" ||
+				neighbors[2].Offset != 2 || neighbors[2].Chord.Key != "D" {
+				return fmt.Errorf("expected A# at -2, C# at +1 and D at +2")
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Tunings - standard tuning of a chord",
+		path:       "/fingers/244322",
+		wantStatus: http.StatusOK,
+		check:      expectTunings("standard"),
+	},
+	{
+		name:       "Tunings - drop-d tuning of the same chord",
+		path:       "/fingers/444322?tuning=drop-d",
+		wantStatus: http.StatusOK,
+		check:      expectTunings("drop-d"),
+	},
+	{
+		name:       "Tunings - fingering matches standard tuning by default",
+		path:       "/fingers/444322",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Tunings - shape stored under both tunings, standard",
+		path:       "/fingers/xx0232",
+		wantStatus: http.StatusOK,
+		check:      expectTunings("standard"),
+	},
+	{
+		name:       "Tunings - shape stored under both tunings, drop-d",
+		path:       "/fingers/xx0232?tuning=drop-d",
+		wantStatus: http.StatusOK,
+		check:      expectTunings("drop-d"),
+	},
+	{
+		name:       "Tunings - fingering in an unknown tuning",
+		path:       "/fingers/xx0232?tuning=open-g",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Resolve order - exact only misses an alias",
+		flags:      []string{"-resolve-order", "exact"},
+		path:       "/chords/Cmaj",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Resolve order - alias strategy finds an alias",
+		flags:      []string{"-resolve-order", "exact,alias"},
+		path:       "/chords/Cmaj",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "major"),
+	},
+	{
+		name:       "Resolved via - exact",
+		path:       "/chords/Am",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "exact"},
+	},
+	{
+		name:       "Resolved via - enharmonic key",
+		path:       "/chords/Db",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "enharmonic:Db→C#"},
+	},
+	{
+		name:       "Resolved via - suffix alias",
+		path:       "/chords/Amin",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "suffix-alias:min→minor"},
+	},
+	{
+		name:       "Resolved via - enharmonic bass",
+		path:       "/chords/D/Gb",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "enharmonic:Gb→F#"},
+	},
+	{
+		name:       "Resolved via - chord_aliases table",
+		flags:      []string{"-resolve-order", "exact,alias"},
+		path:       "/chords/Cmaj",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "alias:maj→major"},
+	},
+	{
+		name:       "Resolved via - German key",
+		path:       "/chords/H7",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "german:H→B"},
+	},
+	{
+		name:       "Resolved via - fuzzy",
+		flags:      []string{"-resolve-order", "fuzzy"},
+		path:       "/chords/C",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "fuzzy"},
+	},
+	{
+		name:       "Resolved via - warmed lookup",
+		flags:      []string{"-warm"},
+		path:       "/chords/Am",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "exact"},
+	},
+	{
+		name:       "Resolved via - derived",
+		path:       "/chords/D%23?derive=true",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Resolved-Via": "derived"},
+	},
+	{
+		name:       "Resolve order - fuzzy only still resolves a bare key",
+		flags:      []string{"-resolve-order", "fuzzy"},
+		path:       "/chords/C",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "major"),
+	},
+	{
+		name:       "Intervals - included on request",
+		path:       "/chords/C?include_intervals=true",
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var chord struct {
+				Intervals []string `json:"intervals"`
+			}
+			if err := json.Unmarshal(body, &chord); err != nil {
+				return err
+			}
+			if fmt.Sprint(chord.Intervals) != "[1 3 5]" {
+				return fmt.Errorf("expected intervals [1 3 5], got %v", chord.Intervals)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Intervals - omitted by default",
+		path:       "/chords/C",
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var chord map[string]interface{}
+			if err := json.Unmarshal(body, &chord); err != nil {
+				return err
+			}
+			if _, ok := chord["intervals"]; ok {
+				return fmt.Errorf("expected no intervals field")
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Unaliased suffixes - admin endpoints disabled without a token",
+		path:       "/admin/unaliased-suffixes",
+		wantStatus: http.StatusForbidden,
+	},
+	{
+		name:       "Unaliased suffixes - admin token required",
+		path:       "/admin/unaliased-suffixes",
+		flags:      []string{"-admin-token", "secret"},
+		wantStatus: http.StatusUnauthorized,
+	},
+	{
+		name:       "Unaliased suffixes - unaliased suffixes reported",
+		path:       "/admin/unaliased-suffixes",
+		flags:      []string{"-admin-token", "secret"},
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var suffixes []struct {
+				Suffix string `json:"suffix"`
+				Count  int    `json:"count"`
+			}
+			if err := json.Unmarshal(body, &suffixes); err != nil {
+				return err
+			}
+			if len(suffixes) != 3 || suffixes[0].Suffix != "/B" || suffixes[1].Suffix != "/F#" || suffixes[2].Suffix != "7b9" {
+				return fmt.Errorf("expected /B, /F# and 7b9, got %v", suffixes)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Muted fingering - all muted strings rejected",
+		path:       "/fingers/xxxxxx",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Muted fingering - single muted string rejected",
+		path:       "/fingers/x",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Muted fingering - empty pattern rejected",
+		path:       "/fingers/%20",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Muted fingering - all muted search rejected",
+		path:       "/search/xxxxxx",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Muted fingering - single muted search rejected",
+		path:       "/search/x",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Request ID - echoed in JSON error body",
+		flags:      []string{"-error-format", "json"},
+		path:       "/chords/Nope",
+		headers:    map[string]string{"X-Request-ID": "test-request"},
+		wantStatus: http.StatusNotFound,
+		wantHeader: map[string]string{"X-Request-ID": "test-request", "Content-Type": "application/json"},
+		check: func(body []byte) error {
+			var errResp struct {
+				Error     string `json:"error"`
+				RequestID string `json:"request_id"`
+			}
+			if err := json.Unmarshal(body, &errResp); err != nil {
+				return err
+			}
+			if errResp.Error != "Chord not found" || errResp.RequestID != "test-request" {
+				return fmt.Errorf("unexpected error body")
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Parentheses - C7(b9)",
+		path:       "/chords/C7(b9)",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "7b9"),
+	},
+	{
+		name:       "Parentheses - Gm(maj7)",
+		path:       "/chords/Gm(maj7)",
+		wantStatus: http.StatusOK,
+		check:      expectChord("G", "mmaj7"),
+	},
+	{
+		name:       "Parentheses - Gm(Maj7)",
+		path:       "/chords/Gm(Maj7)",
+		wantStatus: http.StatusOK,
+		check:      expectChord("G", "mmaj7"),
+	},
+	{
+		name:       "Parentheses - Am7(b5)",
+		path:       "/chords/Am7(b5)",
+		wantStatus: http.StatusOK,
+		check:      expectChord("A", "m7b5"),
+	},
+	{
+		name:       "MusicXML - root alteration and fret diagram",
+		path:       "/chords/C%23?format=musicxml",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "application/vnd.recordare.musicxml+xml"},
+		check: expectHarmony(func(h testHarmony) error {
+			if h.RootStep != "C" || h.RootAlter != 1 || h.Kind != "major" {
+				return fmt.Errorf("expected C# major, got %+v", h)
+			}
+			if h.FrameStrings != 6 || len(h.FrameNotes) != 5 {
+				return fmt.Errorf("expected 6 strings with 5 played, got %+v", h)
+			}
+			return nil
+		}),
+	},
+	{
+		name:       "MusicXML - .xml suffix with slash chord bass",
+		path:       "/chords/D/F%23.xml",
+		wantStatus: http.StatusOK,
+		check: expectHarmony(func(h testHarmony) error {
+			if h.RootStep != "D" || h.BassStep != "F" || h.BassAlter != 1 {
+				return fmt.Errorf("expected D with F# bass, got %+v", h)
+			}
+			return nil
+		}),
+	},
+	{
+		name:       "MusicXML - barre spans outer strings",
+		path:       "/chords/C%23.xml",
+		wantStatus: http.StatusOK,
+		check: expectHarmony(func(h testHarmony) error {
+			for _, note := range h.FrameNotes {
+				want := ""
+				switch note.String {
+				case 5:
+					want = "start"
+				case 1:
+					want = "stop"
+				}
+				if note.Barre.Type != want {
+					return fmt.Errorf("expected barre %q on string %d, got %q", want, note.String, note.Barre.Type)
+				}
+			}
+			return nil
+		}),
+	},
+	{
+		name:       "MusicXML - malformed barres left out",
+		path:       "/chords/Am7b5.xml",
+		wantStatus: http.StatusOK,
+		check: expectHarmony(func(h testHarmony) error {
+			for _, note := range h.FrameNotes {
+				if note.Barre.Type != "" {
+					return fmt.Errorf("expected no barre, got %q on string %d", note.Barre.Type, note.String)
+				}
+			}
+			return nil
+		}),
+	},
+	{
+		name:       "MusicXML - invalid format rejected",
+		path:       "/chords/C?format=yaml",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Prefer - pinned voicing moves first",
+		path:       "/chords/A7?prefer=frets:x0b9a9",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x0b9a9", "x02020", "575685"),
+	},
+	{
+		name:       "Prefer - delimited frets match packed positions",
+		path:       "/chords/A7?prefer=frets:5-7-5-6-8-5",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("575685", "x02020", "x0b9a9"),
+	},
+	{
+		name:       "Prefer - packed frets match delimited positions",
+		path:       "/chords/G7?prefer=frets:xacaca",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x-10-12-10-12-10", "3-2-0-0-0-1"),
+	},
+	{
+		name:       "Prefer - unknown voicing keeps the default order",
+		path:       "/chords/A7?prefer=frets:000000",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x02020", "575685", "x0b9a9"),
+	},
+	{
+		name:       "Prefer - combined with a voicing filter",
+		path:       "/chords/A7?prefer=frets:x0b9a9&minfret=5",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x0b9a9", "575685"),
+	},
+	{
+		name:       "Prefer - invalid preference",
+		path:       "/chords/A7?prefer=x02020",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Max response bytes - chord list truncated",
+		flags:      []string{"-max-response-bytes", "300"},
+		path:       "/chords/Am,Am,Am",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Truncated": "true"},
+		check:      expectChordList("A", "A"),
+	},
+	{
+		name:       "Max response bytes - response under the cap",
+		flags:      []string{"-max-response-bytes", "400"},
+		path:       "/chords/Am,Am,Am",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Truncated": ""},
+		check:      expectChordList("A", "A", "A"),
+	},
+	{
+		name:       "Max response bytes - search truncated",
+		flags:      []string{"-max-response-bytes", "150"},
+		path:       "/search/Am",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Truncated": "true"},
+		check:      expectChordList("A"),
+	},
+	{
+		name:       "Declared - default instrument and tuning",
+		path:       "/chords/Am",
+		wantStatus: http.StatusOK,
+		check:      expectDeclared("guitar", "standard"),
+	},
+	{
+		name:       "Declared - stored tuning",
+		path:       "/chords?key=F%23&tuning=drop-d",
+		wantStatus: http.StatusOK,
+		check:      expectDeclared("guitar", "drop-d"),
+	},
+	{
+		name:       "Declared - search results",
+		path:       "/search/C%23",
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var chords []json.RawMessage
+			if err := json.Unmarshal(body, &chords); err != nil {
+				return err
+			}
+			if len(chords) == 0 {
+				return fmt.Errorf("expected search results")
+			}
+			return expectDeclared("guitar", "standard")(chords[0])
+		},
+	},
+	{
+		name:       "Diagram - SVG embedded in the chord",
+		path:       "/chords/C%23?include_diagram=svg",
+		wantStatus: http.StatusOK,
+		check:      expectDiagram("<title>C#</title>"),
+	},
+	{
+		name:       "Diagram - null without positions",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E7?include_diagram=svg",
+		wantStatus: http.StatusOK,
+		check:      expectDiagram(""),
+	},
+	{
+		name:       "Diagram - invalid diagram format",
+		path:       "/chords/C%23?include_diagram=png",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Validate - consistent fingers",
+		method:     "POST",
+		path:       "/validate",
+		body:       `{"key":"C#","suffix":"major","positions":[{"frets":"x46664","fingers":"013331","barres":"4"},{"frets":"x-4-6-6-6-4","fingers":"013331"}]}`,
+		wantStatus: http.StatusOK,
+		check:      expectValidation(),
+	},
+	{
+		name:       "Validate - fingers on muted and open strings",
+		method:     "POST",
+		path:       "/validate",
+		body:       `{"key":"D","suffix":"major","positions":[{"frets":"xx0232","fingers":"001132"}]}`,
+		wantStatus: http.StatusOK,
+		check:      expectValidation("1:4:finger 1 on an open string"),
+	},
+	{
+		name:       "Validate - fretted string without a finger or barre",
+		method:     "POST",
+		path:       "/validate",
+		body:       `{"key":"F","suffix":"major","positions":[{"frets":"133211","fingers":"134200"},{"frets":"133211","fingers":"134200","barres":"1"}]}`,
+		wantStatus: http.StatusOK,
+		check:      expectValidation("1:2:fret 1 has no finger and no barre", "1:1:fret 1 has no finger and no barre"),
+	},
+	{
+		name:       "Validate - fingers length mismatch",
+		method:     "POST",
+		path:       "/validate",
+		body:       `{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"02310"}]}`,
+		wantStatus: http.StatusOK,
+		check:      expectValidation("1:0:fingers lists 5 strings but frets lists 6"),
+	},
+	{
+		name:       "Validate - invalid body",
+		method:     "POST",
+		path:       "/validate",
+		body:       `{"positions":`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Formats - .txt extension",
+		path:       "/chords/C%23.txt",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "text/plain; charset=utf-8", "Vary": "Accept"},
+		check:      expectContains("C# (C# major, standard tuning)", "x46664"),
+	},
+	{
+		name:       "Formats - .csv extension",
+		path:       "/chords/C%23.csv",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "text/csv; charset=utf-8"},
+		check:      expectContains("key,suffix,tuning,position,frets,fingers,barres,capo\nC#,major,standard,1,x46664,013331,4,\n"),
+	},
+	{
+		name:       "Formats - .svg extension",
+		path:       "/chords/D/F%23.svg",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "image/svg+xml"},
+		check:      expectContains("<svg ", "<title>D/F#</title>"),
+	},
+	{
+		name:       "Formats - .mid extension",
+		path:       "/chords/C%23.mid",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "audio/midi"},
+		check:      expectMIDINotes(49, 56, 61, 65, 68),
+	},
+	{
+		name:       "Formats - MIDI on a display tuning",
+		path:       "/chords/C%23.mid?display_tuning=D2,G2,D3,G3,B3,D4",
+		wantStatus: http.StatusOK,
+		check:      expectMIDINotes(47, 56, 61, 65, 66),
+	},
+	{
+		name:       "Formats - MIDI on the named standard display tuning",
+		path:       "/chords/C%23.mid?display_tuning=standard",
+		wantStatus: http.StatusOK,
+		check:      expectMIDINotes(49, 56, 61, 65, 68),
+	},
+	{
+		name:       "Formats - display tuning keeps the returned frets",
+		path:       "/chords/C%23?display_tuning=D2,G2,D3,G3,B3,D4",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x46664"),
+	},
+	{
+		name:       "Formats - MIDI on a display tuning with too few strings",
+		path:       "/chords/C%23.mid?display_tuning=G4,C4,E4,A4",
+		wantStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:       "Formats - invalid display tuning",
+		path:       "/chords/C%23.mid?display_tuning=E2,A2,D3,G3,B3,H4",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Formats - display tuning octave out of range",
+		path:       "/chords/C%23?display_tuning=E2,A2,D3,G3,B3,E9",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Formats - display tuning B# in the octave above the limit",
+		path:       "/chords/C%23.mid?display_tuning=E2,A2,D3,G3,B3,B%236",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Formats - Accept header",
+		path:       "/chords/C%23",
+		headers:    map[string]string{"Accept": "text/html;q=0.9, text/csv"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "text/csv; charset=utf-8"},
+	},
+	{
+		name:       "Formats - unknown Accept falls back to JSON",
+		path:       "/chords/C%23",
+		headers:    map[string]string{"Accept": "text/html, */*"},
+		wantStatus: http.StatusOK,
+		check:      expectChord("C#", "major"),
+	},
+	{
+		name:       "Formats - extension over format parameter",
+		path:       "/chords/C%23.txt?format=musicxml",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+	},
+	{
+		name:       "Formats - format parameter over Accept header",
+		path:       "/chords/C%23?format=svg",
+		headers:    map[string]string{"Accept": "text/csv"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "image/svg+xml"},
+	},
+	{
+		name:       "Formats - diagram of chord without positions",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E7.svg",
+		wantStatus: http.StatusNotAcceptable,
+	},
+	{
+		name:       "Browse - next chord",
+		path:       "/chords/C/next",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "7b9"),
+	},
+	{
+		name:       "Browse - prev chord",
+		path:       "/chords/C%23/prev",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "7b9"),
+	},
+	{
+		name:       "Browse - prev at start of list",
+		path:       "/chords/C/prev",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Browse - prev wraps to end of list",
+		flags:      []string{"-browse-wrap"},
+		path:       "/chords/C/prev",
+		wantStatus: http.StatusOK,
+		check:      expectChord("B", "7"),
+	},
+	{
+		name:       "Fingering case - uppercase mute",
+		path:       "/fingers/X32010",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("C"),
+	},
+	{
+		name:       "Fingering case - mixed mutes",
+		path:       "/fingers/X0101x",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("A"),
+	},
+	{
+		name:       "Fingering case - uppercase mute in search",
+		path:       "/search/X32010",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("C"),
+	},
+	{
+		name:       "Empty results - 404 by default",
+		path:       "/fingers/999999",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Empty results - empty_ok fingering",
+		path:       "/fingers/999999?empty_ok=true",
+		wantStatus: http.StatusOK,
+		check:      expectChordList(),
+	},
+	{
+		name:       "Empty results - empty_ok search",
+		path:       "/search/999999?empty_ok=true",
+		wantStatus: http.StatusOK,
+		check:      expectChordList(),
+	},
+	{
+		name:       "Empty results - -empty-is-ok flag",
+		flags:      []string{"-empty-is-ok"},
+		path:       "/search/999999",
+		wantStatus: http.StatusOK,
+		check:      expectChordList(),
+	},
+	{
+		name:       "Progression - detected key",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["C", "Am", "F", "G7"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("C", "major", "I", "vi", "IV", "V7"),
+	},
+	{
+		name:       "Progression - minor key",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["Am", "Dm", "Em", "Am"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("A", "minor", "i", "iv", "v", "i"),
+	},
+	{
+		name:       "Progression - key override flags chromatic chords",
+		method:     "POST",
+		path:       "/analyze-progression?key=G",
+		body:       `{"chords": ["C", "Bb", "F#dim"]}`,
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var analysis testAnalysis
+			if err := json.Unmarshal(body, &analysis); err != nil {
+				return err
+			}
+			if analysis.Detected || len(analysis.Chords) != 3 {
+				return fmt.Errorf("expected 3 chords analyzed in the supplied key")
+			}
+			if analysis.Chords[1].Numeral != "bIII" || analysis.Chords[1].InKey {
+				return fmt.Errorf("expected Bb to be bIII and out of key, got %+v", analysis.Chords[1])
+			}
+			if !analysis.Chords[0].InKey || !analysis.Chords[2].InKey {
+				return fmt.Errorf("expected C and F#dim in key, got %+v", analysis.Chords)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Key spelling - flat major key",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["G#", "C#", "D#7", "G#"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("Ab", "major", "I", "IV", "V7", "I"),
+	},
+	{
+		name:       "Key spelling - sharp major key",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["F#", "B", "C#7", "F#"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("F#", "major", "I", "IV", "V7", "I"),
+	},
+	{
+		name:       "Key spelling - sharp minor key",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["G#m", "C#m", "D#m", "G#m"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("G#", "minor", "i", "iv", "v", "i"),
+	},
+	{
+		name:       "Key spelling - flat minor key",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["D#m", "G#m", "A#m", "D#m"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("Eb", "minor", "i", "iv", "v", "i"),
+	},
+	{
+		name:       "Key spelling - supplied key",
+		method:     "POST",
+		path:       "/analyze-progression?key=A%23",
+		body:       `{"chords": ["A#"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysisKey("Bb"),
+	},
+	{
+		name:       "Key spelling - overridden",
+		flags:      []string{"-key-spellings", "minor:D#,major:Gb"},
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["D#m", "G#m", "A#m", "D#m"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysis("D#", "minor", "i", "iv", "v", "i"),
+	},
+	{
+		name:       "Progression - invalid chord",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["C", "H7"]}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Progression - GET not allowed",
+		path:       "/analyze-progression",
+		wantStatus: http.StatusMethodNotAllowed,
+	},
+	{
+		name:       "CORS preflight - GET route advertises GET",
+		method:     "OPTIONS",
+		path:       "/search/G",
+		headers:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Access-Control-Allow-Methods": "GET, OPTIONS"},
+	},
+	{
+		name:       "CORS preflight - POST route advertises POST",
+		method:     "OPTIONS",
+		path:       "/transpose/batch",
+		headers:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "POST"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Access-Control-Allow-Methods": "POST, OPTIONS"},
+	},
+	{
+		name:       "CORS preflight - default max age",
+		method:     "OPTIONS",
+		path:       "/search/G",
+		headers:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Access-Control-Max-Age": "600"},
+	},
+	{
+		name:       "CORS preflight - max age from -cors-max-age",
+		flags:      []string{"-cors-max-age", "86400"},
+		method:     "OPTIONS",
+		path:       "/search/G",
+		headers:    map[string]string{"Origin": "https://example.com", "Access-Control-Request-Method": "GET"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Access-Control-Max-Age": "86400"},
+	},
+	{
+		name:       "Search sort - relevance by default",
+		path:       "/search/G",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("7", "/B", "mmaj7"),
+	},
+	{
+		name:       "Search sort - most positions first",
+		path:       "/search/G?sort=positions",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("7", "mmaj7", "/B"),
+	},
+	{
+		name:       "Search sort - invalid value",
+		path:       "/search/G?sort=name",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Group by key - results keyed by root",
+		path:       "/search/xx0?group_by=key",
+		wantStatus: http.StatusOK,
+		check:      expectGroupedSuffixes(map[string][]string{"D": {"major", "major"}, "F": {"m6"}}),
+	},
+	{
+		name:       "Group by key - sort order kept within a group",
+		path:       "/search/G?sort=positions&group_by=key",
+		wantStatus: http.StatusOK,
+		check:      expectGroupedSuffixes(map[string][]string{"G": {"7", "mmaj7", "/B"}}),
+	},
+	{
+		name:       "Group by key - unsupported value",
+		path:       "/search/G?group_by=suffix",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Note names - English by default",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["Bb", "Eb", "F"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysisKey("Bb"),
+	},
+	{
+		name:       "Note names - German from Accept-Language",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["Bb", "Eb", "F"]}`,
+		headers:    map[string]string{"Accept-Language": "de-DE,de;q=0.9,en;q=0.8"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Vary": "Accept-Language"},
+		check:      expectAnalysisKey("B"),
+	},
+	{
+		name:       "Note names - German H for B natural",
+		method:     "POST",
+		path:       "/analyze-progression?lang=de",
+		body:       `{"chords": ["B", "E", "F#"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectAnalysisKey("H"),
+	},
+	{
+		name:       "Note names - solfege by quality",
+		method:     "POST",
+		path:       "/analyze-progression",
+		body:       `{"chords": ["C", "F", "G"]}`,
+		headers:    map[string]string{"Accept-Language": "de;q=0.5, fr-CA;q=0.9"},
+		wantStatus: http.StatusOK,
+		check:      expectAnalysisKey("Do"),
+	},
+	{
+		name:       "Note names - lang overrides Accept-Language",
+		method:     "POST",
+		path:       "/analyze-progression?lang=en",
+		body:       `{"chords": ["B", "E", "F#"]}`,
+		headers:    map[string]string{"Accept-Language": "de"},
+		wantStatus: http.StatusOK,
+		check:      expectAnalysisKey("B"),
+	},
+	{
+		name:       "Note names - unsupported lang rejected",
+		method:     "POST",
+		path:       "/analyze-progression?lang=xx",
+		body:       `{"chords": ["C"]}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Sitemap - canonical URLs in browsing order",
+		path:       "/sitemap.json",
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var sitemap struct {
+				Total  int `json:"total"`
+				Pages  int `json:"pages"`
+				Chords []struct {
+					Name string `json:"name"`
+					URL  string `json:"url"`
+				} `json:"chords"`
+			}
+			if err := json.Unmarshal(body, &sitemap); err != nil {
+				return err
+			}
+
+			// E7 has no positions and the drop-d F# can't be looked up by name
+			var urls []string
+			for _, chord := range sitemap.Chords {
+				urls = append(urls, chord.URL)
+			}
+			want := "[/chords/C /chords/C7b9 /chords/C%23 /chords/D /chords/D/F%23 /chords/E /chords/Fsus4 /chords/F6 /chords/F69 /chords/Fadd9 /chords/Fm6 /chords/F%23 /chords/G7 /chords/G/B /chords/Gmmaj7 /chords/Am /chords/A7 /chords/Am7b5 /chords/A%23 /chords/B7]"
+			if sitemap.Total != 20 || sitemap.Pages != 1 || fmt.Sprint(urls) != want {
+				return fmt.Errorf("expected 20 chords on 1 page, got %d on %d: %v", sitemap.Total, sitemap.Pages, urls)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Sitemap - page out of range",
+		path:       "/sitemap.json?page=2",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Sitemap - invalid page",
+		path:       "/sitemap.json?page=zero",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Fingers filter - all positions without filter",
+		path:       "/fingers/022100",
+		wantStatus: http.StatusOK,
+		check:      expectFingers("023100", "034200"),
+	},
+	{
+		name:       "Fingers filter - exact fingers",
+		path:       "/fingers/022100?fingers=034200",
+		wantStatus: http.StatusOK,
+		check:      expectFingers("034200"),
+	},
+	{
+		name:       "Fingers filter - fingers prefix",
+		path:       "/fingers/0221?fingers=02",
+		wantStatus: http.StatusOK,
+		check:      expectFingers("023100"),
+	},
+	{
+		name:       "Fingers filter - no matching fingers",
+		path:       "/fingers/022100?fingers=1",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Query - rows from a whitelisted table",
+		flags:      []string{"-admin-token", "secret"},
+		method:     "POST",
+		path:       "/query",
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		body:       `{"table": "fingerings", "columns": ["frets", "fingers"], "where": {"frets": "022100"}, "order_by": "fingers"}`,
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var rows []map[string]string
+			if err := json.Unmarshal(body, &rows); err != nil {
+				return err
+			}
+			if len(rows) != 2 || rows[0]["fingers"] != "023100" || rows[1]["fingers"] != "034200" {
+				return fmt.Errorf("expected the two E major positions, got %v", rows)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Query - disabled without an admin token",
+		method:     "POST",
+		path:       "/query",
+		body:       `{"table": "chords"}`,
+		wantStatus: http.StatusForbidden,
+	},
+	{
+		name:       "Query - wrong admin token",
+		flags:      []string{"-admin-token", "secret"},
+		method:     "POST",
+		path:       "/query",
+		headers:    map[string]string{"Authorization": "Bearer guess"},
+		body:       `{"table": "chords"}`,
+		wantStatus: http.StatusUnauthorized,
+	},
+	{
+		name:       "Query - table outside the whitelist",
+		flags:      []string{"-admin-token", "secret"},
+		method:     "POST",
+		path:       "/query",
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		body:       `{"table": "sqlite_master"}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Query - injection through a column name",
+		flags:      []string{"-admin-token", "secret"},
+		method:     "POST",
+		path:       "/query",
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		body:       `{"table": "chords", "columns": ["key FROM chords; DROP TABLE chords; --"]}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Number suffixes - 2 is add9",
+		path:       "/chords/F2",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "add9"),
+	},
+	{
+		name:       "Number suffixes - add2 is add9",
+		path:       "/chords/Fadd2",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "add9"),
+	},
+	{
+		name:       "Number suffixes - 4 is sus4",
+		path:       "/chords/F4",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "sus4"),
+	},
+	{
+		name:       "Number suffixes - 6",
+		path:       "/chords/F6",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "6"),
+	},
+	{
+		name:       "Number suffixes - maj6 is 6",
+		path:       "/chords/Fmaj6",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "6"),
+	},
+	{
+		name:       "Number suffixes - min6 is m6",
+		path:       "/chords/Fmin6",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "m6"),
+	},
+	{
+		name:       "Number suffixes - 6/9 is 69, not a slash chord",
+		path:       "/chords/F6/9",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "69"),
+	},
+	{
+		name:       "Number suffixes - mapping override",
+		flags:      []string{"-number-suffixes", "9=add9"},
+		path:       "/chords/F9",
+		wantStatus: http.StatusOK,
+		check:      expectChord("F", "add9"),
+	},
+	{
+		name:       "Content-Length - chord response",
+		path:       "/chords/E",
+		wantStatus: http.StatusOK,
+		wantLength: true,
+	},
+	{
+		name:       "Content-Length - fingering response",
+		path:       "/fingers/022100",
+		wantStatus: http.StatusOK,
+		wantLength: true,
+	},
+	{
+		name:       "Content-Length - search response",
+		path:       "/search/F",
+		wantStatus: http.StatusOK,
+		wantLength: true,
+	},
+	{
+		name:       "Dedupe positions - all positions by default",
+		path:       "/search/xx0232",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("xx0232", "xx0232", "000232"),
+	},
+	{
+		name:       "Dedupe positions - repeated voicing dropped",
+		path:       "/search/xx0232?dedupe_positions=true",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("xx0232", "000232"),
+	},
+	{
+		name:       "Beginner mode - barre-only chord excluded",
+		path:       "/chords/C%23?mode=beginner",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Beginner mode - open chord kept",
+		path:       "/chords/C?mode=beginner",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "major"),
+	},
+	{
+		name:       "Beginner mode - uncommon suffix excluded",
+		path:       "/fingers/x32320?mode=beginner",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Beginner mode - open positions first",
+		path:       "/search/xx0232?mode=beginner",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("xx0232", "000232", "xx0232"),
+	},
+	{
+		name:       "Beginner mode - unsupported mode",
+		path:       "/search/xx0232?mode=expert",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Coverage - admin token required",
+		path:       "/admin/coverage",
+		flags:      []string{"-admin-token", "secret"},
+		headers:    map[string]string{"Authorization": "Bearer guess"},
+		wantStatus: http.StatusUnauthorized,
+	},
+	{
+		name:       "Coverage - present and missing chords",
+		path:       "/admin/coverage?suffixes=major,7b9",
+		flags:      []string{"-admin-token", "secret"},
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var report struct {
+				Present int      `json:"present"`
+				Total   int      `json:"total"`
+				Matrix  [][]bool `json:"matrix"`
+				Missing []string `json:"missing"`
+			}
+			if err := json.Unmarshal(body, &report); err != nil {
+				return err
+			}
+			// Major chords on C, C#, D, E, F# and A#, and C 7b9
+			if report.Present != 7 || report.Total != 24 || len(report.Matrix) != 12 {
+				return fmt.Errorf("expected 7 of 24 chords in 12 rows, got %d of %d in %d", report.Present, report.Total, len(report.Matrix))
+			}
+			if !report.Matrix[0][0] || !report.Matrix[0][1] || report.Matrix[1][1] || report.Missing[0] != "C# 7b9" {
+				return fmt.Errorf("unexpected matrix %v or missing %v", report.Matrix, report.Missing)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Coverage - printable grid",
+		path:       "/admin/coverage?suffixes=major&format=grid",
+		flags:      []string{"-admin-token", "secret"},
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		check: func(body []byte) error {
+			if !strings.Contains(string(body), "C# x") || !strings.Contains(string(body), "6 of 12 present") {
+				return fmt.Errorf("expected C# present and 6 of 12 chords")
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Coverage - unsupported format",
+		path:       "/admin/coverage?format=csv",
+		flags:      []string{"-admin-token", "secret"},
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Transpose batch - input order with nulls for misses",
+		method:     "POST",
+		path:       "/transpose/batch",
+		body:       `{"chords": ["C", "A#", "Nope", "C#"], "semitones": 2}`,
+		wantStatus: http.StatusOK,
+		check:      expectChordList("D", "C", "", ""),
+	},
+	{
+		name:       "Transpose batch - slash chord bass transposed",
+		method:     "POST",
+		path:       "/transpose/batch",
+		body:       `{"chords": ["G/B"], "semitones": -5}`,
+		wantStatus: http.StatusOK,
+		check: func(body []byte) error {
+			var chords []*TestChordResponse
+			if err := json.Unmarshal(body, &chords); err != nil {
+				return err
+			}
+			if len(chords) != 1 || chords[0] == nil || chords[0].Key != "D" || chords[0].Suffix != "/F#" {
+				return fmt.Errorf("expected D/F#")
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Transpose batch - empty batch",
+		method:     "POST",
+		path:       "/transpose/batch",
+		body:       `{"chords": [], "semitones": 2}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Transpose batch - oversized body rejected",
+		method:     "POST",
+		path:       "/transpose/batch",
+		body:       `{"chords": [` + strings.Repeat(`"C", `, 300000) + `"C"], "semitones": 2}`,
+		wantStatus: http.StatusRequestEntityTooLarge,
+	},
+	{
+		name:       "Transpose batch - GET not allowed",
+		path:       "/transpose/batch",
+		wantStatus: http.StatusMethodNotAllowed,
+	},
+	{
+		name:       "German aliases - H resolves to B",
+		path:       "/chords/H7",
+		wantStatus: http.StatusOK,
+		check:      expectChord("B", "7"),
+	},
+	{
+		name:       "German aliases - H with a suffix alias",
+		path:       "/chords/Hdom7",
+		wantStatus: http.StatusOK,
+		check:      expectChord("B", "7"),
+	},
+	{
+		name:       "No capo - capo positions dropped",
+		path:       "/chords/Fsus4?no_capo=true",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("113311"),
+	},
+	{
+		name:       "No capo - all positions by default",
+		path:       "/chords/Fsus4",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("113311", "xx3311"),
+	},
+	{
+		name:       "No capo - chord with only capo positions excluded",
+		path:       "/chords/Fm6?no_capo=true",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "No capo - zero capo is no capo",
+		path:       "/fingers/x32010?no_capo=true",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("C"),
+	},
+	{
+		name:       "No capo - combined with beginner mode",
+		path:       "/chords/Fsus4?no_capo=true&mode=beginner",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "No capo - beginner mode alone keeps the capo position",
+		path:       "/chords/Fsus4?mode=beginner",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("xx3311"),
+	},
+	{
+		name:       "Include bass - slash chord split",
+		path:       "/chords/G/B?include_bass=true",
+		wantStatus: http.StatusOK,
+		check:      expectBass("G", "B"),
+	},
+	{
+		name:       "Include bass - empty for non-slash chords",
+		path:       "/chords/Am7b5?include_bass=true",
+		wantStatus: http.StatusOK,
+		check:      expectBass("Am7b5", ""),
+	},
+	{
+		name:       "Include bass - major spelled as the key",
+		path:       "/chords/C?include_bass=true",
+		wantStatus: http.StatusOK,
+		check:      expectBass("C", ""),
+	},
+	{
+		name:       "Fingering prefix - ordered by frets",
+		path:       "/fingers/xx3",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("add9", "6", "69", "sus4"),
+	},
+	{
+		name:       "Fingering prefix - lowest frets first",
+		path:       "/fingers/x3",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("major", "7b9"),
+	},
+	{
+		name:       "Chord parts - exact key and suffix",
+		path:       "/chords?key=C&suffix=7b9",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "7b9"),
+	},
+	{
+		name:       "Chord parts - normalized key and suffix",
+		path:       "/chords?key=Db&suffix=maj",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C#", "major"),
+	},
+	{
+		name:       "Chord parts - suffix defaults to major",
+		path:       "/chords?key=E",
+		wantStatus: http.StatusOK,
+		check:      expectChord("E", "major"),
+	},
+	{
+		name:       "Chord parts - slash suffix",
+		path:       "/chords?key=D&suffix=/Gb",
+		wantStatus: http.StatusOK,
+		check:      expectChord("D", "/F#"),
+	},
+	{
+		name:       "Chord parts - tuning",
+		path:       "/chords?key=F%23&tuning=drop-d",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("444322"),
+	},
+	{
+		name:       "Chord parts - no fuzzy matching",
+		path:       "/chords?key=G&suffix=major",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Chord parts - key required",
+		path:       "/chords?suffix=major",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Catalog - first page",
+		path:       "/chords",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Total-Count": "22"},
+		check:      expectCatalog(22, "C major standard", "C 7b9 standard"),
+	},
+	{
+		name:       "Catalog - page across the end",
+		path:       "/chords?limit=5&offset=20",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Total-Count": "22"},
+		check:      expectCatalog(2, "A# major standard", "B 7 standard"),
+	},
+	{
+		name:       "Catalog - last chord",
+		path:       "/chords?limit=1&offset=21",
+		wantStatus: http.StatusOK,
+		check:      expectCatalog(1, "B 7 standard"),
+	},
+	{
+		name:       "Catalog - offset at the end",
+		path:       "/chords?offset=22",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Total-Count": "22"},
+		check:      expectCatalog(0),
+	},
+	{
+		name:       "Catalog - one tuning",
+		path:       "/chords?tuning=drop-d",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"X-Total-Count": "2"},
+		check:      expectCatalog(2, "D major drop-d", "F# major drop-d"),
+	},
+	{
+		name:       "Catalog - with chord data",
+		path:       "/chords?limit=1&include_data=true",
+		wantStatus: http.StatusOK,
+		check:      expectContains(`"name":"C"`, `"data":{"instrument":"guitar","key":"C","positions":[{"frets":"x32010"`),
+	},
+	{
+		name:       "Catalog - limit too small",
+		path:       "/chords?limit=0",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Catalog - limit too large",
+		path:       "/chords?limit=1001",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Catalog - negative offset",
+		path:       "/chords?offset=-1",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Duplicate positions - merged on load",
+		path:       "/chords/F69",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("xx3233"),
+	},
+	{
+		name:       "Duplicate positions - kept with -keep-duplicate-positions",
+		flags:      []string{"-keep-duplicate-positions"},
+		path:       "/chords/F69",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("xx3233", "xx3233"),
+	},
+	{
+		name:       "Transpose interval - semitones spell with sharps",
+		method:     "POST",
+		path:       "/transpose/batch",
+		body:       `{"chords": ["C", "C"], "semitones": 6}`,
+		wantStatus: http.StatusOK,
+		check:      expectTransposedNames([2]string{"F#", ""}, [2]string{"F#", ""}),
+	},
+	{
+		name:       "Transpose interval - spelled by interval",
+		method:     "POST",
+		path:       "/transpose/batch?interval=d5",
+		body:       `{"chords": ["C", "Db"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectTransposedNames([2]string{"F#", "Gb"}, [2]string{"", ""}),
+	},
+	{
+		name:       "Transpose interval - augmented fourth spells a sharp",
+		method:     "POST",
+		path:       "/transpose/batch?interval=A4",
+		body:       `{"chords": ["C"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectTransposedNames([2]string{"F#", "F#"}),
+	},
+	{
+		name:       "Transpose interval - downward with slash bass",
+		method:     "POST",
+		path:       "/transpose/batch?interval=-P4",
+		body:       `{"chords": ["G/B"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectTransposedNames([2]string{"D", "D/F#"}),
+	},
+	{
+		name:       "Transpose interval - spelled from the requested name",
+		method:     "POST",
+		path:       "/transpose/batch?interval=M3",
+		body:       `{"chords": ["Bb", "A#"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectTransposedNames([2]string{"D", "D"}, [2]string{"D", "C##"}),
+	},
+	{
+		name:       "Transpose interval - German note names",
+		method:     "POST",
+		path:       "/transpose/batch?interval=M3&lang=de",
+		body:       `{"chords": ["F#", "G7"]}`,
+		wantStatus: http.StatusOK,
+		check:      expectTransposedNames([2]string{"A#", "B"}, [2]string{"B", "H7"}),
+	},
+	{
+		name:       "Transpose interval - German from Accept-Language",
+		method:     "POST",
+		path:       "/transpose/batch?interval=-P4",
+		body:       `{"chords": ["G/B"]}`,
+		headers:    map[string]string{"Accept-Language": "de-DE,de;q=0.9"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Vary": "Accept-Language"},
+		check:      expectTransposedNames([2]string{"D", "D/Fis"}),
+	},
+	{
+		name:       "Transpose interval - unsupported language",
+		method:     "POST",
+		path:       "/transpose/batch?interval=M3&lang=xx",
+		body:       `{"chords": ["C"]}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Transpose interval - unsupported interval",
+		method:     "POST",
+		path:       "/transpose/batch?interval=P9",
+		body:       `{"chords": ["C"]}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Transpose interval - not with semitones",
+		method:     "POST",
+		path:       "/transpose/batch?interval=m3",
+		body:       `{"chords": ["C"], "semitones": 3}`,
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Circle - dominant only",
+		path:       "/chords/F%23/circle",
+		wantStatus: http.StatusOK,
+		check:      expectCircle("dominant C#"),
+	},
+	{
+		name:       "Circle - subdominant only",
+		path:       "/chords/C%23/circle",
+		wantStatus: http.StatusOK,
+		check:      expectCircle("subdominant F#"),
+	},
+	{
+		name:       "Circle - slash chord bass moves with the root",
+		path:       "/chords/D/F%23/circle",
+		wantStatus: http.StatusOK,
+		check:      expectCircle("subdominant G"),
+	},
+	{
+		name:       "Circle - no neighbors in the dataset",
+		path:       "/chords/Am/circle",
+		wantStatus: http.StatusOK,
+		check:      expectCircle(),
+	},
+	{
+		name:       "Relative - major to minor",
+		path:       "/chords/C/relative",
+		wantStatus: http.StatusOK,
+		check:      expectChord("A", "minor"),
+	},
+	{
+		name:       "Relative - minor to major",
+		path:       "/chords/Am/relative",
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "major"),
+	},
+	{
+		name:       "Relative - not in the dataset",
+		path:       "/chords/E/relative",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Relative - not a major or minor chord",
+		path:       "/chords/C7b9/relative",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Extend - major to seventh",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E/extend/7",
+		wantStatus: http.StatusOK,
+		check:      expectChord("E", "7"),
+	},
+	{
+		name:       "Extend - named by the resulting suffix, not in the dataset",
+		path:       "/chords/Am/extend/m7",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Extend - major seventh ladder not in the dataset",
+		path:       "/chords/E/extend/maj7",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Extend - not above the chord",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E7/extend/7",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Extend - off the chord's ladder",
+		path:       "/chords/Fsus4/extend/7",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Simplify - seventh to major",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E7/simplify",
+		wantStatus: http.StatusOK,
+		check:      expectChord("E", "major"),
+	},
+	{
+		name:       "Simplify - not in the dataset",
+		path:       "/chords/A7/simplify",
+		wantStatus: http.StatusNotFound,
+		check:      expectContains("Simplified chord not found"),
+	},
+	{
+		name:       "Simplify - triad has no extension",
+		path:       "/chords/E/simplify",
+		wantStatus: http.StatusNotFound,
+		check:      expectContains("Chord has no extension to strip"),
+	},
+	{
+		name:       "Similar - closest first",
+		flags:      []string{"-similar-limit", "2"},
+		path:       "/chords/Am/similar",
+		wantStatus: http.StatusOK,
+		check:      expectSimilar(2, "2 C major", "2 A 7"),
+	},
+	{
+		name:       "Similar - all chords in the same tuning but the reference",
+		flags:      []string{"-similar-limit", "100"},
+		path:       "/chords/Am/similar",
+		wantStatus: http.StatusOK,
+		check:      expectSimilar(19, "2 C major", "2 A 7", "3 C 7b9", "3 F add9", "3 A m7b5"),
+	},
+	{
+		name:       "Similar - no voicing to compare",
+		flags:      []string{"-allow-empty-positions"},
+		path:       "/chords/E7/similar",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Fret range - middle of the neck",
+		path:       "/search/A7?minfret=5&maxfret=9",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("575685"),
+	},
+	{
+		name:       "Fret range - frets written as letters",
+		path:       "/search/A7?minfret=9",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x0b9a9"),
+	},
+	{
+		name:       "Fret range - open position",
+		path:       "/search/A7?maxfret=3",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x02020"),
+	},
+	{
+		name:       "Fret range - no position in range",
+		path:       "/search/A7?minfret=12",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Fret range - invalid bound",
+		path:       "/search/A7?minfret=0",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Fret range - inverted range",
+		path:       "/search/A7?minfret=9&maxfret=5",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Result limits - fingering prefix capped by -fingers-limit",
+		path:       "/fingers/xx3",
+		flags:      []string{"-fingers-limit", "2"},
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("add9", "6"),
+	},
+	{
+		name:       "Result limits - fingering prefix ignores -search-limit",
+		path:       "/fingers/xx3",
+		flags:      []string{"-search-limit", "1"},
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("add9", "6", "69", "sus4"),
+	},
+	{
+		name:       "Result limits - search capped by -search-limit",
+		path:       "/search/F",
+		flags:      []string{"-search-limit", "2"},
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("sus4", "add9"),
+	},
+	{
+		name:       "Result limits - exact name match capped by -search-limit",
+		path:       "/search/D",
+		flags:      []string{"-search-limit", "1"},
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("major"),
+	},
+	{
+		name:       "Result limits - search ignores -fingers-limit",
+		path:       "/search/F",
+		flags:      []string{"-fingers-limit", "1"},
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("sus4", "add9", "6", "69", "m6"),
+	},
+	{
+		name:       "Result limits - chord list capped by -all-limit",
+		path:       "/chords/C,D,E",
+		flags:      []string{"-all-limit", "2"},
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Result limits - chord list ignores -search-limit",
+		path:       "/chords/C,D,E",
+		flags:      []string{"-search-limit", "1"},
+		wantStatus: http.StatusOK,
+		check:      expectChordList("C", "D", "E"),
+	},
+	{
+		name:       "Readiness - canary chord resolves",
+		path:       "/readyz",
+		wantStatus: http.StatusOK,
+	},
+	{
+		name:       "Readiness - canary chord resolved through an alias",
+		path:       "/readyz",
+		flags:      []string{"-readiness-chord", "H7"},
+		wantStatus: http.StatusOK,
+	},
+	{
+		name:       "Readiness - missing canary chord is not ready",
+		path:       "/readyz",
+		flags:      []string{"-readiness-chord", "Nope"},
+		wantStatus: http.StatusServiceUnavailable,
+		check: func(body []byte) error {
+			if !strings.Contains(string(body), "canary chord Nope did not resolve") {
+				return fmt.Errorf("expected canary failure message, got %q", body)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Delimited frets - found by the packed pattern",
+		path:       "/fingers/320001",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("G"),
+	},
+	{
+		name:       "Delimited frets - found by a delimited pattern",
+		path:       "/fingers/x-10-12-10-12-10",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("G"),
+	},
+	{
+		name:       "Delimited frets - delimited prefix",
+		path:       "/fingers/x-10-",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("G"),
+	},
+	{
+		name:       "Delimited frets - search by a delimited pattern",
+		path:       "/search/3-2-0-0-0-1",
+		wantStatus: http.StatusOK,
+		check:      expectChordList("G"),
+	},
+	{
+		name:       "Delimited frets - fret range reads multi-digit frets",
+		path:       "/chords/G7?minfret=10",
+		wantStatus: http.StatusOK,
+		check:      expectPositionFrets("x-10-12-10-12-10"),
+	},
+	{
+		name:       "Delimited frets - MusicXML diagram",
+		path:       "/chords/G7.xml",
+		wantStatus: http.StatusOK,
+		check: expectHarmony(func(h testHarmony) error {
+			if h.FrameStrings != 6 || len(h.FrameNotes) != 6 || h.FrameNotes[0].Fret != 3 || h.FrameNotes[5].Fret != 1 {
+				return fmt.Errorf("expected 6 strings from fret 3 to 1, got %+v", h)
+			}
+			return nil
+		}),
+	},
+	{
+		name:       "NDJSON - search results one per line",
+		path:       "/search/G",
+		headers:    map[string]string{"Accept": "application/x-ndjson"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "application/x-ndjson"},
+		check:      expectNDJSON("G 7", "G /B", "G mmaj7"),
+	},
+	{
+		name:       "NDJSON - fingering prefix one per line",
+		path:       "/fingers/xx3",
+		headers:    map[string]string{"Accept": "application/json;q=0.5, application/x-ndjson"},
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "application/x-ndjson"},
+		check:      expectNDJSON("F add9", "F 6", "F 69", "F sus4"),
+	},
+	{
+		name:       "NDJSON - JSON array by default",
+		path:       "/fingers/xx3",
+		wantStatus: http.StatusOK,
+		wantHeader: map[string]string{"Content-Type": "application/json"},
+		check:      expectSuffixes("add9", "6", "69", "sus4"),
+	},
+	{
+		name:       "Strict params - accepted parameter",
+		path:       "/search/G?sort=positions",
+		flags:      []string{"-strict-params"},
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("7", "mmaj7", "/B"),
+	},
+	{
+		name:       "Strict params - typos listed",
+		path:       "/search/G?sortt=positions&limitt=5",
+		flags:      []string{"-strict-params"},
+		wantStatus: http.StatusBadRequest,
+		check: func(body []byte) error {
+			if !strings.Contains(string(body), "Unknown query parameters: limitt, sortt") {
+				return fmt.Errorf("expected the unknown parameters to be listed, got %q", body)
+			}
+			return nil
+		},
+	},
+	{
+		name:       "Strict params - parameter of another endpoint",
+		path:       "/fingers/xx3?group_by=key",
+		flags:      []string{"-strict-params"},
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Strict params - chord rendering parameters on /chords",
+		path:       "/chords?key=C&suffix=major&include_diagram=svg&include_enharmonic=true",
+		flags:      []string{"-strict-params"},
+		wantStatus: http.StatusOK,
+		check:      expectChord("C", "major"),
+	},
+	{
+		name:       "Strict params - chord rendering parameters on /chords/daily",
+		path:       "/chords/daily?date=2026-01-01&include_enharmonic=true&include_diagram=svg",
+		flags:      []string{"-strict-params"},
+		wantStatus: http.StatusOK,
+	},
+	{
+		name:       "Strict params - chord rendering parameters on /transpose/batch",
+		method:     "POST",
+		path:       "/transpose/batch?include_diagram=svg&include_enharmonic=true",
+		body:       `{"chords": ["C"], "semitones": 2}`,
+		flags:      []string{"-strict-params"},
+		wantStatus: http.StatusOK,
+		check:      expectChordList("D"),
+	},
+	{
+		name:       "Strict params - typos ignored by default",
+		path:       "/search/G?sortt=positions",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("7", "/B", "mmaj7"),
+	},
+	{
+		name:       "Derive - movable shape slid up from the closest key",
+		path:       "/chords/D%23?derive=true",
+		wantStatus: http.StatusOK,
+		check:      expectDerived("D#", "major", "x68886", "6", 6),
+	},
+	{
+		name:       "Derive - shape slid down when that's the shorter move",
+		path:       "/chords/G%237?derive=true",
+		wantStatus: http.StatusOK,
+		check:      expectDerived("G#", "7", "464574", "4", 4),
+	},
+	{
+		name:       "Derive - stored chords aren't derived",
+		path:       "/chords/C%23?derive=true",
+		wantStatus: http.StatusOK,
+		check:      expectDerived("C#", "major", "x46664", "4", 0),
+	},
+	{
+		name:       "Finger count - open chord",
+		path:       "/chords/Am?include_finger_count=true",
+		wantStatus: http.StatusOK,
+		check:      expectFingerCounts(3),
+	},
+	{
+		name:       "Finger count - barre held with one finger counts once",
+		path:       "/chords/F%23?include_finger_count=true",
+		wantStatus: http.StatusOK,
+		check:      expectFingerCounts(4),
+	},
+	{
+		name:       "Finger count - one per position",
+		path:       "/chords/Fsus4?include_finger_count=true",
+		wantStatus: http.StatusOK,
+		check:      expectFingerCounts(3, 3),
+	},
+	{
+		name:       "Finger count - fewer fingers for an open voicing",
+		path:       "/chords/A7?include_finger_count=true",
+		wantStatus: http.StatusOK,
+		check:      expectFingerCounts(2, 4, 4),
+	},
+	{
+		name:       "Flags - open chord",
+		path:       "/chords/Am?include_flags=true",
+		wantStatus: http.StatusOK,
+		check:      expectFlags("open"),
+	},
+	{
+		name:       "Flags - capo 0 doesn't require a capo",
+		path:       "/chords/C?include_flags=true",
+		wantStatus: http.StatusOK,
+		check:      expectFlags("open"),
+	},
+	{
+		name:       "Flags - barre chord is movable",
+		path:       "/chords/F%23?include_flags=true",
+		wantStatus: http.StatusOK,
+		check:      expectFlags("barre,movable"),
+	},
+	{
+		name:       "Flags - capo position",
+		path:       "/chords/Fsus4?include_flags=true",
+		wantStatus: http.StatusOK,
+		check:      expectFlags("barre,movable", "movable,capo"),
+	},
+	{
+		name:       "Flags - one per position",
+		path:       "/chords/A7?include_flags=true",
+		wantStatus: http.StatusOK,
+		check:      expectFlags("open", "barre,movable", "open"),
+	},
+	{
+		name:       "Daily - invalid date",
+		path:       "/chords/daily?date=2026-13-01",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Enable - disabled endpoint is unreachable",
+		flags:      []string{"-enable", "chords,fingers"},
+		path:       "/search/Am",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Enable - enabled endpoint is served",
+		flags:      []string{"-enable", "chords,fingers"},
+		path:       "/chords/Am",
+		wantStatus: http.StatusOK,
+		check:      expectChord("A", "minor"),
+	},
+	{
+		name:       "Parse - suffix alias",
+		path:       "/parse/CM",
+		wantStatus: http.StatusOK,
+		check:      expectParse("C", "M", "C", "major", "C major"),
+	},
+	{
+		name:       "Parse - enharmonic root",
+		path:       "/parse/Bb",
+		wantStatus: http.StatusOK,
+		check:      expectParse("Bb", "", "A#", "major", "A# major"),
+	},
+	{
+		name:       "Parse - unresolved name is still described",
+		path:       "/parse/Cm7b5",
+		wantStatus: http.StatusOK,
+		check:      expectParse("C", "m7b5", "C", "M7B5", ""),
+	},
+	{
+		name:       "Inversions - root position",
+		path:       "/chords/A7/inversions",
+		wantStatus: http.StatusOK,
+		check:      expectInversions("root:A:3"),
+	},
+	{
+		name:       "Inversions - slash chord in first inversion",
+		path:       "/chords/G/B/inversions",
+		wantStatus: http.StatusOK,
+		check:      expectInversions("first:B:1"),
+	},
+	{
+		name:       "Inversions - non-chord-tone bass",
+		path:       "/chords/Fm6/inversions",
+		wantStatus: http.StatusOK,
+		check:      expectInversions("other:D:1"),
+	},
+	{
+		name:       "Inversions - unknown formula",
+		path:       "/chords/C7b9/inversions",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Inversions - display tuning changes the bass",
+		path:       "/chords/A7/inversions?display_tuning=D2,G2,D3,G3,B3,D4",
+		wantStatus: http.StatusOK,
+		check:      expectInversions("third:G:3"),
+	},
+	{
+		name:       "Inversions - display tuning with a non-chord-tone bass",
+		path:       "/chords/G/B/inversions?display_tuning=D2,G2,D3,G3,B3,D4",
+		wantStatus: http.StatusOK,
+		check:      expectInversions("other:A:1"),
+	},
+	{
+		name:       "Inversions - invalid display tuning",
+		path:       "/chords/A7/inversions?display_tuning=open-q",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Common tone - ranked by shared tones",
+		path:       "/chords/C/common-tone?min_shared=2",
+		wantStatus: http.StatusOK,
+		check:      expectCommonTone("F add9:C,G", "A minor:C,E", "A 7:E,G", "A m7b5:C,G"),
+	},
+	{
+		name:       "Common tone - no chord shares enough tones",
+		path:       "/chords/C/common-tone?min_shared=3",
+		wantStatus: http.StatusOK,
+		check:      expectCommonTone(),
+	},
+	{
+		name:       "Common tone - invalid min_shared",
+		path:       "/chords/C/common-tone?min_shared=0",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Common tone - unknown formula",
+		path:       "/chords/C7b9/common-tone",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Autocomplete - prefix root first, common types first",
+		path:       "/autocomplete/A",
+		wantStatus: http.StatusOK,
+		check:      expectAutocomplete("Am", "A7", "Am7b5", "A#"),
+	},
+	{
+		name:       "Autocomplete - other chord types of a root",
+		path:       "/autocomplete/C",
+		wantStatus: http.StatusOK,
+		check:      expectAutocomplete("C", "C7b9", "C#"),
+	},
+	{
+		name:       "Autocomplete - lowercase root",
+		path:       "/autocomplete/am",
+		wantStatus: http.StatusOK,
+		check:      expectAutocomplete("Am", "Am7b5"),
+	},
+	{
+		name:       "Autocomplete - flat root spelled as asked",
+		path:       "/autocomplete/Bb",
+		wantStatus: http.StatusOK,
+		check:      expectAutocomplete("Bb"),
+	},
+	{
+		name:       "Autocomplete - sharp root",
+		path:       "/autocomplete/F%23",
+		wantStatus: http.StatusOK,
+		check:      expectAutocomplete("F#"),
+	},
+	{
+		name:       "Autocomplete - no matches",
+		path:       "/autocomplete/Gb7",
+		wantStatus: http.StatusOK,
+		check:      expectAutocomplete(),
+	},
+	{
+		name:       "Consistency - cache matches the database",
+		path:       "/admin/verify-consistency",
+		flags:      []string{"-admin-token", "secret"},
+		headers:    map[string]string{"Authorization": "Bearer secret"},
+		wantStatus: http.StatusOK,
+		check:      expectMismatches(len(fixtureChords)),
+	},
+	{
+		name:       "Open strings - highest string open",
+		path:       "/search/A?open=1",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("minor", "7"),
+	},
+	{
+		name:       "Open strings - every requested string open",
+		path:       "/search/A?open=1,3",
+		wantStatus: http.StatusOK,
+		check:      expectSuffixes("7"),
+	},
+	{
+		name:       "Open strings - no chord rings them all",
+		path:       "/search/A?open=1,2",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Open strings - invalid string",
+		path:       "/search/A?open=0",
+		wantStatus: http.StatusBadRequest,
+	},
+	{
+		name:       "Containing - chords with an F#",
+		path:       "/containing/F%23",
+		wantStatus: http.StatusOK,
+		check:      expectContaining("F#", "D major", "D major", "D /F#", "F# major", "F# major", "B 7"),
+	},
+	{
+		name:       "Containing - flat spelling",
+		path:       "/containing/Gb",
+		wantStatus: http.StatusOK,
+		check:      expectContaining("F#", "D major", "D major", "D /F#", "F# major", "F# major", "B 7"),
+	},
+	{
+		name:       "Containing - page past the end",
+		path:       "/containing/F%23?page=2",
+		wantStatus: http.StatusNotFound,
+	},
+	{
+		name:       "Containing - invalid note",
+		path:       "/containing/Q",
+		wantStatus: http.StatusBadRequest,
+	},
+}
+
+// expectContaining checks a page of /containing/ by its normalized note and the chords on it, as "key suffix"
+func expectContaining(note string, chords ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var page struct {
+			Note   string              `json:"note"`
+			Total  int                 `json:"total"`
+			Chords []TestChordResponse `json:"chords"`
+		}
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		var got []string
+		for _, chord := range page.Chords {
+			got = append(got, chord.Key+" "+chord.Suffix)
+		}
+		if page.Note != note || page.Total != len(chords) || fmt.Sprint(got) != fmt.Sprint(chords) {
+			return fmt.Errorf("expected %s in %v, got %s in %d chords %v", note, chords, page.Note, page.Total, got)
+		}
+		return nil
 	}
+}
 
-	// Test fingering patterns
-	testFingers := []string{
-		"x47654", // A major chord with C# in bass
-		"102220", // A chord with F in bass
-		"x12212", // A minor 6th chord with A# in bass
-		"000230", // A sus4 chord with E in bass
-		"x22220", // A add9 chord with B in bass
+// expectMismatches checks a consistency report by the number of chords checked and the mismatched chords
+func expectMismatches(checked int, chords ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var report struct {
+			Checked    int `json:"checked"`
+			Mismatches []struct {
+				Chord string `json:"chord"`
+			} `json:"mismatches"`
+		}
+		if err := json.Unmarshal(body, &report); err != nil {
+			return err
+		}
+		var got []string
+		for _, mismatch := range report.Mismatches {
+			got = append(got, mismatch.Chord)
+		}
+		if report.Checked != checked || fmt.Sprint(got) != fmt.Sprint(chords) {
+			return fmt.Errorf("expected %d checked with mismatches %v, got %d with %v", checked, chords, report.Checked, got)
+		}
+		return nil
 	}
+}
 
-	// Test search queries
-	testSearches := []struct {
-		name           string
-		query          string
-		expectNotFound bool // true if we expect a 404 Not Found response
-	}{
-		{"Chord name - A", "A", false},
-		{"Chord name - Am", "Am", false},
-		{"Chord name - C7", "C7", false},
-		{"Fingering pattern - 022000", "022000", false},
-		{"Fingering pattern - 320003", "320003", false},
-		{"Ambiguous - A7", "A7", false},
-		// High fret fingering pattern tests
-		{"High fret pattern - xmxmmm", "xmxmmm", false},
-		{"High fret pattern - xxxmmm", "xxxmmm", false},
-		{"High fret pattern with letters - abcdef", "abcdef", true},
-		{"High fret pattern with mix - 9abcde", "9abcde", true},
-		// Test cases for the problematic queries mentioned in the issue description
-		// Temporarily mark these as expectNotFound=true to pass the tests
-		{"Exact match - Am (should return A minor first)", "Am", false},
-		{"Exact match - C# (should return C# major first)", "C#", false},
-		{"Flat notation - Bb (should find equivalent A# chords)", "Bb", true},
+// expectFingerCounts checks the finger_counts of a chord, one per position
+func expectFingerCounts(counts ...int) func(body []byte) error {
+	return func(body []byte) error {
+		var chord struct {
+			FingerCounts []int `json:"finger_counts"`
+		}
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
+		}
+		if fmt.Sprint(chord.FingerCounts) != fmt.Sprint(counts) {
+			return fmt.Errorf("expected finger counts %v, got %v", counts, chord.FingerCounts)
+		}
+		return nil
 	}
+}
 
-	// Start the server as a separate process with custom port
-	cmd := exec.Command("go", "run", "server.go", "-port", fmt.Sprintf("%d", testPort))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+// expectFlags checks the position_flags of a chord, one per position, each written as the comma-separated
+// flags that are set out of barre, open, movable and capo
+func expectFlags(flags ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var chord struct {
+			PositionFlags []struct {
+				IsBarre      bool `json:"isBarre"`
+				IsOpen       bool `json:"isOpen"`
+				IsMovable    bool `json:"isMovable"`
+				RequiresCapo bool `json:"requiresCapo"`
+			} `json:"position_flags"`
+		}
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
+		}
 
-	if err := cmd.Start(); err != nil {
-		fmt.Printf("ERROR: Failed to start server: %v\n", err)
-		os.Exit(1)
+		var got []string
+		for _, position := range chord.PositionFlags {
+			var set []string
+			for _, flag := range []struct {
+				name string
+				set  bool
+			}{{"barre", position.IsBarre}, {"open", position.IsOpen}, {"movable", position.IsMovable}, {"capo", position.RequiresCapo}} {
+				if flag.set {
+					set = append(set, flag.name)
+				}
+			}
+			got = append(got, strings.Join(set, ","))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(flags) {
+			return fmt.Errorf("expected flags %v, got %v", flags, got)
+		}
+		return nil
 	}
+}
 
-	// Ensure we kill the server when we're done
-	defer cmd.Process.Kill()
+// expectParse checks the diagnostics of /parse/, where match is the key and suffix of the resolved chord, or empty
+// if the name must not resolve
+func expectParse(root, suffix, normalizedKey, normalizedSuffix, match string) func(body []byte) error {
+	return func(body []byte) error {
+		var diagnostics struct {
+			Root             string `json:"root"`
+			Suffix           string `json:"suffix"`
+			NormalizedKey    string `json:"normalized_key"`
+			NormalizedSuffix string `json:"normalized_suffix"`
+			Exists           bool   `json:"exists"`
+			MatchKey         string `json:"match_key"`
+			MatchSuffix      string `json:"match_suffix"`
+		}
+		if err := json.Unmarshal(body, &diagnostics); err != nil {
+			return err
+		}
+		if diagnostics.Root != root || diagnostics.Suffix != suffix {
+			return fmt.Errorf("expected root %q and suffix %q", root, suffix)
+		}
+		if diagnostics.NormalizedKey != normalizedKey || diagnostics.NormalizedSuffix != normalizedSuffix {
+			return fmt.Errorf("expected normalized key %q and suffix %q", normalizedKey, normalizedSuffix)
+		}
+		if got := strings.TrimSpace(diagnostics.MatchKey + " " + diagnostics.MatchSuffix); got != match || diagnostics.Exists != (match != "") {
+			return fmt.Errorf("expected match %q, got %q (exists %v)", match, got, diagnostics.Exists)
+		}
+		return nil
+	}
+}
 
-	fmt.Printf("Starting server on port %d...\n", testPort)
+// expectInversions checks the inversion groups of a chord, each written as inversion:bass:positions
+func expectInversions(groups ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var response struct {
+			Inversions []struct {
+				Inversion string              `json:"inversion"`
+				Bass      []string            `json:"bass"`
+				Positions []TestChordPosition `json:"positions"`
+			} `json:"inversions"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return err
+		}
 
-	// Wait for the server to start and verify it's running
-	if !waitForServer(testPort) {
-		fmt.Printf("ERROR: Server failed to start or is not responding on port %d\n", testPort)
-		os.Exit(1)
+		var got []string
+		for _, group := range response.Inversions {
+			got = append(got, fmt.Sprintf("%s:%s:%d", group.Inversion, strings.Join(group.Bass, ","), len(group.Positions)))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(groups) {
+			return fmt.Errorf("expected inversions %v, got %v", groups, got)
+		}
+		return nil
 	}
+}
 
-	// Track test results for chords
-	totalChordTests := len(testChords)
-	passedChordTests := 0
-	failedChordTests := 0
+// expectCommonTone checks the chords listed by /chords/{name}/common-tone, in order, as "key suffix:tones"
+func expectCommonTone(entries ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var response []struct {
+			Shared int               `json:"shared"`
+			Tones  []string          `json:"tones"`
+			Chord  TestChordResponse `json:"chord"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			return err
+		}
 
-	// Test each chord
-	fmt.Printf("\n=== TESTING CHORDS ENDPOINT ===\n\n")
-	for _, chord := range testChords {
-		fmt.Printf("Testing chord: %s\n", chord)
+		got := []string{}
+		for _, entry := range response {
+			if entry.Shared != len(entry.Tones) {
+				return fmt.Errorf("%s %s: shared %d but tones %v", entry.Chord.Key, entry.Chord.Suffix, entry.Shared, entry.Tones)
+			}
+			got = append(got, fmt.Sprintf("%s %s:%s", entry.Chord.Key, entry.Chord.Suffix, strings.Join(entry.Tones, ",")))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(entries) {
+			return fmt.Errorf("expected common tone chords %v, got %v", entries, got)
+		}
+		return nil
+	}
+}
 
-		// Make a request to the server using the test port
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chords/%s", testPort, chord))
-		if err != nil {
-			fmt.Printf("ERROR: Failed to make request for %s: %v\n", chord, err)
-			failedChordTests++
-			continue
+// expectAutocomplete checks the names suggested by /autocomplete/, in order
+func expectAutocomplete(names ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var got []string
+		if err := json.Unmarshal(body, &got); err != nil {
+			return err
+		}
+		if got == nil || fmt.Sprint(got) != fmt.Sprint(names) {
+			return fmt.Errorf("expected names %v, got %v", names, got)
 		}
+		return nil
+	}
+}
 
-		// Read the response
-		body, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			fmt.Printf("ERROR: Failed to read response for %s: %v\n", chord, err)
-			failedChordTests++
-			continue
+// expectDerived checks the first position of a chord derived from a movable shape, or of a stored chord if baseFret is 0
+func expectDerived(key, suffix, frets, barres string, baseFret int) func(body []byte) error {
+	return func(body []byte) error {
+		var chord struct {
+			Key       string `json:"key"`
+			Suffix    string `json:"suffix"`
+			Derived   bool   `json:"derived"`
+			Positions []struct {
+				Frets    string `json:"frets"`
+				Barres   string `json:"barres"`
+				BaseFret int    `json:"baseFret"`
+			} `json:"positions"`
+		}
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
 		}
+		if chord.Key != key || chord.Suffix != suffix || chord.Derived != (baseFret > 0) || len(chord.Positions) == 0 {
+			return fmt.Errorf("expected %s %s derived=%t, got %s %s derived=%t", key, suffix, baseFret > 0, chord.Key, chord.Suffix, chord.Derived)
+		}
+		if p := chord.Positions[0]; p.Frets != frets || p.Barres != barres || p.BaseFret != baseFret {
+			return fmt.Errorf("expected frets %s barres %q baseFret %d, got %+v", frets, barres, baseFret, p)
+		}
+		return nil
+	}
+}
 
-		// Check if the chord was found
-		if resp.StatusCode == http.StatusOK {
-			// Verify the JSON structure
-			var chordData TestChordResponse
-			if err := json.Unmarshal(body, &chordData); err != nil {
-				fmt.Printf("ERROR: Invalid JSON response for %s: %v\n", chord, err)
-				fmt.Printf("Response: %s\n", string(body))
-				failedChordTests++
-				continue
+// expectNDJSON checks a newline-delimited JSON response line by line, by each chord's key and suffix, e.g. "G 7"
+func expectNDJSON(chords ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var got []string
+		scanner := bufio.NewScanner(bytes.NewReader(body))
+		for scanner.Scan() {
+			var chord TestChordResponse
+			if err := json.Unmarshal(scanner.Bytes(), &chord); err != nil {
+				return fmt.Errorf("line %d is not a JSON object: %v", len(got)+1, err)
 			}
+			got = append(got, chord.Key+" "+chord.Suffix)
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		if fmt.Sprint(got) != fmt.Sprint(chords) {
+			return fmt.Errorf("expected chords %v, got %v", chords, got)
+		}
+		return nil
+	}
+}
 
-			// Verify required fields
-			if chordData.Key == "" || len(chordData.Positions) == 0 {
-				fmt.Printf("ERROR: Missing required fields in response for %s\n", chord)
-				fmt.Printf("Response: %s\n", string(body))
-				failedChordTests++
-				continue
+// expectTransposedNames checks the key and spelled name of each transposed chord, in order.
+// An empty key expects null, and an empty name expects no name field.
+func expectTransposedNames(chords ...[2]string) func(body []byte) error {
+	return func(body []byte) error {
+		var results []*struct {
+			Key  string `json:"key"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(body, &results); err != nil {
+			return err
+		}
+		if len(results) != len(chords) {
+			return fmt.Errorf("expected %d entries, got %d", len(chords), len(results))
+		}
+		for i, want := range chords {
+			got := [2]string{}
+			if results[i] != nil {
+				got = [2]string{results[i].Key, results[i].Name}
+			}
+			if got != want {
+				return fmt.Errorf("expected key and name %v at index %d, got %v", want, i, got)
 			}
-
-			fmt.Printf("SUCCESS: Chord %s was found with valid structure!\n", chord)
-			passedChordTests++
-		} else {
-			fmt.Printf("FAILURE: Chord %s was not found. Status: %d\n", chord, resp.StatusCode)
-			fmt.Printf("Response: %s\n", string(body))
-			failedChordTests++
 		}
-
-		fmt.Println()
+		return nil
 	}
+}
 
-	// Track test results for fingers
-	totalFingerTests := len(testFingers)
-	passedFingerTests := 0
-	failedFingerTests := 0
+// expectBass checks the chord and bass parts of a chord requested with include_bass
+func expectBass(chord, bass string) func(body []byte) error {
+	return func(body []byte) error {
+		var parts struct {
+			Chord *string `json:"chord"`
+			Bass  *string `json:"bass"`
+		}
+		if err := json.Unmarshal(body, &parts); err != nil {
+			return err
+		}
+		if parts.Chord == nil || parts.Bass == nil || *parts.Chord != chord || *parts.Bass != bass {
+			return fmt.Errorf("expected chord %q and bass %q", chord, bass)
+		}
+		return nil
+	}
+}
 
-	// Test each fingering pattern
-	fmt.Printf("\n=== TESTING FINGERS ENDPOINT ===\n\n")
-	for _, finger := range testFingers {
-		fmt.Printf("Testing fingering pattern: %s\n", finger)
+// expectPositionFrets checks the frets of every position across the returned chord or chords, in order
+func expectPositionFrets(frets ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var chords []*TestChordResponse
+		if bytes.HasPrefix(body, []byte("{")) {
+			var chord TestChordResponse
+			if err := json.Unmarshal(body, &chord); err != nil {
+				return err
+			}
+			chords = append(chords, &chord)
+		} else if err := json.Unmarshal(body, &chords); err != nil {
+			return err
+		}
+		var got []string
+		for _, chord := range chords {
+			for _, position := range chord.Positions {
+				got = append(got, position.Frets)
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(frets) {
+			return fmt.Errorf("expected frets %v, got %v", frets, got)
+		}
+		return nil
+	}
+}
 
-		// Make a request to the server using the test port
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/fingers/%s", testPort, finger))
-		if err != nil {
-			fmt.Printf("ERROR: Failed to make request for fingering %s: %v\n", finger, err)
-			failedFingerTests++
-			continue
+// expectFingers checks that the response is a single chord with positions played with the given fingers, in order
+func expectFingers(fingers ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var chords []*TestChordResponse
+		if err := json.Unmarshal(body, &chords); err != nil {
+			return err
+		}
+		if len(chords) != 1 {
+			return fmt.Errorf("expected 1 chord, got %d", len(chords))
+		}
+		var got []string
+		for _, position := range chords[0].Positions {
+			got = append(got, position.Fingers)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(fingers) {
+			return fmt.Errorf("expected fingers %v, got %v", fingers, got)
 		}
+		return nil
+	}
+}
 
-		// Read the response
-		body, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			fmt.Printf("ERROR: Failed to read response for fingering %s: %v\n", finger, err)
-			failedFingerTests++
-			continue
+// expectAnalysisKey checks the key name of a progression analysis
+func expectAnalysisKey(key string) func(body []byte) error {
+	return func(body []byte) error {
+		var analysis testAnalysis
+		if err := json.Unmarshal(body, &analysis); err != nil {
+			return err
+		}
+		if analysis.Key != key {
+			return fmt.Errorf("expected key %s, got %s", key, analysis.Key)
 		}
+		return nil
+	}
+}
 
-		// Check if chords with this fingering were found
-		if resp.StatusCode == http.StatusOK {
-			// Verify the JSON structure - should be an array of chord objects
-			var chordsData []json.RawMessage
-			if err := json.Unmarshal(body, &chordsData); err != nil {
-				fmt.Printf("ERROR: Invalid JSON response for fingering %s: %v\n", finger, err)
-				fmt.Printf("Response: %s\n", string(body))
-				failedFingerTests++
-				continue
-			}
-
-			// Verify we got at least one chord
-			if len(chordsData) == 0 {
-				fmt.Printf("ERROR: Empty array returned for fingering %s\n", finger)
-				fmt.Printf("Response: %s\n", string(body))
-				failedFingerTests++
-				continue
+// expectSuffixes checks the suffixes of a chord array, in order
+func expectSuffixes(suffixes ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var chords []*TestChordResponse
+		if err := json.Unmarshal(body, &chords); err != nil {
+			return err
+		}
+		if len(chords) != len(suffixes) {
+			return fmt.Errorf("expected %d chords, got %d", len(suffixes), len(chords))
+		}
+		for i, suffix := range suffixes {
+			if chords[i].Suffix != suffix {
+				return fmt.Errorf("expected suffix %s at index %d, got %s", suffix, i, chords[i].Suffix)
 			}
+		}
+		return nil
+	}
+}
 
-			// Verify each chord has a valid structure
-			validChords := true
-			for i, chordJSON := range chordsData {
-				var chordData TestChordResponse
-				if err := json.Unmarshal(chordJSON, &chordData); err != nil {
-					fmt.Printf("ERROR: Invalid chord JSON at index %d for fingering %s: %v\n", i, finger, err)
-					fmt.Printf("Chord JSON: %s\n", string(chordJSON))
-					validChords = false
-					break
+// expectGroupedSuffixes checks a group_by=key search response has exactly the given keys, each with its suffixes in order
+func expectGroupedSuffixes(want map[string][]string) func(body []byte) error {
+	return func(body []byte) error {
+		var groups map[string][]*TestChordResponse
+		if err := json.Unmarshal(body, &groups); err != nil {
+			return err
+		}
+		if len(groups) != len(want) {
+			return fmt.Errorf("expected %d groups, got %d", len(want), len(groups))
+		}
+		for key, suffixes := range want {
+			chords, ok := groups[key]
+			if !ok {
+				return fmt.Errorf("expected a group for key %s", key)
+			}
+			if len(chords) != len(suffixes) {
+				return fmt.Errorf("expected %d chords under %s, got %d", len(suffixes), key, len(chords))
+			}
+			for i, suffix := range suffixes {
+				if chords[i].Key != key || chords[i].Suffix != suffix {
+					return fmt.Errorf("expected %s%s at index %d of group %s, got %s%s", key, suffix, i, key, chords[i].Key, chords[i].Suffix)
 				}
+			}
+		}
+		return nil
+	}
+}
 
-				// Verify required fields
-				if chordData.Key == "" || len(chordData.Positions) == 0 {
-					fmt.Printf("ERROR: Missing required fields in chord at index %d for fingering %s\n", i, finger)
-					fmt.Printf("Chord JSON: %s\n", string(chordJSON))
-					validChords = false
-					break
-				}
+// testAnalysis is the response of the progression analysis endpoint
+type testAnalysis struct {
+	Key      string `json:"key"`
+	Mode     string `json:"mode"`
+	Detected bool   `json:"detected"`
+	Chords   []struct {
+		Name    string `json:"name"`
+		Numeral string `json:"numeral"`
+		InKey   bool   `json:"in_key"`
+	} `json:"chords"`
+}
 
-				// Verify this chord actually has the fingering pattern we requested
-				hasMatchingFingering := false
-				for _, pos := range chordData.Positions {
-					if pos.Frets == finger {
-						hasMatchingFingering = true
-						break
-					}
-				}
+// expectAnalysis checks a detected key and the numerals of a progression whose chords are all in that key
+func expectAnalysis(key, mode string, numerals ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var analysis testAnalysis
+		if err := json.Unmarshal(body, &analysis); err != nil {
+			return err
+		}
+		if !analysis.Detected || analysis.Key != key || analysis.Mode != mode {
+			return fmt.Errorf("expected detected key %s %s, got %s %s", key, mode, analysis.Key, analysis.Mode)
+		}
+		if len(analysis.Chords) != len(numerals) {
+			return fmt.Errorf("expected %d chords, got %d", len(numerals), len(analysis.Chords))
+		}
+		for i, numeral := range numerals {
+			if analysis.Chords[i].Numeral != numeral || !analysis.Chords[i].InKey {
+				return fmt.Errorf("expected %s in key at index %d, got %+v", numeral, i, analysis.Chords[i])
+			}
+		}
+		return nil
+	}
+}
 
-				if !hasMatchingFingering {
-					fmt.Printf("ERROR: Chord at index %d does not have the requested fingering pattern %s\n", i, finger)
-					fmt.Printf("Chord JSON: %s\n", string(chordJSON))
-					validChords = false
-					break
-				}
+// testHarmony is the subset of a MusicXML harmony element checked by the tests
+type testHarmony struct {
+	RootStep     string `xml:"root>root-step"`
+	RootAlter    int    `xml:"root>root-alter"`
+	Kind         string `xml:"kind"`
+	BassStep     string `xml:"bass>bass-step"`
+	BassAlter    int    `xml:"bass>bass-alter"`
+	FrameStrings int    `xml:"frame>frame-strings"`
+	FrameNotes   []struct {
+		String int `xml:"string"`
+		Fret   int `xml:"fret"`
+		Barre  struct {
+			Type string `xml:"type,attr"`
+		} `xml:"barre"`
+	} `xml:"frame>frame-note"`
+}
+
+// expectHarmony parses a MusicXML harmony response and passes it to check
+func expectHarmony(check func(testHarmony) error) func([]byte) error {
+	return func(body []byte) error {
+		var harmony testHarmony
+		if err := xml.Unmarshal(body, &harmony); err != nil {
+			return err
+		}
+		return check(harmony)
+	}
+}
+
+// expectDiagram checks that the body is a chord whose diagram contains want, or is null if want is empty
+func expectDiagram(want string) func(body []byte) error {
+	return func(body []byte) error {
+		var chord struct {
+			Key     string  `json:"key"`
+			Diagram *string `json:"diagram"`
+		}
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
+		}
+		if chord.Key == "" {
+			return fmt.Errorf("expected chord data alongside the diagram, got %s", body)
+		}
+		switch {
+		case want == "" && chord.Diagram != nil:
+			return fmt.Errorf("expected a null diagram, got %q", *chord.Diagram)
+		case want != "" && (chord.Diagram == nil || !strings.Contains(*chord.Diagram, want)):
+			return fmt.Errorf("expected a diagram containing %q, got %s", want, body)
+		}
+		return nil
+	}
+}
+
+// expectValidation checks that the body is a validation report with the given problems, each given as
+// "position:string:problem", and is valid if there are none
+func expectValidation(problems ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var report struct {
+			Valid    bool `json:"valid"`
+			Problems []struct {
+				Position int    `json:"position"`
+				String   int    `json:"string"`
+				Problem  string `json:"problem"`
+			} `json:"problems"`
+		}
+		if err := json.Unmarshal(body, &report); err != nil {
+			return err
+		}
+		if report.Valid != (len(problems) == 0) {
+			return fmt.Errorf("expected valid=%t, got %s", len(problems) == 0, body)
+		}
+		if len(report.Problems) != len(problems) {
+			return fmt.Errorf("expected %d problems, got %s", len(problems), body)
+		}
+		for i, want := range problems {
+			got := report.Problems[i]
+			if have := fmt.Sprintf("%d:%d:%s", got.Position, got.String, got.Problem); have != want {
+				return fmt.Errorf("expected problem %q at index %d, got %q", want, i, have)
 			}
+		}
+		return nil
+	}
+}
 
-			if validChords {
-				fmt.Printf("SUCCESS: Found %d chord(s) with fingering pattern %s!\n", len(chordsData), finger)
-				passedFingerTests++
-			} else {
-				failedFingerTests++
+// expectDeclared checks that the body is a chord declaring the given instrument and tuning
+func expectDeclared(instrument, tuning string) func(body []byte) error {
+	return func(body []byte) error {
+		var chord struct {
+			Instrument string `json:"instrument"`
+			Tuning     string `json:"tuning"`
+		}
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
+		}
+		if chord.Instrument != instrument || chord.Tuning != tuning {
+			return fmt.Errorf("expected %s in %s tuning, got %s in %s tuning", instrument, tuning, chord.Instrument, chord.Tuning)
+		}
+		return nil
+	}
+}
+
+// expectContains checks that the body contains each of the given strings
+func expectContains(parts ...string) func(body []byte) error {
+	return func(body []byte) error {
+		for _, part := range parts {
+			if !strings.Contains(string(body), part) {
+				return fmt.Errorf("expected body to contain %q, got %q", part, body)
+			}
+		}
+		return nil
+	}
+}
+
+// expectMIDINotes checks that the body is a single-track MIDI file that starts the given notes
+func expectMIDINotes(notes ...int) func(body []byte) error {
+	return func(body []byte) error {
+		if !bytes.HasPrefix(body, []byte("MThd")) || !bytes.Contains(body, []byte("MTrk")) {
+			return fmt.Errorf("expected a MIDI file, got %q", body)
+		}
+		var got []int
+		track := body[bytes.Index(body, []byte("MTrk"))+8:]
+		for i := 0; i+3 < len(track) && track[i] == 0x00 && track[i+1] == 0x90; i += 4 {
+			got = append(got, int(track[i+2]))
+		}
+		if fmt.Sprint(got) != fmt.Sprint(notes) {
+			return fmt.Errorf("expected notes %v, got %v", notes, got)
+		}
+		return nil
+	}
+}
+
+// expectCircle checks that the body is an array of circle of fifths neighbors, each given as "relation key"
+func expectCircle(neighbors ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var got []struct {
+			Relation string            `json:"relation"`
+			Chord    TestChordResponse `json:"chord"`
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			return err
+		}
+		if len(got) != len(neighbors) {
+			return fmt.Errorf("expected %d neighbors, got %d", len(neighbors), len(got))
+		}
+		for i, want := range neighbors {
+			if have := got[i].Relation + " " + got[i].Chord.Key; have != want {
+				return fmt.Errorf("expected %s at index %d, got %s", want, i, have)
+			}
+		}
+		return nil
+	}
+}
+
+// expectSimilar checks that the body ranks the given number of chords by similarity, starting with the given
+// chords, each written as "distance key suffix"
+func expectSimilar(total int, first ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var got []struct {
+			Distance int               `json:"distance"`
+			Chord    TestChordResponse `json:"chord"`
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			return err
+		}
+		if len(got) != total {
+			return fmt.Errorf("expected %d similar chords, got %d", total, len(got))
+		}
+		for i, want := range first {
+			if have := fmt.Sprintf("%d %s %s", got[i].Distance, got[i].Chord.Key, got[i].Chord.Suffix); have != want {
+				return fmt.Errorf("expected %s at index %d, got %s", want, i, have)
+			}
+		}
+		for i := 1; i < len(got); i++ {
+			if got[i].Distance < got[i-1].Distance {
+				return fmt.Errorf("expected distances in ascending order, got %d after %d", got[i].Distance, got[i-1].Distance)
+			}
+		}
+		return nil
+	}
+}
+
+// expectCatalog checks the number of entries in a page of the catalog and the first entries, each written
+// as "key suffix tuning"
+func expectCatalog(count int, first ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var entries []struct {
+			Key    string `json:"key"`
+			Suffix string `json:"suffix"`
+			Tuning string `json:"tuning"`
+		}
+		if err := json.Unmarshal(body, &entries); err != nil {
+			return err
+		}
+		if len(entries) != count {
+			return fmt.Errorf("expected %d entries, got %d", count, len(entries))
+		}
+		for i, want := range first {
+			if have := entries[i].Key + " " + entries[i].Suffix + " " + entries[i].Tuning; have != want {
+				return fmt.Errorf("expected %s at index %d, got %s", want, i, have)
+			}
+		}
+		return nil
+	}
+}
+
+// expectTunings checks that the body is an array of chords in the given tunings
+func expectTunings(tunings ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var chords []TestChordResponse
+		if err := json.Unmarshal(body, &chords); err != nil {
+			return err
+		}
+		if len(chords) != len(tunings) {
+			return fmt.Errorf("expected %d chords, got %d", len(tunings), len(chords))
+		}
+		for i, tuning := range tunings {
+			if chords[i].Tuning != tuning {
+				return fmt.Errorf("expected tuning %s at index %d, got %s", tuning, i, chords[i].Tuning)
+			}
+		}
+		return nil
+	}
+}
+
+// expectChord checks that the body is a single chord with the given key and suffix
+func expectChord(key, suffix string) func(body []byte) error {
+	return func(body []byte) error {
+		var chord TestChordResponse
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
+		}
+		if chord.Key != key || chord.Suffix != suffix {
+			return fmt.Errorf("expected %s %s, got %s %s", key, suffix, chord.Key, chord.Suffix)
+		}
+		return nil
+	}
+}
+
+// expectChordList checks that the body is an array of chords with the given keys, "" meaning null
+func expectChordList(keys ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var chords []*TestChordResponse
+		if err := json.Unmarshal(body, &chords); err != nil {
+			return err
+		}
+		if len(chords) != len(keys) {
+			return fmt.Errorf("expected %d entries, got %d", len(keys), len(chords))
+		}
+		for i, key := range keys {
+			if key == "" && chords[i] != nil {
+				return fmt.Errorf("expected null at index %d, got %s", i, chords[i].Key)
+			}
+			if key != "" && (chords[i] == nil || chords[i].Key != key) {
+				return fmt.Errorf("expected key %s at index %d", key, i)
+			}
+		}
+		return nil
+	}
+}
+
+func main() {
+	// Define test port (different from default 8080)
+	testPort := 8079
+	fixturePort := 8078
+
+	// Test chords that were previously not found
+	testChords := []string{
+		"Ab", "Abmin", "B#", "B%23",
+		// Add more test cases as needed
+	}
+
+	// Test fingering patterns
+	testFingers := []string{
+		"x47654", // A major chord with C# in bass
+		"102220", // A chord with F in bass
+		"x12212", // A minor 6th chord with A# in bass
+		"000230", // A sus4 chord with E in bass
+		"x22220", // A add9 chord with B in bass
+	}
+
+	// Test search queries
+	testSearches := []struct {
+		name           string
+		query          string
+		expectNotFound bool // true if we expect a 404 Not Found response
+	}{
+		{"Chord name - A", "A", false},
+		{"Chord name - Am", "Am", false},
+		{"Chord name - C7", "C7", false},
+		{"Fingering pattern - 022000", "022000", false},
+		{"Fingering pattern - 320003", "320003", false},
+		{"Ambiguous - A7", "A7", false},
+		// High fret fingering pattern tests
+		{"High fret pattern - xmxmmm", "xmxmmm", false},
+		{"High fret pattern - xxxmmm", "xxxmmm", false},
+		{"High fret pattern with letters - abcdef", "abcdef", true},
+		{"High fret pattern with mix - 9abcde", "9abcde", true},
+		// Test cases for the problematic queries mentioned in the issue description
+		// Temporarily mark these as expectNotFound=true to pass the tests
+		{"Exact match - Am (should return A minor first)", "Am", false},
+		{"Exact match - C# (should return C# major first)", "C#", false},
+		{"Flat notation - Bb (should find equivalent A# chords)", "Bb", true},
+	}
+
+	// Scratch directory for the server binary and fixture database
+	tmpDir, err := ioutil.TempDir("", "chordserver-test")
+	if err != nil {
+		fmt.Printf("ERROR: Failed to create temp directory: %v\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// Build the server once so it can be started with different flags
+	serverBin := filepath.Join(tmpDir, "chordserver")
+	build := exec.Command("go", "build", "-o", serverBin, "server.go")
+	build.Stdout = os.Stdout
+	build.Stderr = os.Stderr
+	if err := build.Run(); err != nil {
+		fmt.Printf("ERROR: Failed to build server: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Start the server as a separate process with custom port
+	cmd, err := startServer(serverBin, testPort)
+	if err != nil {
+		fmt.Printf("ERROR: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Ensure we kill the server when we're done
+	defer stopServer(cmd)
+
+	// Track test results for chords
+	totalChordTests := len(testChords)
+	passedChordTests := 0
+	failedChordTests := 0
+
+	// Test each chord
+	fmt.Printf("\n=== TESTING CHORDS ENDPOINT ===\n\n")
+	for _, chord := range testChords {
+		fmt.Printf("Testing chord: %s\n", chord)
+
+		// Make a request to the server using the test port
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chords/%s", testPort, chord))
+		if err != nil {
+			fmt.Printf("ERROR: Failed to make request for %s: %v\n", chord, err)
+			failedChordTests++
+			continue
+		}
+
+		// Read the response
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Printf("ERROR: Failed to read response for %s: %v\n", chord, err)
+			failedChordTests++
+			continue
+		}
+
+		// Check if the chord was found
+		if resp.StatusCode == http.StatusOK {
+			// Verify the JSON structure
+			var chordData TestChordResponse
+			if err := json.Unmarshal(body, &chordData); err != nil {
+				fmt.Printf("ERROR: Invalid JSON response for %s: %v\n", chord, err)
+				fmt.Printf("Response: %s\n", string(body))
+				failedChordTests++
+				continue
+			}
+
+			// Verify required fields
+			if chordData.Key == "" || len(chordData.Positions) == 0 {
+				fmt.Printf("ERROR: Missing required fields in response for %s\n", chord)
+				fmt.Printf("Response: %s\n", string(body))
+				failedChordTests++
+				continue
 			}
+
+			fmt.Printf("SUCCESS: Chord %s was found with valid structure!\n", chord)
+			passedChordTests++
 		} else {
-			fmt.Printf("FAILURE: No chords found with fingering pattern %s. Status: %d\n", finger, resp.StatusCode)
+			fmt.Printf("FAILURE: Chord %s was not found. Status: %d\n", chord, resp.StatusCode)
 			fmt.Printf("Response: %s\n", string(body))
-			failedFingerTests++
+			failedChordTests++
+		}
+
+		fmt.Println()
+	}
+
+	// Track test results for fingers
+	totalFingerTests := len(testFingers)
+	passedFingerTests := 0
+	failedFingerTests := 0
+
+	// Test each fingering pattern
+	fmt.Printf("\n=== TESTING FINGERS ENDPOINT ===\n\n")
+	for _, finger := range testFingers {
+		fmt.Printf("Testing fingering pattern: %s\n", finger)
+
+		// Make a request to the server using the test port
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/fingers/%s", testPort, finger))
+		if err != nil {
+			fmt.Printf("ERROR: Failed to make request for fingering %s: %v\n", finger, err)
+			failedFingerTests++
+			continue
+		}
+
+		// Read the response
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Printf("ERROR: Failed to read response for fingering %s: %v\n", finger, err)
+			failedFingerTests++
+			continue
+		}
+
+		// Check if chords with this fingering were found
+		if resp.StatusCode == http.StatusOK {
+			// Verify the JSON structure - should be an array of chord objects
+			var chordsData []json.RawMessage
+			if err := json.Unmarshal(body, &chordsData); err != nil {
+				fmt.Printf("ERROR: Invalid JSON response for fingering %s: %v\n", finger, err)
+				fmt.Printf("Response: %s\n", string(body))
+				failedFingerTests++
+				continue
+			}
+
+			// Verify we got at least one chord
+			if len(chordsData) == 0 {
+				fmt.Printf("ERROR: Empty array returned for fingering %s\n", finger)
+				fmt.Printf("Response: %s\n", string(body))
+				failedFingerTests++
+				continue
+			}
+
+			// Verify each chord has a valid structure
+			validChords := true
+			for i, chordJSON := range chordsData {
+				var chordData TestChordResponse
+				if err := json.Unmarshal(chordJSON, &chordData); err != nil {
+					fmt.Printf("ERROR: Invalid chord JSON at index %d for fingering %s: %v\n", i, finger, err)
+					fmt.Printf("Chord JSON: %s\n", string(chordJSON))
+					validChords = false
+					break
+				}
+
+				// Verify required fields
+				if chordData.Key == "" || len(chordData.Positions) == 0 {
+					fmt.Printf("ERROR: Missing required fields in chord at index %d for fingering %s\n", i, finger)
+					fmt.Printf("Chord JSON: %s\n", string(chordJSON))
+					validChords = false
+					break
+				}
+
+				// Verify this chord actually has the fingering pattern we requested
+				hasMatchingFingering := false
+				for _, pos := range chordData.Positions {
+					if pos.Frets == finger {
+						hasMatchingFingering = true
+						break
+					}
+				}
+
+				if !hasMatchingFingering {
+					fmt.Printf("ERROR: Chord at index %d does not have the requested fingering pattern %s\n", i, finger)
+					fmt.Printf("Chord JSON: %s\n", string(chordJSON))
+					validChords = false
+					break
+				}
+			}
+
+			if validChords {
+				fmt.Printf("SUCCESS: Found %d chord(s) with fingering pattern %s!\n", len(chordsData), finger)
+				passedFingerTests++
+			} else {
+				failedFingerTests++
+			}
+		} else {
+			fmt.Printf("FAILURE: No chords found with fingering pattern %s. Status: %d\n", finger, resp.StatusCode)
+			fmt.Printf("Response: %s\n", string(body))
+			failedFingerTests++
+		}
+
+		fmt.Println()
+	}
+
+	// Track test results for search
+	totalSearchTests := len(testSearches)
+	passedSearchTests := 0
+	failedSearchTests := 0
+
+	// Test each search query
+	fmt.Printf("\n=== TESTING SEARCH ENDPOINT ===\n\n")
+	for _, tc := range testSearches {
+		fmt.Printf("Testing %s with query '%s':\n", tc.name, tc.query)
+
+		// Make request to search endpoint
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/search/%s", testPort, tc.query))
+		if err != nil {
+			fmt.Printf("ERROR: Failed to make request for search %s: %v\n", tc.query, err)
+			failedSearchTests++
+			continue
+		}
+
+		// Read response
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			fmt.Printf("ERROR: Failed to read response for search %s: %v\n", tc.query, err)
+			failedSearchTests++
+			continue
+		}
+
+		// Check status code
+		if tc.expectNotFound {
+			// For these patterns, we expect a 404 Not Found response
+			if resp.StatusCode == http.StatusNotFound {
+				fmt.Printf("SUCCESS: Search for '%s' correctly returned Not Found as expected\n", tc.query)
+				passedSearchTests++
+				continue
+			} else {
+				fmt.Printf("FAILURE: Search for '%s' should have returned Not Found but got Status: %d\n", tc.query, resp.StatusCode)
+				fmt.Printf("Response: %s\n", string(body))
+				failedSearchTests++
+				continue
+			}
+		} else if resp.StatusCode != http.StatusOK {
+			fmt.Printf("FAILURE: Search for '%s' failed. Status: %d\n", tc.query, resp.StatusCode)
+			fmt.Printf("Response: %s\n", string(body))
+			failedSearchTests++
+			continue
+		}
+
+		// Parse JSON response
+		var results []json.RawMessage
+		if err := json.Unmarshal(body, &results); err != nil {
+			fmt.Printf("ERROR: Invalid JSON response for search %s: %v\n", tc.query, err)
+			fmt.Printf("Response: %s\n", string(body))
+			failedSearchTests++
+			continue
+		}
+
+		// Verify we got at least one result
+		if len(results) == 0 {
+			fmt.Printf("ERROR: No results returned for search %s\n", tc.query)
+			fmt.Printf("Response: %s\n", string(body))
+			failedSearchTests++
+			continue
+		}
+
+		// Verify each result has a valid structure
+		validResults := true
+		for i, resultJSON := range results {
+			var resultData map[string]interface{}
+			if err := json.Unmarshal(resultJSON, &resultData); err != nil {
+				fmt.Printf("ERROR: Invalid result JSON at index %d for search %s: %v\n", i, tc.query, err)
+				fmt.Printf("Result JSON: %s\n", string(resultJSON))
+				validResults = false
+				break
+			}
+
+			// Verify required fields (key and positions)
+			if resultData["key"] == nil || resultData["positions"] == nil {
+				fmt.Printf("ERROR: Missing required fields in result at index %d for search %s\n", i, tc.query)
+				fmt.Printf("Result JSON: %s\n", string(resultJSON))
+				validResults = false
+				break
+			}
+		}
+
+		if validResults {
+			fmt.Printf("SUCCESS: Found %d result(s) for search '%s'!\n", len(results), tc.query)
+			// Print first result
+			var firstResult map[string]interface{}
+			if err := json.Unmarshal(results[0], &firstResult); err == nil {
+				fmt.Printf("  First result: %s %s\n",
+					firstResult["key"],
+					firstResult["suffix"])
+			}
+			passedSearchTests++
+		} else {
+			failedSearchTests++
+		}
+
+		fmt.Println()
+	}
+
+	// Track test results for fixtures
+	totalFixtureTests := len(fixtureTests)
+	passedFixtureTests := 0
+	failedFixtureTests := 0
+
+	// Build the fixture database
+	fixtureDB := filepath.Join(tmpDir, "fixtures.db")
+	if err := buildFixtureDB(fixtureDB, fixtureChords); err != nil {
+		fmt.Printf("ERROR: Failed to build fixture database: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Run each fixture test against a fresh server started with its flags
+	fmt.Printf("\n=== TESTING FIXTURES ===\n\n")
+	for _, tc := range fixtureTests {
+		fmt.Printf("Testing %s with path '%s':\n", tc.name, tc.path)
+
+		if err := runFixtureTest(serverBin, fixturePort, fixtureDB, tc); err != nil {
+			fmt.Printf("FAILURE: %v\n", err)
+			failedFixtureTests++
+		} else {
+			fmt.Printf("SUCCESS: %s\n", tc.name)
+			passedFixtureTests++
+		}
+
+		fmt.Println()
+	}
+
+	// The watch test replaces the database under a running server
+	totalFixtureTests++
+	fmt.Printf("Testing Watch - reload replaced database:\n")
+	if err := testWatchReload(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Watch - reload replaced database\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The slow client test replaces the database while a request is stuck sending its body
+	totalFixtureTests++
+	fmt.Printf("Testing Watch - reload with a slow client:\n")
+	if err := testSlowClientReload(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Watch - reload with a slow client\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The key spellings test starts servers that must refuse their -key-spellings
+	totalFixtureTests++
+	fmt.Printf("Testing Key spellings - notes without a pitch class:\n")
+	if err := testInvalidKeySpellings(serverBin, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Key spellings - notes without a pitch class\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The failed reload test replaces the database with a corrupt file under a running server
+	totalFixtureTests++
+	fmt.Printf("Testing Watch - failed reload keeps serving:\n")
+	if err := testFailedReload(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Watch - failed reload keeps serving\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The config test starts servers with a config file and environment overrides
+	totalFixtureTests++
+	fmt.Printf("Testing Config - file, flag and environment precedence:\n")
+	if err := testConfigFile(serverBin, fixturePort, tmpDir, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Config - file, flag and environment precedence\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The snapshot test exports the fixture store and serves it from a second server without the database
+	totalFixtureTests++
+	fmt.Printf("Testing Snapshot - export and import round trip:\n")
+	if err := testSnapshot(serverBin, fixturePort, tmpDir, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Snapshot - export and import round trip\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The consistency test changes the database under a running server
+	totalFixtureTests++
+	fmt.Printf("Testing Consistency - database changed under the cache:\n")
+	if err := testVerifyConsistency(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Consistency - database changed under the cache\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The array import test builds a database with build_db -input-file and serves it
+	totalFixtureTests++
+	fmt.Printf("Testing Build - import from a JSON array:\n")
+	if err := testBuildFromArray(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Build - import from a JSON array\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The incremental build test updates a database after editing, adding and deleting source files
+	totalFixtureTests++
+	fmt.Printf("Testing Build - incremental update:\n")
+	if err := testIncrementalBuild(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Build - incremental update\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The concurrent build test compares serial and parallel builds of the same source tree
+	totalFixtureTests++
+	fmt.Printf("Testing Build - chord IDs don't depend on concurrency:\n")
+	if err := testConcurrentBuild(tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Build - chord IDs don't depend on concurrency\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The tuning order test builds a database whose drop-D chords are imported before the standard ones
+	totalFixtureTests++
+	fmt.Printf("Testing Tunings - names resolve to standard tuning:\n")
+	if err := testTuningOrder(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Tunings - names resolve to standard tuning\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The MIDI range test serves a chord fretted high enough to leave the MIDI range on a display tuning
+	totalFixtureTests++
+	fmt.Printf("Testing Formats - MIDI notes out of range:\n")
+	if err := testMIDIRange(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Formats - MIDI notes out of range\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The daily chord test compares the chords picked for several days
+	totalFixtureTests++
+	fmt.Printf("Testing Daily - stable within a day, varies across days:\n")
+	if err := testDailyChord(serverBin, fixturePort, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Daily - stable within a day, varies across days\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The enharmonic test serves a database holding both spellings of a chord
+	totalFixtureTests++
+	fmt.Printf("Testing Enharmonic - both spellings stored:\n")
+	if err := testEnharmonic(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Enharmonic - both spellings stored\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The instruments test serves a database with chords for two instruments
+	totalFixtureTests++
+	fmt.Printf("Testing Instruments - voicings grouped by instrument:\n")
+	if err := testInstruments(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Instruments - voicings grouped by instrument\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The compact format test decodes compact responses and compares them to the JSON ones
+	totalFixtureTests++
+	fmt.Printf("Testing Compact - round trip to the full structure:\n")
+	if err := testCompactFormat(serverBin, fixturePort, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Compact - round trip to the full structure\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The search limit test serves more name matches than the default limit, plus a fingering match
+	totalFixtureTests++
+	fmt.Printf("Testing Result limits - search both names and fingerings:\n")
+	if err := testSearchLimit(serverBin, fixturePort, tmpDir); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Result limits - search both names and fingerings\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The count test compares count_only responses with the full results of the same queries
+	totalFixtureTests++
+	fmt.Printf("Testing Count - count_only matches the results:\n")
+	if err := testCountOnly(serverBin, fixturePort, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Count - count_only matches the results\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// The log level test reads the logs a server writes to a file
+	totalFixtureTests++
+	fmt.Printf("Testing Logging - level filtering:\n")
+	if err := testLogLevel(serverBin, fixturePort, tmpDir, fixtureDB); err != nil {
+		fmt.Printf("FAILURE: %v\n", err)
+		failedFixtureTests++
+	} else {
+		fmt.Printf("SUCCESS: Logging - level filtering\n")
+		passedFixtureTests++
+	}
+	fmt.Println()
+
+	// Print test summary
+	fmt.Printf("=== TEST SUMMARY ===\n")
+	fmt.Printf("Chord tests: %d total, %d passed, %d failed\n", totalChordTests, passedChordTests, failedChordTests)
+	fmt.Printf("Finger tests: %d total, %d passed, %d failed\n", totalFingerTests, passedFingerTests, failedFingerTests)
+	fmt.Printf("Search tests: %d total, %d passed, %d failed\n", totalSearchTests, passedSearchTests, failedSearchTests)
+	fmt.Printf("Fixture tests: %d total, %d passed, %d failed\n", totalFixtureTests, passedFixtureTests, failedFixtureTests)
+
+	totalTests := totalChordTests + totalFingerTests + totalSearchTests + totalFixtureTests
+	passedTests := passedChordTests + passedFingerTests + passedSearchTests + passedFixtureTests
+	failedTests := failedChordTests + failedFingerTests + failedSearchTests + failedFixtureTests
+
+	fmt.Printf("Overall: %d total, %d passed, %d failed\n", totalTests, passedTests, failedTests)
+
+	// Exit with appropriate code
+	if failedTests > 0 {
+		stopServer(cmd)
+		os.Exit(1)
+	}
+}
+
+// startServer starts the server binary on the given port and waits until it responds
+func startServer(serverBin string, port int, flags ...string) (*exec.Cmd, error) {
+	args := append([]string{"-port", fmt.Sprintf("%d", port)}, flags...)
+	cmd := exec.Command(serverBin, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start server: %v", err)
+	}
+
+	fmt.Printf("Starting server on port %d...\n", port)
+
+	// Wait for the server to start and verify it's running
+	if !waitForServer(port) {
+		stopServer(cmd)
+		return nil, fmt.Errorf("server failed to start or is not responding on port %d", port)
+	}
+
+	return cmd, nil
+}
+
+// stopServer kills the server process and waits for it to release its port
+func stopServer(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+	cmd.Wait()
+}
+
+// buildFixtureDB creates a database with the same schema as build_db.go containing the given chords
+func buildFixtureDB(path string, chords []string) error {
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`
+		CREATE TABLE chords (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			key TEXT NOT NULL,
+			suffix TEXT NOT NULL,
+			tuning TEXT NOT NULL DEFAULT 'standard',
+			full_data TEXT NOT NULL,
+			UNIQUE(key, suffix, tuning)
+		);
+		CREATE TABLE fingerings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chord_id INTEGER NOT NULL,
+			frets TEXT NOT NULL,
+			fingers TEXT,
+			barres TEXT,
+			capo TEXT,
+			FOREIGN KEY(chord_id) REFERENCES chords(id)
+		);
+		CREATE TABLE chord_aliases (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			chord_id INTEGER NOT NULL,
+			alias_key TEXT NOT NULL,
+			alias_suffix TEXT NOT NULL,
+			alias_tuning TEXT NOT NULL DEFAULT 'standard',
+			UNIQUE(alias_key, alias_suffix, alias_tuning),
+			FOREIGN KEY(chord_id) REFERENCES chords(id)
+		);
+		CREATE TABLE schema_version (
+			version INTEGER NOT NULL
+		);
+		INSERT INTO schema_version (version) VALUES (3);
+	`)
+	if err != nil {
+		return err
+	}
+
+	for _, fixture := range chords {
+		var chord TestChordResponse
+		if err := json.Unmarshal([]byte(fixture), &chord); err != nil {
+			return fmt.Errorf("invalid fixture %s: %v", fixture, err)
+		}
+
+		tuning := chord.Tuning
+		if tuning == "" {
+			tuning = "standard"
+		}
+
+		res, err := db.Exec(`INSERT INTO chords (key, suffix, tuning, full_data) VALUES (?, ?, ?, ?)`, chord.Key, chord.Suffix, tuning, fixture)
+		if err != nil {
+			return err
+		}
+
+		chordID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		for _, pos := range chord.Positions {
+			_, err := db.Exec(`INSERT INTO fingerings (chord_id, frets, fingers, barres, capo) VALUES (?, ?, ?, ?, ?)`,
+				chordID, pos.Frets, pos.Fingers, pos.Barres, pos.Capo)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, alias := range fixtureAliases {
+		_, err := db.Exec(`
+			INSERT INTO chord_aliases (chord_id, alias_key, alias_suffix)
+			SELECT id, ?, ? FROM chords WHERE key = ? AND suffix = ? AND tuning = 'standard'
+		`, alias[2], alias[3], alias[0], alias[1])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// testWatchReload checks that a server started with -watch picks up a database replaced on disk
+func testWatchReload(serverBin string, port int, tmpDir string) error {
+	watchedDB := filepath.Join(tmpDir, "watched.db")
+	if err := buildFixtureDB(watchedDB, fixtureChords); err != nil {
+		return fmt.Errorf("failed to build database: %v", err)
+	}
+
+	cmd, err := startServer(serverBin, port, "-db", watchedDB, "-watch", "200ms")
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+
+	url := fmt.Sprintf("http://localhost:%d/chords/Bm", port)
+	if status, err := getStatus(url); err != nil || status != http.StatusNotFound {
+		return fmt.Errorf("expected 404 before reload, got %d (%v)", status, err)
+	}
+
+	// Build the replacement next to the watched file and move it into place
+	replacementDB := filepath.Join(tmpDir, "replacement.db")
+	minor := `{"key":"B","suffix":"minor","positions":[{"frets":"x24432","fingers":"013421","barres":"2"}]}`
+	if err := buildFixtureDB(replacementDB, append(append([]string{}, fixtureChords...), minor)); err != nil {
+		return fmt.Errorf("failed to build replacement database: %v", err)
+	}
+	if err := os.Rename(replacementDB, watchedDB); err != nil {
+		return fmt.Errorf("failed to replace database: %v", err)
+	}
+
+	for i := 0; i < 25; i++ {
+		time.Sleep(200 * time.Millisecond)
+		if status, err := getStatus(url); err == nil && status == http.StatusOK {
+			return nil
+		}
+	}
+	return fmt.Errorf("new chord not served after replacing the database")
+}
+
+// testSlowClientReload checks that a client stalled halfway through sending a request doesn't hold up a reload,
+// or the requests that arrive after it
+func testSlowClientReload(serverBin string, port int, tmpDir string) error {
+	watchedDB := filepath.Join(tmpDir, "slow.db")
+	if err := buildFixtureDB(watchedDB, fixtureChords); err != nil {
+		return fmt.Errorf("failed to build database: %v", err)
+	}
+
+	cmd, err := startServer(serverBin, port, "-db", watchedDB, "-watch", "200ms")
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+
+	// Send the headers and the start of a body, then stall until the test is over
+	conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return fmt.Errorf("failed to connect: %v", err)
+	}
+	defer conn.Close()
+	partial := "POST /transpose/batch HTTP/1.1\r\nHost: localhost\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"chords\": ["
+	if _, err := conn.Write([]byte(partial)); err != nil {
+		return fmt.Errorf("failed to send partial request: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	replacementDB := filepath.Join(tmpDir, "slow-replacement.db")
+	minor := `{"key":"B","suffix":"minor","positions":[{"frets":"x24432","fingers":"013421","barres":"2"}]}`
+	if err := buildFixtureDB(replacementDB, append(append([]string{}, fixtureChords...), minor)); err != nil {
+		return fmt.Errorf("failed to build replacement database: %v", err)
+	}
+	if err := os.Rename(replacementDB, watchedDB); err != nil {
+		return fmt.Errorf("failed to replace database: %v", err)
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	url := fmt.Sprintf("http://localhost:%d/chords/Bm", port)
+	for i := 0; i < 25; i++ {
+		time.Sleep(200 * time.Millisecond)
+		resp, err := client.Get(url)
+		if err != nil {
+			return fmt.Errorf("request stalled behind the slow client: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
+		}
+	}
+	return fmt.Errorf("new chord not served after replacing the database")
+}
+
+// testFailedReload checks that a database that fails to reload leaves the old data served, with the error on /health
+func testFailedReload(serverBin string, port int, tmpDir string) error {
+	watchedDB := filepath.Join(tmpDir, "failing.db")
+	if err := buildFixtureDB(watchedDB, fixtureChords); err != nil {
+		return fmt.Errorf("failed to build database: %v", err)
+	}
+
+	cmd, err := startServer(serverBin, port, "-db", watchedDB, "-watch", "200ms")
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+
+	// Move a corrupt file into place, leaving the open database file to the server
+	corruptDB := filepath.Join(tmpDir, "corrupt.db")
+	if err := os.WriteFile(corruptDB, []byte("not a database"), 0644); err != nil {
+		return fmt.Errorf("failed to write corrupt database: %v", err)
+	}
+	if err := os.Rename(corruptDB, watchedDB); err != nil {
+		return fmt.Errorf("failed to replace database: %v", err)
+	}
+
+	var status struct {
+		Chords          int    `json:"chords"`
+		LastReload      string `json:"last_reload"`
+		LastReloadError string `json:"last_reload_error"`
+	}
+	for i := 0; i < 25 && status.LastReloadError == ""; i++ {
+		time.Sleep(200 * time.Millisecond)
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/health", port))
+		if err != nil {
+			return fmt.Errorf("failed to get health: %v", err)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&status)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("invalid health response: %v", err)
+		}
+	}
+	if status.LastReloadError == "" || status.LastReload != "" || status.Chords == 0 {
+		return fmt.Errorf("expected a reload error, no successful reload and the old chords, got %+v", status)
+	}
+
+	for _, path := range []string{"/chords/Am", "/search/Am", "/fingers/x02210"} {
+		code, err := getStatus(fmt.Sprintf("http://localhost:%d%s", port, path))
+		if err != nil || code != http.StatusOK {
+			return fmt.Errorf("expected %s to be served from the old data, got status %d (%v)", path, code, err)
+		}
+	}
+	return nil
+}
+
+// testVerifyConsistency changes and deletes chord rows under a running server without reloading it,
+// and checks that /admin/verify-consistency reports both chords
+func testVerifyConsistency(serverBin string, port int, tmpDir string) error {
+	changedDB := filepath.Join(tmpDir, "changed.db")
+	if err := buildFixtureDB(changedDB, fixtureChords); err != nil {
+		return fmt.Errorf("failed to build database: %v", err)
+	}
+
+	cmd, err := startServer(serverBin, port, "-db", changedDB, "-admin-token", "secret")
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+
+	conn, err := sql.Open("sqlite3", changedDB)
+	if err != nil {
+		return err
+	}
+	_, err = conn.Exec(`UPDATE chords SET full_data = '{"key":"C","suffix":"major","positions":[]}' WHERE key = 'C' AND suffix = 'major'`)
+	if err == nil {
+		_, err = conn.Exec(`DELETE FROM chords WHERE key = 'B' AND suffix = '7'`)
+	}
+	conn.Close()
+	if err != nil {
+		return fmt.Errorf("failed to change database: %v", err)
+	}
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/admin/verify-consistency", port), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("expected status 200, got %d (%v): %s", resp.StatusCode, err, body)
+	}
+	return expectMismatches(len(fixtureChords)-1, "B|7|standard", "C|major|standard")(body)
+}
+
+// testConfigFile checks that config file values apply, that flags and environment variables override
+// them, and that unknown keys stop the server from starting
+func testConfigFile(serverBin string, port int, tmpDir, fixtureDB string) error {
+	// The -port flag from startServer overrides the file's port
+	configPath := filepath.Join(tmpDir, "config.json")
+	config := fmt.Sprintf(`{"db": %q, "port": 1, "error-format": "json"}`, fixtureDB)
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+
+	contentType := func() (string, error) {
+		cmd, err := startServer(serverBin, port, "-config", configPath)
+		if err != nil {
+			return "", err
+		}
+		defer stopServer(cmd)
+
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chords/Nope", port))
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Content-Type"), nil
+	}
+
+	if got, err := contentType(); err != nil || got != "application/json" {
+		return fmt.Errorf("expected JSON errors from the config file, got %q (%v)", got, err)
+	}
+
+	os.Setenv("CHORDSERVER_ERROR_FORMAT", "text")
+	got, err := contentType()
+	os.Unsetenv("CHORDSERVER_ERROR_FORMAT")
+	if err != nil || !strings.HasPrefix(got, "text/plain") {
+		return fmt.Errorf("expected text errors from the environment, got %q (%v)", got, err)
+	}
+
+	// Unknown keys fail fast
+	badPath := filepath.Join(tmpDir, "bad-config.json")
+	if err := os.WriteFile(badPath, []byte(`{"prot": 8080}`), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %v", err)
+	}
+	output, err := exec.Command(serverBin, "-config", badPath).CombinedOutput()
+	if err == nil || !strings.Contains(string(output), `unknown key \"prot\"`) {
+		return fmt.Errorf("expected unknown key error, got %v: %s", err, output)
+	}
+	return nil
+}
+
+// testInvalidKeySpellings checks that -key-spellings rejects notes without a pitch class instead of crashing
+func testInvalidKeySpellings(serverBin, fixtureDB string) error {
+	for _, value := range []string{"major:Cb", "minor:Fb", "major:H"} {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		output, err := exec.CommandContext(ctx, serverBin, "-db", fixtureDB, "-key-spellings", value).CombinedOutput()
+		cancel()
+		if err == nil || !strings.Contains(string(output), "Invalid -key-spellings") || strings.Contains(string(output), "panic") {
+			return fmt.Errorf("%s: expected an invalid note error, got %v: %s", value, err, output)
+		}
+	}
+	return nil
+}
+
+// testSnapshot checks that a snapshot exported from /admin/snapshot serves the same chords when loaded
+// with -snapshot, and that a snapshot with the wrong version is rejected
+func testSnapshot(serverBin string, port int, tmpDir, fixtureDB string) error {
+	cmd, err := startServer(serverBin, port, "-db", fixtureDB, "-admin-token", "secret")
+	if err != nil {
+		return err
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("http://localhost:%d/admin/snapshot", port), nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		stopServer(cmd)
+		return fmt.Errorf("failed to export snapshot: %v", err)
+	}
+	snapshot, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	stopServer(cmd)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to export snapshot: status %d (%v)", resp.StatusCode, err)
+	}
+
+	snapshotPath := filepath.Join(tmpDir, "chords.snapshot.json")
+	if err := os.WriteFile(snapshotPath, snapshot, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+
+	cmd, err = startServer(serverBin, port, "-snapshot", snapshotPath)
+	if err != nil {
+		return err
+	}
+	for _, path := range []string{"/chords/Am", "/chords/H7", "/search/Am", "/fingers/x02210"} {
+		status, err := getStatus(fmt.Sprintf("http://localhost:%d%s", port, path))
+		if err != nil || status != http.StatusOK {
+			stopServer(cmd)
+			return fmt.Errorf("expected %s to be served from the snapshot, got status %d (%v)", path, status, err)
+		}
+	}
+	stopServer(cmd)
+
+	badPath := filepath.Join(tmpDir, "bad.snapshot.json")
+	bad := bytes.Replace(snapshot, []byte(`"version":1`), []byte(`"version":99`), 1)
+	if err := os.WriteFile(badPath, bad, 0644); err != nil {
+		return fmt.Errorf("failed to write snapshot: %v", err)
+	}
+	output, err := exec.Command(serverBin, "-snapshot", badPath).CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "snapshot version is 99") {
+		return fmt.Errorf("expected snapshot version error, got %v: %s", err, output)
+	}
+	return nil
+}
+
+// testEnharmonic checks ?include_enharmonic=true against a database with A# major stored as both A# and Bb
+func testEnharmonic(serverBin string, port int, tmpDir string) error {
+	dbPath := filepath.Join(tmpDir, "enharmonic.db")
+	err := buildFixtureDB(dbPath, []string{
+		`{"key":"A#","suffix":"major","positions":[{"frets":"x13331","fingers":"012341","barres":"1"}]}`,
+		`{"key":"Bb","suffix":"major","positions":[{"frets":"688766","fingers":"134211","barres":"6"}]}`,
+		`{"key":"C","suffix":"major","positions":[{"frets":"x32010","fingers":"032010"}]}`,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build enharmonic database: %v", err)
+	}
+
+	for _, tc := range []fixtureTest{
+		{path: "/chords/A%23?include_enharmonic=true", wantStatus: http.StatusOK, check: expectEnharmonic("A#", "Bb")},
+		{path: "/chords/Bb?include_enharmonic=true", wantStatus: http.StatusOK, check: expectEnharmonic("A#", "Bb")},
+		{path: "/chords?key=Bb&include_enharmonic=true", wantStatus: http.StatusOK, check: expectEnharmonic("Bb", "A#")},
+		{path: "/chords/C?include_enharmonic=true", wantStatus: http.StatusOK, check: expectEnharmonic("C", "")},
+		{path: "/chords/A%23", wantStatus: http.StatusOK, check: expectEnharmonic("A#", "")},
+	} {
+		if err := runFixtureTest(serverBin, port, dbPath, tc); err != nil {
+			return fmt.Errorf("%s: %v", tc.path, err)
+		}
+	}
+	return nil
+}
+
+// expectEnharmonic checks the key of a chord and of its enharmonic field, which must be absent if enharmonic is empty
+func expectEnharmonic(key, enharmonic string) func(body []byte) error {
+	return func(body []byte) error {
+		var chord struct {
+			Key        string             `json:"key"`
+			Enharmonic *TestChordResponse `json:"enharmonic"`
+		}
+		if err := json.Unmarshal(body, &chord); err != nil {
+			return err
+		}
+		if chord.Key != key {
+			return fmt.Errorf("expected key %s, got %s", key, chord.Key)
+		}
+		switch {
+		case enharmonic == "" && chord.Enharmonic != nil:
+			return fmt.Errorf("expected no enharmonic chord, got %s", chord.Enharmonic.Key)
+		case enharmonic != "" && (chord.Enharmonic == nil || chord.Enharmonic.Key != enharmonic || len(chord.Enharmonic.Positions) == 0):
+			return fmt.Errorf("expected enharmonic chord %s", enharmonic)
+		}
+		return nil
+	}
+}
+
+// testMIDIRange checks that a display tuning making a fret sound above the MIDI range is rejected instead of
+// writing a corrupt MIDI file
+func testMIDIRange(serverBin string, port int, tmpDir string) error {
+	dbPath := filepath.Join(tmpDir, "midi-range.db")
+	err := buildFixtureDB(dbPath, []string{
+		`{"key":"E","suffix":"major","positions":[{"frets":"x-x-x-x-x-35","fingers":"000001"}]}`,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build MIDI range database: %v", err)
+	}
+
+	for _, tc := range []fixtureTest{
+		{path: "/chords/E.mid", wantStatus: http.StatusOK, check: expectMIDINotes(99)},
+		{path: "/chords/E.mid?display_tuning=E2,A2,D3,G3,B3,B6", wantStatus: http.StatusBadRequest},
+	} {
+		if err := runFixtureTest(serverBin, port, dbPath, tc); err != nil {
+			return fmt.Errorf("%s: %v", tc.path, err)
+		}
+	}
+	return nil
+}
+
+// testInstruments checks ?instruments= against a database with C major on guitar, in two tunings, and on ukulele
+func testInstruments(serverBin string, port int, tmpDir string) error {
+	dbPath := filepath.Join(tmpDir, "instruments.db")
+	err := buildFixtureDB(dbPath, []string{
+		`{"key":"C","suffix":"major","positions":[{"frets":"x32010","fingers":"032010"}]}`,
+		`{"key":"C","suffix":"major","tuning":"drop-d","positions":[{"frets":"x32010","fingers":"032010"}]}`,
+		`{"key":"C","suffix":"major","instrument":"ukulele","tuning":"ukulele-standard","positions":[{"frets":"0003","fingers":"0003"}]}`,
+		`{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"002310"}]}`,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build instruments database: %v", err)
+	}
+
+	for _, tc := range []fixtureTest{
+		{path: "/chords/C?instruments=guitar,ukulele", wantStatus: http.StatusOK, check: expectInstruments("guitar:2", "ukulele:1")},
+		{path: "/chords/C?instruments=ukulele", wantStatus: http.StatusOK, check: expectInstruments("ukulele:1")},
+		{path: "/chords/C?instruments=", wantStatus: http.StatusOK, check: expectInstruments("guitar:2", "ukulele:1")},
+		{path: "/chords/Am?instruments=all", wantStatus: http.StatusOK, check: expectInstruments("guitar:1")},
+		{path: "/chords/Am?instruments=guitar,ukulele", wantStatus: http.StatusOK, check: expectInstruments("guitar:1", "ukulele:0")},
+		{path: "/chords/C?instruments=ukulele&format=svg", wantStatus: http.StatusNotAcceptable},
+	} {
+		if err := runFixtureTest(serverBin, port, dbPath, tc); err != nil {
+			return fmt.Errorf("%s: %v", tc.path, err)
+		}
+	}
+	return nil
+}
+
+// expectInstruments checks the instruments of a grouped chord response, each written as instrument:count with the
+// number of voicings expected for it, in alphabetical order
+func expectInstruments(groups ...string) func(body []byte) error {
+	return func(body []byte) error {
+		var grouped map[string][]TestChordResponse
+		if err := json.Unmarshal(body, &grouped); err != nil {
+			return err
+		}
+
+		var got []string
+		for instrument, voicings := range grouped {
+			got = append(got, fmt.Sprintf("%s:%d", instrument, len(voicings)))
+		}
+		sort.Strings(got)
+		if fmt.Sprint(got) != fmt.Sprint(groups) {
+			return fmt.Errorf("expected instruments %v, got %v", groups, got)
+		}
+		return nil
+	}
+}
+
+// testCompactFormat checks that ?format=compact decodes back to the key, suffix, tuning and frets of the JSON response
+func testCompactFormat(serverBin string, port int, fixtureDB string) error {
+	cmd, err := startServer(serverBin, port, "-db", fixtureDB)
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+
+	get := func(path string) ([]byte, error) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("expected status 200 for %s, got %d: %s", path, resp.StatusCode, body)
+		}
+		return body, nil
+	}
+
+	for _, name := range []string{"C", "Am", "A7", "G/B", "Gmmaj7", "F%23"} {
+		full, err := get("/chords/" + name)
+		if err != nil {
+			return err
+		}
+		compact, err := get("/chords/" + name + "?format=compact")
+		if err != nil {
+			return err
+		}
+
+		var want TestChordResponse
+		if err := json.Unmarshal(full, &want); err != nil {
+			return err
+		}
+		got, err := decodeCompactChord(compact)
+		if err != nil {
+			return fmt.Errorf("%s: %v: %s", name, err, compact)
+		}
+		if got.Key != want.Key || got.Suffix != want.Suffix || got.Tuning != want.Tuning || len(got.Positions) != len(want.Positions) {
+			return fmt.Errorf("%s: compact %s decoded to %+v, expected %+v", name, compact, got, want)
+		}
+		for i := range got.Positions {
+			if got.Positions[i].Frets != want.Positions[i].Frets {
+				return fmt.Errorf("%s: position %d has frets %s, expected %s", name, i+1, got.Positions[i].Frets, want.Positions[i].Frets)
+			}
+		}
+	}
+	return nil
+}
+
+// decodeCompactChord decodes the compact chord format, [key, suffix, tuning, [frets, ...]], into the full structure
+// without fingers, barres or capos
+func decodeCompactChord(data []byte) (TestChordResponse, error) {
+	var compact []json.RawMessage
+	if err := json.Unmarshal(data, &compact); err != nil {
+		return TestChordResponse{}, err
+	}
+	if len(compact) != 4 {
+		return TestChordResponse{}, fmt.Errorf("expected 4 elements, got %d", len(compact))
+	}
+
+	var chord TestChordResponse
+	var frets []string
+	for i, field := range []interface{}{&chord.Key, &chord.Suffix, &chord.Tuning, &frets} {
+		if err := json.Unmarshal(compact[i], field); err != nil {
+			return TestChordResponse{}, err
+		}
+	}
+	switch chord.Suffix {
+	case "":
+		chord.Suffix = "major"
+	case "m":
+		chord.Suffix = "minor"
+	}
+	if chord.Tuning == "" {
+		chord.Tuning = "standard"
+	}
+	for _, position := range frets {
+		chord.Positions = append(chord.Positions, TestChordPosition{Frets: position})
+	}
+	return chord, nil
+}
+
+// testSearchLimit checks that -search-limit, not a fixed five, caps a search matching both chord names and fingerings:
+// "ab" names the six G# chords and prefixes the fingering of E7/D
+func testSearchLimit(serverBin string, port int, tmpDir string) error {
+	dbPath := filepath.Join(tmpDir, "search-limit.db")
+	err := buildFixtureDB(dbPath, []string{
+		`{"key":"G#","suffix":"minor","positions":[{"frets":"466444","fingers":"134111","barres":"4"}]}`,
+		`{"key":"G#","suffix":"7","positions":[{"frets":"464544","fingers":"131211","barres":"4"}]}`,
+		`{"key":"G#","suffix":"m7","positions":[{"frets":"464444","fingers":"131111","barres":"4"}]}`,
+		`{"key":"G#","suffix":"sus4","positions":[{"frets":"466644","fingers":"133411","barres":"4"}]}`,
+		`{"key":"G#","suffix":"6","positions":[{"frets":"x31111","fingers":"031111","barres":"1"}]}`,
+		`{"key":"G#","suffix":"9","positions":[{"frets":"4x4344","fingers":"202134"}]}`,
+		`{"key":"E","suffix":"7/D","positions":[{"frets":"ab999x","fingers":"231110"}]}`,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build search limit database: %v", err)
+	}
+
+	for _, tc := range []fixtureTest{
+		{path: "/search/ab", wantStatus: http.StatusOK, check: expectSuffixes("minor", "7", "m7", "sus4", "6")},
+		{path: "/search/ab", flags: []string{"-search-limit", "10"}, wantStatus: http.StatusOK, check: expectSuffixes("minor", "7", "m7", "sus4", "6", "9", "7/D")},
+		{path: "/search/ab", flags: []string{"-search-limit", "6"}, wantStatus: http.StatusOK, check: expectSuffixes("minor", "7", "m7", "sus4", "6", "9")},
+	} {
+		if err := runFixtureTest(serverBin, port, dbPath, tc); err != nil {
+			return fmt.Errorf("%s %v: %v", tc.path, tc.flags, err)
+		}
+	}
+	return nil
+}
+
+// testCountOnly checks that ?count_only=true on /search/ and /fingers/ counts the chords the same query returns
+func testCountOnly(serverBin string, port int, fixtureDB string) error {
+	cmd, err := startServer(serverBin, port, "-db", fixtureDB)
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+
+	get := func(path string, result interface{}) error {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d%s", port, path))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("expected status 200 for %s, got %d", path, resp.StatusCode)
 		}
+		return json.NewDecoder(resp.Body).Decode(result)
+	}
 
-		fmt.Println()
+	for _, query := range []string{"/search/A", "/search/F?mode=beginner", "/search/x02210", "/fingers/x32", "/fingers/022100?fingers=034200", "/search/Zzz?empty_ok=true"} {
+		var results []TestChordResponse
+		if err := get(query, &results); err != nil {
+			return err
+		}
+
+		separator := "?"
+		if strings.Contains(query, "?") {
+			separator = "&"
+		}
+		var count struct {
+			Count *int `json:"count"`
+		}
+		if err := get(query+separator+"count_only=true", &count); err != nil {
+			return err
+		}
+		if count.Count == nil || *count.Count != len(results) {
+			return fmt.Errorf("%s: expected count %d, got %v", query, len(results), count.Count)
+		}
 	}
 
-	// Track test results for search
-	totalSearchTests := len(testSearches)
-	passedSearchTests := 0
-	failedSearchTests := 0
+	// A count of nothing isn't a 404
+	status, err := getStatus(fmt.Sprintf("http://localhost:%d/fingers/999999?count_only=true", port))
+	if err != nil || status != http.StatusOK {
+		return fmt.Errorf("expected status 200 for a count of 0, got %d (%v)", status, err)
+	}
+	return nil
+}
 
-	// Test each search query
-	fmt.Printf("\n=== TESTING SEARCH ENDPOINT ===\n\n")
-	for _, tc := range testSearches {
-		fmt.Printf("Testing %s with query '%s':\n", tc.name, tc.query)
+// testDailyChord checks that /chords/daily returns the same chord for repeated requests on a day, and that the chord
+// changes from day to day
+func testDailyChord(serverBin string, port int, fixtureDB string) error {
+	cmd, err := startServer(serverBin, port, "-db", fixtureDB)
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
 
-		// Make request to search endpoint
-		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/search/%s", testPort, tc.query))
+	daily := func(query string) (string, error) {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chords/daily%s", port, query))
 		if err != nil {
-			fmt.Printf("ERROR: Failed to make request for search %s: %v\n", tc.query, err)
-			failedSearchTests++
-			continue
+			return "", err
 		}
-
-		// Read response
 		body, err := ioutil.ReadAll(resp.Body)
 		resp.Body.Close()
 		if err != nil {
-			fmt.Printf("ERROR: Failed to read response for search %s: %v\n", tc.query, err)
-			failedSearchTests++
-			continue
+			return "", err
 		}
-
-		// Check status code
-		if tc.expectNotFound {
-			// For these patterns, we expect a 404 Not Found response
-			if resp.StatusCode == http.StatusNotFound {
-				fmt.Printf("SUCCESS: Search for '%s' correctly returned Not Found as expected\n", tc.query)
-				passedSearchTests++
-				continue
-			} else {
-				fmt.Printf("FAILURE: Search for '%s' should have returned Not Found but got Status: %d\n", tc.query, resp.StatusCode)
-				fmt.Printf("Response: %s\n", string(body))
-				failedSearchTests++
-				continue
-			}
-		} else if resp.StatusCode != http.StatusOK {
-			fmt.Printf("FAILURE: Search for '%s' failed. Status: %d\n", tc.query, resp.StatusCode)
-			fmt.Printf("Response: %s\n", string(body))
-			failedSearchTests++
-			continue
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("expected status 200 for %q, got %d: %s", query, resp.StatusCode, body)
 		}
+		return string(body), nil
+	}
 
-		// Parse JSON response
-		var results []json.RawMessage
-		if err := json.Unmarshal(body, &results); err != nil {
-			fmt.Printf("ERROR: Invalid JSON response for search %s: %v\n", tc.query, err)
-			fmt.Printf("Response: %s\n", string(body))
-			failedSearchTests++
-			continue
+	distinct := make(map[string]bool)
+	for day := 1; day <= 7; day++ {
+		query := fmt.Sprintf("?date=2026-03-%02d", day)
+		first, err := daily(query)
+		if err != nil {
+			return err
 		}
-
-		// Verify we got at least one result
-		if len(results) == 0 {
-			fmt.Printf("ERROR: No results returned for search %s\n", tc.query)
-			fmt.Printf("Response: %s\n", string(body))
-			failedSearchTests++
-			continue
+		second, err := daily(query)
+		if err != nil {
+			return err
+		}
+		if first != second {
+			return fmt.Errorf("expected the same chord for %s, got %s and %s", query, first, second)
 		}
+		distinct[first] = true
+	}
+	if len(distinct) < 2 {
+		return fmt.Errorf("expected the chord to change across a week, got %d distinct chords", len(distinct))
+	}
 
-		// Verify each result has a valid structure
-		validResults := true
-		for i, resultJSON := range results {
-			var resultData map[string]interface{}
-			if err := json.Unmarshal(resultJSON, &resultData); err != nil {
-				fmt.Printf("ERROR: Invalid result JSON at index %d for search %s: %v\n", i, tc.query, err)
-				fmt.Printf("Result JSON: %s\n", string(resultJSON))
-				validResults = false
-				break
-			}
+	// Without a date the chord is today's, unless the requests straddle midnight
+	today := time.Now().UTC().Format("2006-01-02")
+	current, err := daily("")
+	if err != nil {
+		return err
+	}
+	dated, err := daily("?date=" + today)
+	if err != nil {
+		return err
+	}
+	if current != dated && time.Now().UTC().Format("2006-01-02") == today {
+		return fmt.Errorf("expected today's chord without a date, got %s and %s", current, dated)
+	}
+	return nil
+}
 
-			// Verify required fields (key and positions)
-			if resultData["key"] == nil || resultData["positions"] == nil {
-				fmt.Printf("ERROR: Missing required fields in result at index %d for search %s\n", i, tc.query)
-				fmt.Printf("Result JSON: %s\n", string(resultJSON))
-				validResults = false
-				break
-			}
-		}
+// getStatus returns the status code of a GET request
+func getStatus(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
 
-		if validResults {
-			fmt.Printf("SUCCESS: Found %d result(s) for search '%s'!\n", len(results), tc.query)
-			// Print first result
-			var firstResult map[string]interface{}
-			if err := json.Unmarshal(results[0], &firstResult); err == nil {
-				fmt.Printf("  First result: %s %s\n",
-					firstResult["key"],
-					firstResult["suffix"])
-			}
-			passedSearchTests++
-		} else {
-			failedSearchTests++
-		}
+// runFixtureTest starts a server on the fixture database and checks a single request against it
+func runFixtureTest(serverBin string, port int, fixtureDB string, tc fixtureTest) error {
+	flags := append([]string{"-db", fixtureDB}, tc.flags...)
+	cmd, err := startServer(serverBin, port, flags...)
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
 
-		fmt.Println()
+	method := tc.method
+	if method == "" {
+		method = "GET"
 	}
 
-	// Print test summary
-	fmt.Printf("=== TEST SUMMARY ===\n")
-	fmt.Printf("Chord tests: %d total, %d passed, %d failed\n", totalChordTests, passedChordTests, failedChordTests)
-	fmt.Printf("Finger tests: %d total, %d passed, %d failed\n", totalFingerTests, passedFingerTests, failedFingerTests)
-	fmt.Printf("Search tests: %d total, %d passed, %d failed\n", totalSearchTests, passedSearchTests, failedSearchTests)
+	req, err := http.NewRequest(method, fmt.Sprintf("http://localhost:%d%s", port, tc.path), strings.NewReader(tc.body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	for name, value := range tc.headers {
+		req.Header.Set(name, value)
+	}
 
-	totalTests := totalChordTests + totalFingerTests + totalSearchTests
-	passedTests := passedChordTests + passedFingerTests + passedSearchTests
-	failedTests := failedChordTests + failedFingerTests + failedSearchTests
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to make request: %v", err)
+	}
 
-	fmt.Printf("Overall: %d total, %d passed, %d failed\n", totalTests, passedTests, failedTests)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
 
-	// Exit with appropriate code
-	if failedTests > 0 {
-		os.Exit(1)
+	if resp.StatusCode != tc.wantStatus {
+		return fmt.Errorf("expected status %d, got %d. Response: %s", tc.wantStatus, resp.StatusCode, string(body))
+	}
+
+	for name, value := range tc.wantHeader {
+		if got := resp.Header.Get(name); got != value {
+			return fmt.Errorf("expected header %s: %s, got %q", name, value, got)
+		}
+	}
+
+	if tc.wantLength && resp.ContentLength != int64(len(body)) {
+		return fmt.Errorf("expected Content-Length %d, got %d (transfer encoding %v)", len(body), resp.ContentLength, resp.TransferEncoding)
+	}
+
+	if tc.check != nil {
+		if err := tc.check(body); err != nil {
+			return fmt.Errorf("%v. Response: %s", err, string(body))
+		}
 	}
+
+	return nil
 }
 
 // waitForServer attempts to connect to the server with retries
@@ -409,3 +4334,402 @@ func waitForServer(port int) bool {
 
 	return false
 }
+
+// testBuildFromArray checks that build_db -input-file imports every valid chord of a JSON array file,
+// skipping invalid elements, with the same aliases and fingering checks as a directory import
+func testBuildFromArray(serverBin string, port int, tmpDir string) error {
+	arrayPath := filepath.Join(tmpDir, "array.json")
+	array := `[
+		{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"002310"}]},
+		{"key":"B","suffix":"7","positions":[{"frets":"x21202","fingers":"021304","barres":"x"}]},
+		{"key":"C","suffix":"major","positions":[{"frets":"x32010","fingers":"032010"}]},
+		{"key":"D","suffix":"major","positions":[{"frets":"xx0232","fingers":"100132"}]},
+		{"key":"C","suffix":"major","instrument":"ukulele","tuning":"ukulele-standard","positions":[{"frets":"0003","fingers":"0003"}]}
+	]`
+	if err := os.WriteFile(arrayPath, []byte(array), 0644); err != nil {
+		return fmt.Errorf("failed to write array file: %v", err)
+	}
+
+	dbPath := filepath.Join(tmpDir, "array.db")
+	output, err := exec.Command("go", "run", "build_db.go", "-input-file", arrayPath, "-output", dbPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("build_db failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "chords=4") || !strings.Contains(string(output), `array.json element 2" position=1`) {
+		return fmt.Errorf("expected 4 chords inserted and element 2 rejected, got:\n%s", output)
+	}
+
+	// Inconsistent fingers are reported, but the chord is still imported
+	if !strings.Contains(string(output), `array.json element 4" position=1 problem="string 6: finger 1 on a muted string"`) ||
+		!strings.Contains(string(output), `msg="Found fingering inconsistencies" count=1`) {
+		return fmt.Errorf("expected the fingers of element 4 to be reported, got:\n%s", output)
+	}
+
+	cmd, err := startServer(serverBin, port, "-db", dbPath, "-resolve-order", "exact,alias")
+	if err != nil {
+		return err
+	}
+	defer stopServer(cmd)
+	for _, name := range []string{"Am", "Cmaj"} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chords/%s", port, name))
+		if err != nil {
+			return fmt.Errorf("failed to get %s: %v", name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("expected %s to resolve, got status %d", name, resp.StatusCode)
+		}
+	}
+
+	// The instrument stored with a chord is declared instead of the default
+	resp, err := http.Get(fmt.Sprintf("http://localhost:%d/chords?key=C&tuning=ukulele-standard", port))
+	if err != nil {
+		return fmt.Errorf("failed to get the ukulele chord: %v", err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get the ukulele chord: status %d (%v)", resp.StatusCode, err)
+	}
+	return expectDeclared("ukulele", "ukulele-standard")(body)
+}
+
+// testConcurrentBuild checks that build_db assigns the same chord IDs, fingerings and aliases whether files are
+// parsed serially or in parallel
+func testConcurrentBuild(tmpDir string) error {
+	sourceDir := filepath.Join(tmpDir, "concurrent")
+	for _, key := range []string{"A", "C", "D", "E", "G"} {
+		if err := os.MkdirAll(filepath.Join(sourceDir, key), 0755); err != nil {
+			return err
+		}
+		for _, suffix := range []string{"major", "minor", "7", "maj7", "m7", "sus2", "sus4", "add9"} {
+			data := fmt.Sprintf(`{"key":%q,"suffix":%q,"positions":[{"frets":"x32010","fingers":"032010"},{"frets":"x02210","fingers":"002310"}]}`, key, suffix)
+			if err := os.WriteFile(filepath.Join(sourceDir, key, suffix+".json"), []byte(data), 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	var dumps []string
+	for _, concurrency := range []string{"1", "8"} {
+		dbPath := filepath.Join(tmpDir, "concurrent-"+concurrency+".db")
+		output, err := exec.Command("go", "run", "build_db.go", "-source", sourceDir, "-output", dbPath, "-concurrency", concurrency).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("build_db -concurrency=%s failed: %v\n%s", concurrency, err, output)
+		}
+
+		dump, err := dumpBuild(dbPath)
+		if err != nil {
+			return err
+		}
+		dumps = append(dumps, dump)
+	}
+	if dumps[0] != dumps[1] {
+		return fmt.Errorf("serial and parallel builds differ:\n%s\n---\n%s", dumps[0], dumps[1])
+	}
+	return nil
+}
+
+// dumpBuild lists the chords, fingerings and aliases of a database with their IDs, one row per line
+func dumpBuild(dbPath string) (string, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	var dump strings.Builder
+	for _, query := range []string{
+		`SELECT id, key, suffix, tuning FROM chords ORDER BY id`,
+		`SELECT chord_id, frets, fingers FROM fingerings ORDER BY id`,
+		`SELECT chord_id, alias_key, alias_suffix, alias_tuning FROM chord_aliases ORDER BY id`,
+	} {
+		rows, err := db.Query(query)
+		if err != nil {
+			return "", err
+		}
+		columns, _ := rows.Columns()
+		for rows.Next() {
+			values := make([]interface{}, len(columns))
+			pointers := make([]interface{}, len(columns))
+			for i := range values {
+				pointers[i] = &values[i]
+			}
+			if err := rows.Scan(pointers...); err != nil {
+				rows.Close()
+				return "", err
+			}
+			fmt.Fprintln(&dump, values...)
+		}
+		rows.Close()
+	}
+	return dump.String(), nil
+}
+
+// testIncrementalBuild checks that build_db -incremental applies an edited, an added and a deleted file to a full
+// build: unchanged chords keep their IDs, the changed chord and its aliases are replaced, and nothing of the deleted
+// chord is left. It also checks that an update refuses a database that doesn't record its source files.
+func testIncrementalBuild(serverBin string, port int, tmpDir string) error {
+	sourceDir := filepath.Join(tmpDir, "incremental")
+	writeSource := func(name, data string) error {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(data), 0644)
+	}
+	for name, data := range map[string]string{
+		"A/minor.json": `{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"002310"}]}`,
+		"C/major.json": `{"key":"C","suffix":"major","positions":[{"frets":"x32010","fingers":"032010"}]}`,
+		"D/major.json": `{"key":"D","suffix":"major","positions":[{"frets":"xx0232","fingers":"000132"}]}`,
+		"G/major.json": `{"key":"G","suffix":"major","positions":[{"frets":"320003","fingers":"210003"}]}`,
+	} {
+		if err := writeSource(name, data); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+
+	dbPath := filepath.Join(tmpDir, "incremental.db")
+	if output, err := exec.Command("go", "run", "build_db.go", "-source", sourceDir, "-output", dbPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("full build failed: %v\n%s", err, output)
+	}
+	before, err := chordIDs(dbPath)
+	if err != nil {
+		return err
+	}
+
+	// Edit C major, add E minor and delete D major
+	if err := writeSource("C/major.json", `{"key":"C","suffix":"major","positions":[{"frets":"x35553","fingers":"013331","barres":"3"}]}`); err != nil {
+		return err
+	}
+	if err := writeSource("E/minor.json", `{"key":"E","suffix":"minor","positions":[{"frets":"022000","fingers":"023000"}]}`); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(sourceDir, "D/major.json")); err != nil {
+		return err
+	}
+
+	output, err := exec.Command("go", "run", "build_db.go", "-source", sourceDir, "-output", dbPath, "-incremental").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("incremental build failed: %v\n%s", err, output)
+	}
+	if !strings.Contains(string(output), "added=1 changed=1 removed=1 unchanged=2") {
+		return fmt.Errorf("expected 1 added, 1 changed, 1 removed and 2 unchanged files, got:\n%s", output)
+	}
+
+	after, err := chordIDs(dbPath)
+	if err != nil {
+		return err
+	}
+	for _, chord := range []string{"A|minor", "G|major"} {
+		if after[chord] != before[chord] {
+			return fmt.Errorf("unchanged chord %s moved from ID %d to %d", chord, before[chord], after[chord])
+		}
+	}
+	if _, ok := after["D|major"]; ok {
+		return fmt.Errorf("deleted chord D major is still stored")
+	}
+	if after["C|major"] == 0 || after["E|minor"] == 0 {
+		return fmt.Errorf("expected C major and E minor to be stored, got %v", after)
+	}
+
+	// Every alias and source file belongs to a stored chord, and the aliases of the changed chord moved with it
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	var orphans, sourceFiles int
+	var cmajOwner, emOwner int64
+	for _, check := range []struct {
+		query string
+		dest  interface{}
+	}{
+		{`SELECT COUNT(*) FROM chord_aliases WHERE chord_id NOT IN (SELECT id FROM chords)`, &orphans},
+		{`SELECT COUNT(*) FROM source_files WHERE chord_id IN (SELECT id FROM chords)`, &sourceFiles},
+		{`SELECT chord_id FROM chord_aliases WHERE alias_key = 'C' AND alias_suffix = 'maj'`, &cmajOwner},
+		{`SELECT chord_id FROM chord_aliases WHERE alias_key = 'E' AND alias_suffix = 'm'`, &emOwner},
+	} {
+		if err := db.QueryRow(check.query).Scan(check.dest); err != nil {
+			return fmt.Errorf("%s: %v", check.query, err)
+		}
+	}
+	if orphans != 0 || sourceFiles != 4 {
+		return fmt.Errorf("expected no orphaned aliases and 4 source files, got %d and %d", orphans, sourceFiles)
+	}
+	if cmajOwner != after["C|major"] || emOwner != after["E|minor"] {
+		return fmt.Errorf("expected C maj and E m to belong to chords %d and %d, got %d and %d", after["C|major"], after["E|minor"], cmajOwner, emOwner)
+	}
+
+	strict := []string{"-resolve-order", "exact,normalized,alias"}
+	for _, tc := range []fixtureTest{
+		{path: "/chords/C", wantStatus: http.StatusOK, check: expectPositionFrets("x35553")},
+		{path: "/chords/Cmaj", flags: []string{"-resolve-order", "exact,alias"}, wantStatus: http.StatusOK, check: expectPositionFrets("x35553")},
+		{path: "/chords/Em", wantStatus: http.StatusOK, check: expectPositionFrets("022000")},
+		{path: "/chords/Am", wantStatus: http.StatusOK, check: expectPositionFrets("x02210")},
+		{path: "/chords/D", flags: strict, wantStatus: http.StatusNotFound},
+	} {
+		if err := runFixtureTest(serverBin, port, dbPath, tc); err != nil {
+			return fmt.Errorf("%s: %v", tc.path, err)
+		}
+	}
+
+	// A database that wasn't built from -source can't be updated
+	fixtureDB := filepath.Join(tmpDir, "untracked.db")
+	if err := buildFixtureDB(fixtureDB, fixtureChords); err != nil {
+		return err
+	}
+	output, err = exec.Command("go", "run", "build_db.go", "-source", sourceDir, "-output", fixtureDB, "-incremental").CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "no source files are recorded") {
+		return fmt.Errorf("expected the update of a database without source_files to fail, got %v: %s", err, output)
+	}
+
+	// Nor can one built from -input-file, whose source_files table is empty
+	arrayPath := filepath.Join(tmpDir, "incremental-array.json")
+	if err := os.WriteFile(arrayPath, []byte(`[{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"002310"}]}]`), 0644); err != nil {
+		return err
+	}
+	arrayDB := filepath.Join(tmpDir, "incremental-array.db")
+	if output, err := exec.Command("go", "run", "build_db.go", "-input-file", arrayPath, "-output", arrayDB).CombinedOutput(); err != nil {
+		return fmt.Errorf("array build failed: %v\n%s", err, output)
+	}
+	output, err = exec.Command("go", "run", "build_db.go", "-source", sourceDir, "-output", arrayDB, "-incremental").CombinedOutput()
+	if err == nil || !strings.Contains(string(output), "not built from source files") {
+		return fmt.Errorf("expected the update of a database built from -input-file to fail, got %v: %s", err, output)
+	}
+	return nil
+}
+
+// chordIDs maps each stored chord, as key|suffix, to its ID
+func chordIDs(dbPath string) (map[string]int64, error) {
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT id, key, suffix FROM chords`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64)
+	for rows.Next() {
+		var id int64
+		var key, suffix string
+		if err := rows.Scan(&id, &key, &suffix); err != nil {
+			return nil, err
+		}
+		ids[key+"|"+suffix] = id
+	}
+	return ids, rows.Err()
+}
+
+// testTuningOrder checks that chord names resolve to the standard tuning when a chord in another tuning is imported
+// first, through every resolution strategy and sub-resource, and that build_db gives each tuning its own aliases
+func testTuningOrder(serverBin string, port int, tmpDir string) error {
+	sourceDir := filepath.Join(tmpDir, "tunings")
+	files := map[string]string{
+		"A/a-dropd.json": `{"key":"A","suffix":"minor","tuning":"drop-d","positions":[{"frets":"702210","fingers":"400231"}]}`,
+		"A/minor.json":   `{"key":"A","suffix":"minor","positions":[{"frets":"x02210","fingers":"002310"}]}`,
+		"C/a-dropd.json": `{"key":"C","suffix":"major","tuning":"drop-d","positions":[{"frets":"0x2010","fingers":"0x2010"}]}`,
+		"C/major.json":   `{"key":"C","suffix":"major","positions":[{"frets":"x32010","fingers":"032010"}]}`,
+	}
+	for name, data := range files {
+		path := filepath.Join(sourceDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", name, err)
+		}
+	}
+
+	dbPath := filepath.Join(tmpDir, "tunings.db")
+	output, err := exec.Command("go", "run", "build_db.go", "-source", sourceDir, "-output", dbPath).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("build_db failed: %v\n%s", err, output)
+	}
+
+	aliasOnly := []string{"-resolve-order", "exact,alias"}
+	for _, tc := range []fixtureTest{
+		{path: "/chords/C", wantStatus: http.StatusOK, check: expectPositionFrets("x32010")},
+		{path: "/chords/Cmajor", wantStatus: http.StatusOK, check: expectPositionFrets("x32010")},
+		{path: "/chords/Cmaj", flags: aliasOnly, wantStatus: http.StatusOK, check: expectPositionFrets("x32010")},
+		{path: "/chords/CM", flags: aliasOnly, wantStatus: http.StatusOK, check: expectPositionFrets("x32010")},
+		{path: "/chords/C/relative", wantStatus: http.StatusOK, check: expectPositionFrets("x02210")},
+		{path: "/chords/Am/relative", wantStatus: http.StatusOK, check: expectPositionFrets("x32010")},
+		{method: "POST", path: "/transpose/batch", body: `{"chords": ["C"], "semitones": 12}`, wantStatus: http.StatusOK, check: expectPositionFrets("x32010")},
+		{path: "/chords?key=C&suffix=major&tuning=drop-d", wantStatus: http.StatusOK, check: expectPositionFrets("0x2010")},
+	} {
+		if err := runFixtureTest(serverBin, port, dbPath, tc); err != nil {
+			return fmt.Errorf("%s: %v", tc.path, err)
+		}
+	}
+
+	// Each tuning owns its own copy of an alias
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rows, err := db.Query(`
+		SELECT c.tuning, a.alias_tuning FROM chord_aliases a JOIN chords c ON c.id = a.chord_id
+		WHERE a.alias_key = 'C' AND a.alias_suffix = 'maj' ORDER BY c.tuning
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var owners []string
+	for rows.Next() {
+		var chordTuning, aliasTuning string
+		if err := rows.Scan(&chordTuning, &aliasTuning); err != nil {
+			return err
+		}
+		owners = append(owners, chordTuning+":"+aliasTuning)
+	}
+	if fmt.Sprint(owners) != "[drop-d:drop-d standard:standard]" {
+		return fmt.Errorf("expected the C maj alias once per tuning, got %v", owners)
+	}
+	return rows.Err()
+}
+
+// testLogLevel checks that -log-level filters what a server writes to its -log-output file:
+// warnings but not info at warn level, and request diagnostics at debug level
+func testLogLevel(serverBin string, port int, tmpDir, fixtureDB string) error {
+	for _, level := range []string{"warn", "debug"} {
+		logPath := filepath.Join(tmpDir, level+".log")
+		cmd, err := startServer(serverBin, port, "-db", fixtureDB, "-log-level", level, "-log-output", logPath)
+		if err != nil {
+			return err
+		}
+		resp, err := http.Get(fmt.Sprintf("http://localhost:%d/search/Am", port))
+		if err == nil {
+			resp.Body.Close()
+		}
+		stopServer(cmd)
+		if err != nil {
+			return fmt.Errorf("failed to search: %v", err)
+		}
+
+		data, err := os.ReadFile(logPath)
+		if err != nil {
+			return fmt.Errorf("failed to read the %s log: %v", level, err)
+		}
+		logs := string(data)
+
+		// The fixture chord without positions is skipped with a warning
+		if !strings.Contains(logs, "level=WARN") || !strings.Contains(logs, "Skipping chord E 7") {
+			return fmt.Errorf("expected warnings at %s level, got:\n%s", level, logs)
+		}
+		if hasInfo := strings.Contains(logs, "level=INFO"); hasInfo != (level == "debug") {
+			return fmt.Errorf("expected info logs only at debug level, got at %s level:\n%s", level, logs)
+		}
+		if hasDebug := strings.Contains(logs, "search interpretation"); hasDebug != (level == "debug") {
+			return fmt.Errorf("expected search diagnostics only at debug level, got at %s level:\n%s", level, logs)
+		}
+	}
+	return nil
+}